@@ -1,84 +1,268 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"expvar"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
-	"strings"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
 
+	"fruitsapi/internal/config"
+	"fruitsapi/internal/events"
 	"fruitsapi/internal/handler"
+	"fruitsapi/internal/health"
 	"fruitsapi/internal/middleware"
 	"fruitsapi/internal/repository"
+	"fruitsapi/internal/server"
 	"fruitsapi/internal/service"
+	"fruitsapi/internal/webhook"
 	"fruitsapi/pkg/kvs"
 )
 
-// Router handles HTTP requests and routes them to the appropriate handlers
-type Router struct {
-	fruitHandler *handler.FruitHandler
+// applyMiddleware wraps a handler with multiple middleware
+func applyMiddleware(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
+	for _, middleware := range middlewares {
+		handler = middleware(handler)
+	}
+	return handler
 }
 
-// NewRouter creates a new instance of Router
-func NewRouter(fruitHandler *handler.FruitHandler) *Router {
-	return &Router{
-		fruitHandler: fruitHandler,
+// runReencryption periodically reloads the keyring, to pick up keys
+// rotated with kvsctl, and re-encrypts the records written with older keys
+// until ctx is cancelled
+func runReencryption(ctx context.Context, client *kvs.Client, keyring *kvs.Keyring, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := keyring.ReloadFile(path); err != nil {
+				log.Printf("Error reloading keyring: %v", err)
+				continue
+			}
+			n, err := client.Reencrypt(ctx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				log.Printf("Error re-encrypting values: %v", err)
+			}
+			if n > 0 {
+				log.Printf("Re-encrypted %d values with key %s", n, keyring.Primary())
+			}
+		}
 	}
 }
 
-// ServeHTTP implements the http.Handler interface
-func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	path := req.URL.Path
+// runSnapshots periodically persists the store until ctx is cancelled
+func runSnapshots(ctx context.Context, client *kvs.Client, path string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	// Route requests based on the path
-	if path == "/fruits" && req.Method == http.MethodPost {
-		r.fruitHandler.CreateFruit(w, req)
-		return
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := client.SaveSnapshotFile(path); err != nil {
+				log.Printf("Error saving periodic snapshot: %v", err)
+			}
+		}
 	}
+}
 
-	if strings.HasPrefix(path, "/fruits/") && req.Method == http.MethodGet {
-		r.fruitHandler.GetFruitByID(w, req)
+func main() {
+	cfg, opts, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
 		return
 	}
-
-	// Handle 404 for unknown routes
-	w.WriteHeader(http.StatusNotFound)
-	fmt.Fprintf(w, "Not found")
-}
-
-// applyMiddleware wraps a handler with multiple middleware
-func applyMiddleware(handler http.Handler, middlewares ...func(http.Handler) http.Handler) http.Handler {
-	for _, middleware := range middlewares {
-		handler = middleware(handler)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-	return handler
-}
 
-func main() {
 	// Initialize KVS client
-	client := kvs.NewClient()
+	storageOpts, _ := cfg.Storage.ClientOptions() // already checked by config validation
+	var keyring *kvs.Keyring
+	if cfg.Storage.KeyringPath != "" {
+		if keyring, err = kvs.LoadKeyringFile(cfg.Storage.KeyringPath); err != nil {
+			log.Fatalf("Error loading keyring: %v", err)
+		}
+		storageOpts = append(storageOpts, kvs.WithEncryption(keyring))
+	}
+	client := kvs.NewClient(storageOpts...)
+	expvar.Publish("kvs_stats", expvar.Func(func() any { return client.Stats() }))
+	if cfg.Storage.SnapshotPath != "" {
+		if err := client.LoadSnapshotFile(cfg.Storage.SnapshotPath); err != nil {
+			log.Fatalf("Error loading snapshot: %v", err)
+		}
+	}
 
-	// Initialize repository
+	// Initialize repositories
 	fruitRepo := repository.NewKVSFruitRepository(client)
+	outboxRepo := repository.NewKVSOutboxRepository(client)
+	webhookRepo := repository.NewKVSWebhookRepository(client)
+	auditRepo := repository.NewKVSAuditRepository(client)
 
-	// Initialize service
+	// Initialize services; the domain events they store in the outbox are
+	// relayed to the bus
 	fruitService := service.NewFruitService(fruitRepo)
+	outboxService := service.NewOutboxService(outboxRepo)
+	auditService := service.NewAuditService(auditRepo)
+	webhookService := service.NewWebhookService(webhookRepo)
+	dispatcher := webhook.NewDispatcher(webhookRepo, webhook.Config{
+		Workers:      cfg.Webhooks.Workers,
+		PollInterval: time.Duration(cfg.Webhooks.PollInterval),
+		Timeout:      time.Duration(cfg.Webhooks.Timeout),
+		MaxAttempts:  cfg.Webhooks.MaxAttempts,
+		MinBackoff:   time.Duration(cfg.Webhooks.MinBackoff),
+		MaxBackoff:   time.Duration(cfg.Webhooks.MaxBackoff),
+		DisableAfter: cfg.Webhooks.DisableAfter,
+	})
+	bus := events.NewBus()
+	bus.Subscribe("metrics", events.MetricsHandler(expvar.NewMap("fruit_metrics")), events.Async(1, 256))
+	// Synchronous, so that the relay delivers the event again when queueing
+	// its webhook deliveries fails
+	bus.Subscribe("webhooks", dispatcher.Handle)
+	relay := events.NewRelay(outboxRepo, bus, events.RelayConfig{
+		PollInterval: time.Duration(cfg.Outbox.PollInterval),
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		MinBackoff:   time.Duration(cfg.Outbox.MinBackoff),
+		MaxBackoff:   time.Duration(cfg.Outbox.MaxBackoff),
+	})
 
-	// Initialize handler
+	// Initialize handlers
 	fruitHandler := handler.NewFruitHandler(fruitService)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	adminHandler := handler.NewAdminHandler(outboxService, auditService, cfg.API.AdminToken)
+	fruitHandler.SetMaxBodyBytes(cfg.API.MaxBodyBytes)
+	webhookHandler.SetMaxBodyBytes(cfg.API.MaxBodyBytes)
+	sunsets, _ := cfg.API.Sunsets() // already checked by config validation
+	for version, sunset := range sunsets {
+		if err := fruitHandler.DeprecateVersion(version, sunset); err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	// Initialize router
-	router := NewRouter(fruitHandler)
+	// Readiness checks
+	healthRegistry := health.NewRegistry(time.Duration(cfg.Health.CheckTimeout))
+	healthRegistry.Register("kvs", health.KVSChecker(client))
+	switch {
+	case cfg.Storage.SnapshotPath == "":
+	case health.DiskSupported:
+		healthRegistry.Register("disk", health.DiskChecker(filepath.Dir(cfg.Storage.SnapshotPath), uint64(cfg.Health.MinFreeDisk)))
+	default:
+		log.Printf("Free disk space is not checked on %s", runtime.GOOS)
+	}
 
-	// Apply middleware
-	handlerWithMiddleware := applyMiddleware(
-		router,
+	// Initialize routes and apply middleware; health and OpenAPI endpoints
+	// bypass the middleware chain so probes never need an Owner
+	rootHandler := newRoutes(fruitHandler, webhookHandler, adminHandler, healthRegistry, cfg.API.MaxBodyBytes).handler(
 		middleware.LoggingMiddleware,
 		middleware.ContentTypeValidator,
 		middleware.OwnerValidator,
+		middleware.Actor,
+		middleware.RequestID,
+		middleware.Recovery,
 	)
 
+	// Stop on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(server.Config{
+		Addr:              cfg.Server.Addr,
+		ReadTimeout:       time.Duration(cfg.Server.ReadTimeout),
+		ReadHeaderTimeout: time.Duration(cfg.Server.ReadHeaderTimeout),
+		WriteTimeout:      time.Duration(cfg.Server.WriteTimeout),
+		IdleTimeout:       time.Duration(cfg.Server.IdleTimeout),
+		DrainDelay:        time.Duration(cfg.Server.DrainDelay),
+		ShutdownTimeout:   time.Duration(cfg.Server.ShutdownTimeout),
+	}, rootHandler)
+	srv.OnDrain(healthRegistry.Drain)
+	srv.OnDrain(fruitHandler.CloseEventStreams)
+
+	// Background workers stop before the final snapshot is taken
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	if cfg.Storage.SnapshotPath != "" {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			runSnapshots(workersCtx, client, cfg.Storage.SnapshotPath, time.Duration(cfg.Storage.SnapshotInterval))
+		}()
+	}
+	if keyring != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			runReencryption(workersCtx, client, keyring, cfg.Storage.KeyringPath, time.Duration(cfg.Storage.ReencryptInterval))
+		}()
+	}
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		dispatcher.Run(workersCtx)
+	}()
+
+	// The relay stops before the bus is closed, so that the events it has
+	// not delivered stay in the outbox for the next start
+	relayCtx, stopRelay := context.WithCancel(context.Background())
+	relayDone := make(chan struct{})
+	go func() {
+		defer close(relayDone)
+		relay.Run(relayCtx)
+	}()
+
+	// Upgraded WebSocket connections are not drained by the HTTP server
+	srv.OnShutdown("event streams", fruitHandler.WaitEventStreams)
+	srv.OnShutdown("outbox relay", func(ctx context.Context) error {
+		stopRelay()
+		select {
+		case <-relayDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	srv.OnShutdown("event bus", bus.Close)
+	srv.OnShutdown("background workers", func(ctx context.Context) error {
+		stopWorkers()
+		workersDone := make(chan struct{})
+		go func() {
+			workers.Wait()
+			close(workersDone)
+		}()
+		select {
+		case <-workersDone:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	if cfg.Storage.SnapshotPath != "" {
+		srv.OnShutdown("storage", func(ctx context.Context) error {
+			return client.SaveSnapshotFile(cfg.Storage.SnapshotPath)
+		})
+	}
+
 	// Start HTTP server
-	port := ":8080"
-	fmt.Printf("Starting server on port %s...\n", port)
-	log.Fatal(http.ListenAndServe(port, handlerWithMiddleware))
+	if err := srv.Run(ctx); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Server stopped")
 }
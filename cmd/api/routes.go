@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+
+	"fruitsapi/internal/handler"
+	"fruitsapi/internal/health"
+	"fruitsapi/internal/middleware"
+	"fruitsapi/internal/openapi"
+	"fruitsapi/internal/router"
+)
+
+// Paths served outside the middleware chain
+const (
+	livenessPath  = "/healthz"
+	readinessPath = "/readyz"
+	openAPIPath   = "/openapi.json"
+)
+
+// routes holds the route tables of the process and the OpenAPI document
+// describing them
+type routes struct {
+	// root serves the health and meta endpoints, which bypass the middleware chain
+	root *router.Router
+	// api serves the fruit, webhook and admin endpoints behind the middleware chain
+	api *router.Router
+	doc *openapi.Document
+}
+
+// newRoutes registers every endpoint together with its OpenAPI description
+func newRoutes(fruitHandler *handler.FruitHandler, webhookHandler *handler.WebhookHandler, adminHandler *handler.AdminHandler, healthRegistry *health.Registry, maxBodyBytes int64) *routes {
+	rs := &routes{
+		root: router.New(router.TrailingSlashRedirect),
+		api:  router.New(router.TrailingSlashRedirect),
+		doc: openapi.NewDocument(openapi.Info{
+			Title:   "Fruits API",
+			Version: "1.0.0",
+		}),
+	}
+
+	fruitHandler.RegisterRoutes(rs.api)
+	fruitHandler.DescribeRoutes(rs.doc)
+	webhookHandler.RegisterRoutes(rs.api)
+	webhookHandler.DescribeRoutes(rs.doc)
+	adminHandler.RegisterRoutes(rs.api)
+	adminHandler.DescribeRoutes(rs.doc)
+	// Requests reach the handlers only once they satisfy the document
+	rs.api.Use(middleware.OpenAPIValidator(rs.doc, maxBodyBytes, fruitHandler.OperationPath))
+
+	rs.root.Handle(http.MethodGet, livenessPath, healthRegistry.LivenessHandler())
+	rs.root.Handle(http.MethodGet, readinessPath, healthRegistry.ReadinessHandler())
+	healthRegistry.DescribeRoutes(rs.doc, livenessPath, readinessPath)
+
+	rs.root.Handle(http.MethodGet, openAPIPath, openapi.Handler(rs.doc))
+	rs.doc.DescribeRoutes(openAPIPath)
+
+	return rs
+}
+
+// handler returns the root handler, sending the requests that do not
+// match a root route through the middleware chain to the API routes. The
+// root routes recover from panics too.
+func (rs *routes) handler(middlewares ...func(http.Handler) http.Handler) http.Handler {
+	rs.root.Fallback(applyMiddleware(rs.api, middlewares...))
+	return middleware.Recovery(rs.root)
+}
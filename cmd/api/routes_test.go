@@ -0,0 +1,255 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"fruitsapi/internal/handler"
+	"fruitsapi/internal/health"
+	"fruitsapi/internal/middleware"
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/kvs"
+)
+
+func newTestRoutes() *routes {
+	client := kvs.NewClient()
+	fruitHandler := handler.NewFruitHandler(service.NewFruitService(repository.NewKVSFruitRepository(client)))
+	webhookHandler := handler.NewWebhookHandler(service.NewWebhookService(repository.NewKVSWebhookRepository(client)))
+	adminHandler := handler.NewAdminHandler(service.NewOutboxService(repository.NewKVSOutboxRepository(client)), service.NewAuditService(repository.NewKVSAuditRepository(client)), "secret")
+	return newRoutes(fruitHandler, webhookHandler, adminHandler, health.NewRegistry(time.Second), handler.DefaultMaxBodyBytes)
+}
+
+func TestRoutes_EveryRouteIsInSpec(t *testing.T) {
+	rs := newTestRoutes()
+
+	for _, rt := range []*router.Router{rs.root, rs.api} {
+		for _, route := range rt.Routes() {
+			if _, ok := rs.doc.Operation(route.Method, route.Pattern); !ok {
+				t.Errorf("Expected route %s %s to be described in the OpenAPI document", route.Method, route.Pattern)
+			}
+		}
+	}
+}
+
+func TestRoutes_EverySpecOperationIsRouted(t *testing.T) {
+	rs := newTestRoutes()
+
+	routed := make(map[string]bool)
+	for _, rt := range []*router.Router{rs.root, rs.api} {
+		for _, route := range rt.Routes() {
+			routed[strings.ToLower(route.Method)+" "+route.Pattern] = true
+		}
+	}
+
+	for path, item := range rs.doc.Paths {
+		for method := range *item {
+			if !routed[method+" "+path] {
+				t.Errorf("Expected OpenAPI operation %s %s to be routed", method, path)
+			}
+		}
+	}
+}
+
+func TestRoutes_ServeOpenAPI(t *testing.T) {
+	h := newTestRoutes().handler()
+	recorder := httptest.NewRecorder()
+
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, openAPIPath, nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var doc struct {
+		OpenAPI string                 `json:"openapi"`
+		Paths   map[string]interface{} `json:"paths"`
+	}
+	if err := json.NewDecoder(recorder.Body).Decode(&doc); err != nil {
+		t.Fatalf("Error decoding response body: %v", err)
+	}
+	if doc.OpenAPI != "3.1.0" {
+		t.Errorf("Expected openapi %s, got %s", "3.1.0", doc.OpenAPI)
+	}
+	if _, ok := doc.Paths["/fruits/{id}"]; !ok {
+		t.Error("Expected path /fruits/{id} in the served document")
+	}
+}
+
+func TestRoutes_RootRecovery(t *testing.T) {
+	// Setup: a root route that panics, outside the middleware chain
+	rs := newTestRoutes()
+	rs.root.Handle(http.MethodGet, "/panic", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+	h := rs.handler()
+	recorder := httptest.NewRecorder()
+
+	// Action
+	h.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/panic", nil))
+
+	// Assertions
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+}
+
+func TestRoutes_WebSocketThroughMiddleware(t *testing.T) {
+	// Setup: the middleware chain of the server wraps the ResponseWriter
+	server := httptest.NewServer(newTestRoutes().handler(
+		middleware.LoggingMiddleware,
+		middleware.ContentTypeValidator,
+		middleware.OwnerValidator,
+		middleware.Actor,
+		middleware.RequestID,
+		middleware.Recovery,
+	))
+	defer server.Close()
+
+	// Action
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", http.Header{"Owner": {"test"}})
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected the upgrade to succeed, got %v", err)
+	}
+	defer conn.Close()
+	if err := conn.WriteJSON(handler.ClientMessage{Type: "subscribe", ID: "all"}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	var reply handler.ServerMessage
+	if err := conn.ReadJSON(&reply); err != nil || reply.Type != "subscribed" {
+		t.Errorf("Expected subscribed, got %+v (%v)", reply, err)
+	}
+}
+
+func TestRoutes_AdminThroughMiddleware(t *testing.T) {
+	// Setup
+	h := newTestRoutes().handler(
+		middleware.LoggingMiddleware,
+		middleware.ContentTypeValidator,
+		middleware.OwnerValidator,
+		middleware.Actor,
+		middleware.RequestID,
+		middleware.Recovery,
+	)
+	req := httptest.NewRequest(http.MethodPost, "/admin/outbox/unknown/replay", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+
+	// Action
+	h.ServeHTTP(rec, req)
+
+	// Assertions: no Owner is needed to reach the handler
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status code %d, got %d: %s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoutes_WebhookThroughMiddleware(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Valid", body: `{"url":"https://example.com/hook","events":["fruit.created"],"secret":"0123456789abcdef"}`, expectedStatus: http.StatusCreated},
+		{name: "UnknownEvent", body: `{"url":"https://example.com/hook","events":["fruit.eaten"],"secret":"0123456789abcdef"}`, expectedStatus: http.StatusBadRequest},
+		{name: "ShortSecret", body: `{"url":"https://example.com/hook","events":["fruit.created"],"secret":"short"}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			h := newTestRoutes().handler(
+				middleware.LoggingMiddleware,
+				middleware.ContentTypeValidator,
+				middleware.OwnerValidator,
+				middleware.RequestID,
+				middleware.Recovery,
+			)
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Owner", "alice")
+			rec := httptest.NewRecorder()
+
+			// Action
+			h.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestRoutes_V2AcceptNegotiation(t *testing.T) {
+	// Setup
+	h := newTestRoutes().handler()
+	req := httptest.NewRequest(http.MethodPost, "/fruits", strings.NewReader(`{"name":"Apple","quantity":3,"unit_price":2}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/vnd.fruits.v2+json")
+	req.Header.Set("Owner", "test")
+	rec := httptest.NewRecorder()
+
+	// Action
+	h.ServeHTTP(rec, req)
+
+	// Assertions: the body is validated against the v2 schema
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, rec.Code, rec.Body.String())
+	}
+	var resp handler.FruitResponseV2
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Error decoding response body: %v", err)
+	}
+	if resp.UnitPrice != 2 {
+		t.Errorf("Expected unit price 2, got %v", resp.UnitPrice)
+	}
+}
+
+func TestRoutes_AuditThroughMiddleware(t *testing.T) {
+	// Setup
+	h := newTestRoutes().handler(
+		middleware.LoggingMiddleware,
+		middleware.ContentTypeValidator,
+		middleware.OwnerValidator,
+		middleware.Actor,
+		middleware.RequestID,
+		middleware.Recovery,
+	)
+	req := httptest.NewRequest(http.MethodPost, "/fruits", strings.NewReader(`{"name":"manzana","quantity":12,"price":1000}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Owner", "test")
+	req.Header.Set(middleware.RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Failed to create fruit: %d %s", rec.Code, rec.Body.String())
+	}
+
+	// Action
+	req = httptest.NewRequest(http.MethodGet, "/audit?actor=owner:test&from=2000-01-01T00:00:00Z&limit=10", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	// Assertions: the actor and request ID of the change are recorded
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp handler.AuditListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Error decoding response body: %v", err)
+	}
+	if len(resp.Entries) != 1 || resp.Entries[0].RequestID != "req-1" {
+		t.Errorf("Expected one entry of req-1, got %+v", resp.Entries)
+	}
+}
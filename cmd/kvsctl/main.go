@@ -0,0 +1,151 @@
+// Command kvsctl manages the encryption keys of the KVS store.
+//
+//	kvsctl rotate -keyring keyring.json [-snapshot snapshot.json]
+//	kvsctl verify -keyring keyring.json -snapshot snapshot.json [-allow-plaintext]
+//
+// rotate adds a new primary key to the keyring, creating it if needed. A
+// running API reloads the keyring and re-encrypts its values in the
+// background; with -snapshot, the values of a stopped API's snapshot are
+// re-encrypted right away. verify checks that every value of a snapshot
+// is encrypted and decrypts with the keyring; -allow-plaintext accepts
+// values that are not encrypted, such as those written before encryption
+// was enabled.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"fruitsapi/pkg/kvs"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "kvsctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run executes the command line args, writing its report to stdout and
+// usage messages to stderr
+func run(args []string, stdout, stderr io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(stderr, "usage: kvsctl rotate|verify [flags]")
+		return flag.ErrHelp
+	}
+
+	fs := flag.NewFlagSet("kvsctl "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	keyringPath := fs.String("keyring", "", "keyring file")
+	snapshotPath := fs.String("snapshot", "", "snapshot file of the store")
+	allowPlaintext := fs.Bool("allow-plaintext", false, "do not fail verify on values that are not encrypted")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	if *keyringPath == "" {
+		return errors.New("-keyring is required")
+	}
+
+	switch args[0] {
+	case "rotate":
+		return rotate(stdout, *keyringPath, *snapshotPath)
+	case "verify":
+		if *snapshotPath == "" {
+			return errors.New("-snapshot is required")
+		}
+		return verify(stdout, *keyringPath, *snapshotPath, *allowPlaintext)
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// rotate adds a primary key to the keyring, then re-encrypts the snapshot
+// if one is given
+func rotate(stdout io.Writer, keyringPath, snapshotPath string) error {
+	keyring, err := kvs.LoadKeyringFile(keyringPath)
+	if errors.Is(err, os.ErrNotExist) {
+		keyring, err = kvs.NewKeyring(), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	id, err := keyring.Rotate()
+	if err != nil {
+		return err
+	}
+	if err := keyring.SaveFile(keyringPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "New primary key %s\n", id)
+
+	if snapshotPath == "" {
+		return nil
+	}
+	client := kvs.NewClient(kvs.WithEncryption(keyring))
+	if err := client.LoadSnapshotFile(snapshotPath); err != nil {
+		return err
+	}
+	n, err := client.Reencrypt(context.Background())
+	if err != nil {
+		return err
+	}
+	if err := client.SaveSnapshotFile(snapshotPath); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Re-encrypted %d values\n", n)
+	return nil
+}
+
+// verify reports the keys the values of the snapshot are encrypted with,
+// failing if any of them does not decrypt or, unless allowPlaintext, is not
+// encrypted
+func verify(stdout io.Writer, keyringPath, snapshotPath string, allowPlaintext bool) error {
+	keyring, err := kvs.LoadKeyringFile(keyringPath)
+	if err != nil {
+		return err
+	}
+	client := kvs.NewClient(kvs.WithEncryption(keyring))
+	if err := client.LoadSnapshotFile(snapshotPath); err != nil {
+		return err
+	}
+
+	report, err := client.VerifyEncryption(context.Background())
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(report.ByKey))
+	for id := range report.ByKey {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	fmt.Fprintf(stdout, "%d values\n", report.Records)
+	for _, id := range ids {
+		primary := ""
+		if id == keyring.Primary() {
+			primary = " (primary)"
+		}
+		fmt.Fprintf(stdout, "  key %s%s: %d\n", id, primary, report.ByKey[id])
+	}
+	if report.Plaintext > 0 {
+		fmt.Fprintf(stdout, "  not encrypted: %d\n", report.Plaintext)
+	}
+	for _, key := range report.Failed {
+		fmt.Fprintf(stdout, "  cannot decrypt: %s\n", key)
+	}
+	if len(report.Failed) > 0 {
+		return fmt.Errorf("%d values cannot be decrypted", len(report.Failed))
+	}
+	if report.Plaintext > 0 && !allowPlaintext {
+		return fmt.Errorf("%d values are not encrypted", report.Plaintext)
+	}
+	return nil
+}
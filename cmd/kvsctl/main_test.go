@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"fruitsapi/pkg/kvs"
+)
+
+func TestRun_RotateAndVerify(t *testing.T) {
+	// Setup: a snapshot written with a first key
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring.json")
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"rotate", "-keyring", keyringPath}, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+	keyring, err := kvs.LoadKeyringFile(keyringPath)
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+	client := kvs.NewClient(kvs.WithEncryption(keyring))
+	for _, key := range []string{"a", "b"} {
+		if err := client.Set(context.Background(), key, key); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+	if err := client.SaveSnapshotFile(snapshotPath); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+
+	// Action
+	stdout.Reset()
+	err = run([]string{"rotate", "-keyring", keyringPath, "-snapshot", snapshotPath}, &stdout, &stderr)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Re-encrypted 2 values") {
+		t.Errorf("Expected 2 re-encrypted values, got %q", stdout.String())
+	}
+	if err := keyring.ReloadFile(keyringPath); err != nil {
+		t.Fatalf("Failed to reload keyring: %v", err)
+	}
+	stdout.Reset()
+	if err := run([]string{"verify", "-keyring", keyringPath, "-snapshot", snapshotPath}, &stdout, &stderr); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if want := "key " + keyring.Primary() + " (primary): 2"; !strings.Contains(stdout.String(), want) {
+		t.Errorf("Expected %q in %q", want, stdout.String())
+	}
+}
+
+func TestRun_VerifyFailure(t *testing.T) {
+	// Setup: a snapshot written with a key that is not in the keyring
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring.json")
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	other := kvs.NewKeyring()
+	if _, err := other.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate keyring: %v", err)
+	}
+	client := kvs.NewClient(kvs.WithEncryption(other))
+	if err := client.Set(context.Background(), "a", "a"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := client.SaveSnapshotFile(snapshotPath); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"rotate", "-keyring", keyringPath}, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	// Action
+	stdout.Reset()
+	err := run([]string{"verify", "-keyring", keyringPath, "-snapshot", snapshotPath}, &stdout, &stderr)
+
+	// Assertions
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+	if !strings.Contains(stdout.String(), "cannot decrypt: a") {
+		t.Errorf("Expected the failing value to be reported, got %q", stdout.String())
+	}
+}
+
+func TestRun_VerifyPlaintext(t *testing.T) {
+	// Setup: a snapshot written before encryption was enabled
+	dir := t.TempDir()
+	keyringPath := filepath.Join(dir, "keyring.json")
+	snapshotPath := filepath.Join(dir, "snapshot.json")
+	client := kvs.NewClient()
+	if err := client.Set(context.Background(), "a", "a"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := client.SaveSnapshotFile(snapshotPath); err != nil {
+		t.Fatalf("Failed to save snapshot: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if err := run([]string{"rotate", "-keyring", keyringPath}, &stdout, &stderr); err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError bool
+	}{
+		{name: "Rejected", expectedError: true},
+		{name: "Allowed", args: []string{"-allow-plaintext"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			stdout.Reset()
+			args := append([]string{"verify", "-keyring", keyringPath, "-snapshot", snapshotPath}, tt.args...)
+			err := run(args, &stdout, &stderr)
+
+			// Assertions
+			if (err != nil) != tt.expectedError {
+				t.Errorf("Expected error %v, got %v", tt.expectedError, err)
+			}
+			if !strings.Contains(stdout.String(), "not encrypted: 1") {
+				t.Errorf("Expected the plaintext value to be reported, got %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRun_Usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "NoCommand"},
+		{name: "UnknownCommand", args: []string{"encrypt", "-keyring", "k.json"}},
+		{name: "MissingKeyring", args: []string{"rotate"}},
+		{name: "MissingSnapshot", args: []string{"verify", "-keyring", "k.json"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if err := run(tt.args, &stdout, &stderr); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
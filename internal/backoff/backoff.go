@@ -0,0 +1,16 @@
+// Package backoff holds the retry delays shared by the outbox relay and the
+// webhook dispatcher
+package backoff
+
+import "time"
+
+// Delay returns the delay before the retry following the given number of
+// failed attempts: minimum after the first one, doubling with every attempt
+// up to maximum
+func Delay(minimum, maximum time.Duration, attempts int) time.Duration {
+	d := minimum
+	for i := 1; i < attempts && d < maximum; i++ {
+		d *= 2
+	}
+	return min(d, maximum)
+}
@@ -0,0 +1,31 @@
+package backoff
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		expected time.Duration
+	}{
+		{name: "FirstRetry", attempts: 1, expected: time.Second},
+		{name: "Doubles", attempts: 3, expected: 4 * time.Second},
+		{name: "Capped", attempts: 10, expected: time.Minute},
+		{name: "NoOverflow", attempts: 1000, expected: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			got := Delay(time.Second, time.Minute, tt.attempts)
+
+			// Assertions
+			if got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
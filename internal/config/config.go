@@ -0,0 +1,269 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"fruitsapi/pkg/kvs"
+)
+
+// Config holds every setting of the API process
+type Config struct {
+	Server   ServerConfig   `json:"server" yaml:"server"`
+	Storage  StorageConfig  `json:"storage" yaml:"storage"`
+	Health   HealthConfig   `json:"health" yaml:"health"`
+	API      APIConfig      `json:"api" yaml:"api"`
+	Outbox   OutboxConfig   `json:"outbox" yaml:"outbox"`
+	Webhooks WebhooksConfig `json:"webhooks" yaml:"webhooks"`
+}
+
+// ServerConfig holds the HTTP server settings
+type ServerConfig struct {
+	// Addr is a TCP address (":8080") or a Unix socket ("unix:/run/fruits.sock")
+	Addr              string   `json:"addr" yaml:"addr" usage:"TCP address or unix:/path/to/socket to listen on"`
+	ReadTimeout       Duration `json:"read_timeout" yaml:"read_timeout" usage:"maximum duration for reading an entire request"`
+	ReadHeaderTimeout Duration `json:"read_header_timeout" yaml:"read_header_timeout" usage:"maximum duration for reading request headers"`
+	WriteTimeout      Duration `json:"write_timeout" yaml:"write_timeout" usage:"maximum duration before timing out writes of the response"`
+	IdleTimeout       Duration `json:"idle_timeout" yaml:"idle_timeout" usage:"maximum time to wait for the next request on keep-alive connections"`
+	DrainDelay        Duration `json:"drain_delay" yaml:"drain_delay" usage:"time to keep serving as not ready before closing the listener on shutdown"`
+	ShutdownTimeout   Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" usage:"drain deadline for in-flight requests and background workers"`
+}
+
+// StorageConfig holds the key-value store settings
+type StorageConfig struct {
+	SnapshotPath         string   `json:"snapshot_path" yaml:"snapshot_path" usage:"file used to persist the store across restarts (disabled when empty)"`
+	SnapshotInterval     Duration `json:"snapshot_interval" yaml:"snapshot_interval" usage:"interval between periodic snapshots"`
+	Codec                string   `json:"codec" yaml:"codec" usage:"codec of newly written values: json, gob, msgpack or cbor"`
+	Compression          string   `json:"compression" yaml:"compression" usage:"compression of large values: none, gzip or zstd"`
+	CompressionThreshold int      `json:"compression_threshold" yaml:"compression_threshold" usage:"size in bytes from which values are compressed"`
+	KeyringPath          string   `json:"keyring_path" yaml:"keyring_path" usage:"keyring file of the keys encrypting values at rest (disabled when empty)"`
+	ReencryptInterval    Duration `json:"reencrypt_interval" yaml:"reencrypt_interval" usage:"interval between reloads of the keyring and re-encryptions of the values written with older keys"`
+	MaxBytes             int64    `json:"max_bytes" yaml:"max_bytes" usage:"size of the stored keys and values above which writes are rejected (unlimited when 0)"`
+	MaxKeys              int      `json:"max_keys" yaml:"max_keys" usage:"number of stored keys above which writes are rejected (unlimited when 0)"`
+	Shards               int      `json:"shards" yaml:"shards" usage:"number of independently locked shards of the store"`
+}
+
+// ClientOptions returns the KVS client options matching the settings
+func (s StorageConfig) ClientOptions() ([]kvs.Option, error) {
+	codec, err := kvs.CodecByName(s.Codec)
+	if err != nil {
+		return nil, fmt.Errorf("storage.codec: %w", err)
+	}
+	opts := []kvs.Option{kvs.WithCodec(codec), kvs.WithShards(s.Shards)}
+
+	compressor, err := kvs.CompressorByName(s.Compression)
+	if err != nil {
+		return nil, fmt.Errorf("storage.compression: %w", err)
+	}
+	if compressor != nil {
+		opts = append(opts, kvs.WithCompression(compressor, s.CompressionThreshold))
+	}
+	// The store is the primary copy of the fruits, so a full store rejects
+	// writes rather than evicting
+	if s.MaxBytes > 0 || s.MaxKeys > 0 {
+		opts = append(opts, kvs.WithQuota(kvs.Quota{MaxBytes: s.MaxBytes, MaxKeys: s.MaxKeys, Policy: kvs.NoEviction}))
+	}
+	return opts, nil
+}
+
+// HealthConfig holds the readiness check settings
+type HealthConfig struct {
+	CheckTimeout Duration `json:"check_timeout" yaml:"check_timeout" usage:"maximum duration of the readiness checks"`
+	MinFreeDisk  int64    `json:"min_free_disk" yaml:"min_free_disk" usage:"minimum free bytes on the snapshot filesystem to report ready"`
+}
+
+// APIConfig holds the API versioning settings
+type APIConfig struct {
+	MaxBodyBytes int64 `json:"max_body_bytes" yaml:"max_body_bytes" usage:"largest request body accepted, larger bodies get a 413"`
+	// Deprecations lists deprecated versions with their sunset date, e.g. "v1=2027-06-30"
+	Deprecations []string `json:"deprecations" yaml:"deprecations" usage:"comma-separated deprecated API versions with their sunset date, e.g. v1=2027-06-30"`
+	AdminToken   string   `json:"admin_token" yaml:"admin_token" secret:"true" usage:"bearer token of the /admin endpoints (disabled when empty)"`
+}
+
+// OutboxConfig holds the settings of the relay delivering the domain events
+// of the outbox
+type OutboxConfig struct {
+	PollInterval Duration `json:"poll_interval" yaml:"poll_interval" usage:"interval between reads of the outbox"`
+	MaxAttempts  int      `json:"max_attempts" yaml:"max_attempts" usage:"failed deliveries after which an event is moved to the dead letters"`
+	MinBackoff   Duration `json:"min_backoff" yaml:"min_backoff" usage:"delay before retrying a failed delivery, doubling with every attempt"`
+	MaxBackoff   Duration `json:"max_backoff" yaml:"max_backoff" usage:"longest delay between two deliveries of an event"`
+}
+
+// WebhooksConfig holds the settings of the dispatcher posting the domain
+// events to the webhooks
+type WebhooksConfig struct {
+	Workers      int      `json:"workers" yaml:"workers" usage:"number of deliveries attempted concurrently"`
+	PollInterval Duration `json:"poll_interval" yaml:"poll_interval" usage:"interval between reads of the delivery queue"`
+	Timeout      Duration `json:"timeout" yaml:"timeout" usage:"maximum duration of a delivery request"`
+	MaxAttempts  int      `json:"max_attempts" yaml:"max_attempts" usage:"failed attempts after which a delivery is given up"`
+	MinBackoff   Duration `json:"min_backoff" yaml:"min_backoff" usage:"delay before retrying a failed delivery, doubling with every attempt"`
+	MaxBackoff   Duration `json:"max_backoff" yaml:"max_backoff" usage:"longest delay between two attempts of a delivery"`
+	DisableAfter int      `json:"disable_after" yaml:"disable_after" usage:"consecutive failed attempts after which a webhook is disabled"`
+}
+
+// Sunsets returns the sunset date of every deprecated API version
+func (c APIConfig) Sunsets() (map[string]time.Time, error) {
+	sunsets := make(map[string]time.Time, len(c.Deprecations))
+	for _, d := range c.Deprecations {
+		version, date, ok := strings.Cut(d, "=")
+		if !ok || version == "" {
+			return nil, fmt.Errorf("api.deprecations entry %q must look like v1=2027-06-30", d)
+		}
+		sunset, err := time.Parse(time.DateOnly, date)
+		if err != nil {
+			return nil, fmt.Errorf("api.deprecations entry %q has an invalid date: %w", d, err)
+		}
+		sunsets[version] = sunset
+	}
+	return sunsets, nil
+}
+
+// Default returns the configuration used when nothing else is provided
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:              ":8080",
+			ReadTimeout:       Duration(15 * time.Second),
+			ReadHeaderTimeout: Duration(5 * time.Second),
+			WriteTimeout:      Duration(15 * time.Second),
+			IdleTimeout:       Duration(60 * time.Second),
+			ShutdownTimeout:   Duration(30 * time.Second),
+		},
+		Storage: StorageConfig{
+			SnapshotInterval:     Duration(time.Minute),
+			Codec:                "json",
+			Compression:          "none",
+			CompressionThreshold: kvs.DefaultCompressionThreshold,
+			ReencryptInterval:    Duration(time.Minute),
+			Shards:               kvs.DefaultShards,
+		},
+		Health: HealthConfig{
+			CheckTimeout: Duration(2 * time.Second),
+			MinFreeDisk:  64 << 20,
+		},
+		API: APIConfig{
+			MaxBodyBytes: 1 << 20,
+		},
+		Outbox: OutboxConfig{
+			PollInterval: Duration(200 * time.Millisecond),
+			MaxAttempts:  8,
+			MinBackoff:   Duration(time.Second),
+			MaxBackoff:   Duration(5 * time.Minute),
+		},
+		Webhooks: WebhooksConfig{
+			Workers:      4,
+			PollInterval: Duration(500 * time.Millisecond),
+			Timeout:      Duration(10 * time.Second),
+			MaxAttempts:  8,
+			MinBackoff:   Duration(time.Second),
+			MaxBackoff:   Duration(10 * time.Minute),
+			DisableAfter: 20,
+		},
+	}
+}
+
+// Validate checks that the configuration is usable
+func (c *Config) Validate() error {
+	var errs []error
+
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr cannot be empty"))
+	}
+	if c.Server.ReadTimeout < 0 || c.Server.ReadHeaderTimeout < 0 || c.Server.WriteTimeout < 0 || c.Server.IdleTimeout < 0 || c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("server timeouts cannot be negative"))
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("server.shutdown_timeout must be greater than 0"))
+	}
+	if c.Storage.SnapshotPath != "" && c.Storage.SnapshotInterval <= 0 {
+		errs = append(errs, errors.New("storage.snapshot_interval must be greater than 0 when snapshots are enabled"))
+	}
+	if _, err := c.Storage.ClientOptions(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Storage.CompressionThreshold < 0 {
+		errs = append(errs, errors.New("storage.compression_threshold cannot be negative"))
+	}
+	if c.Storage.Shards < 1 {
+		errs = append(errs, errors.New("storage.shards must be at least 1"))
+	}
+	if c.Storage.MaxBytes < 0 || c.Storage.MaxKeys < 0 {
+		errs = append(errs, errors.New("storage.max_bytes and storage.max_keys cannot be negative"))
+	}
+	if c.Storage.KeyringPath != "" && c.Storage.ReencryptInterval <= 0 {
+		errs = append(errs, errors.New("storage.reencrypt_interval must be greater than 0 when encryption is enabled"))
+	}
+	if c.Health.CheckTimeout <= 0 {
+		errs = append(errs, errors.New("health.check_timeout must be greater than 0"))
+	}
+	if c.Health.MinFreeDisk < 0 {
+		errs = append(errs, errors.New("health.min_free_disk cannot be negative"))
+	}
+	if c.API.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("api.max_body_bytes must be greater than 0"))
+	}
+	if _, err := c.API.Sunsets(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Outbox.PollInterval <= 0 || c.Outbox.MaxAttempts < 1 {
+		errs = append(errs, errors.New("outbox.poll_interval and outbox.max_attempts must be greater than 0"))
+	}
+	if c.Outbox.MinBackoff <= 0 || c.Outbox.MaxBackoff < c.Outbox.MinBackoff {
+		errs = append(errs, errors.New("outbox.min_backoff must be greater than 0 and at most outbox.max_backoff"))
+	}
+	if c.Webhooks.Workers < 1 || c.Webhooks.PollInterval <= 0 || c.Webhooks.Timeout <= 0 || c.Webhooks.MaxAttempts < 1 || c.Webhooks.DisableAfter < 1 {
+		errs = append(errs, errors.New("webhooks.workers, poll_interval, timeout, max_attempts and disable_after must be greater than 0"))
+	}
+	if c.Webhooks.MinBackoff <= 0 || c.Webhooks.MaxBackoff < c.Webhooks.MinBackoff {
+		errs = append(errs, errors.New("webhooks.min_backoff must be greater than 0 and at most webhooks.max_backoff"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Duration is a time.Duration that reads and writes as a string such as "15s"
+type Duration time.Duration
+
+// String returns the duration formatted like time.Duration
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+// Set parses a duration string, implementing the setter used by env vars and flags
+func (d *Duration) Set(s string) error {
+	v, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON encodes the duration as a string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON decodes the duration from a string
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"15s\": %w", err)
+	}
+	return d.Set(s)
+}
+
+// MarshalYAML encodes the duration as a string
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+// UnmarshalYAML decodes the duration from a string
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	return d.Set(node.Value)
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// envMap returns a lookupEnv function backed by a map
+func envMap(env map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := env[key]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Defaults(t *testing.T) {
+	cfg, opts, err := Load(nil, envMap(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cfg.Server.Addr != ":8080" {
+		t.Errorf("Expected addr %s, got %s", ":8080", cfg.Server.Addr)
+	}
+	if time.Duration(cfg.Server.ShutdownTimeout) != 30*time.Second {
+		t.Errorf("Expected shutdown timeout %s, got %s", 30*time.Second, cfg.Server.ShutdownTimeout)
+	}
+	if opts.PrintConfig {
+		t.Error("Expected print-config to be false")
+	}
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  addr: ":9000"
+  read_timeout: 3s
+  write_timeout: 4s
+storage:
+  snapshot_path: /tmp/from-file.json
+`)
+
+	env := map[string]string{
+		"FRUITS_CONFIG":               path,
+		"FRUITS_SERVER_READ_TIMEOUT":  "7s",
+		"FRUITS_SERVER_WRITE_TIMEOUT": "8s",
+	}
+	args := []string{"-server.write-timeout", "9s"}
+
+	cfg, opts, err := Load(args, envMap(env))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if opts.ConfigFile != path {
+		t.Errorf("Expected config file %s, got %s", path, opts.ConfigFile)
+	}
+	// File overrides defaults
+	if cfg.Server.Addr != ":9000" {
+		t.Errorf("Expected addr %s, got %s", ":9000", cfg.Server.Addr)
+	}
+	if cfg.Storage.SnapshotPath != "/tmp/from-file.json" {
+		t.Errorf("Expected snapshot path %s, got %s", "/tmp/from-file.json", cfg.Storage.SnapshotPath)
+	}
+	// Env overrides file
+	if time.Duration(cfg.Server.ReadTimeout) != 7*time.Second {
+		t.Errorf("Expected read timeout %s, got %s", 7*time.Second, cfg.Server.ReadTimeout)
+	}
+	// Flags override env
+	if time.Duration(cfg.Server.WriteTimeout) != 9*time.Second {
+		t.Errorf("Expected write timeout %s, got %s", 9*time.Second, cfg.Server.WriteTimeout)
+	}
+}
+
+func TestLoad_JSONFile(t *testing.T) {
+	path := writeFile(t, "config.json", `{"server": {"addr": "unix:/tmp/fruits.sock"}}`)
+
+	cfg, _, err := Load([]string{"-config", path}, envMap(nil))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if cfg.Server.Addr != "unix:/tmp/fruits.sock" {
+		t.Errorf("Expected addr %s, got %s", "unix:/tmp/fruits.sock", cfg.Server.Addr)
+	}
+}
+
+func TestLoad_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		file string
+	}{
+		{
+			name: "UnknownFileKey",
+			file: "server:\n  port: 8080\n",
+		},
+		{
+			name: "InvalidEnvDuration",
+			env:  map[string]string{"FRUITS_SERVER_IDLE_TIMEOUT": "soon"},
+		},
+		{
+			name: "UnknownFlag",
+			args: []string{"-port", "8080"},
+		},
+		{
+			name: "ValidationFailure",
+			args: []string{"-server.shutdown-timeout", "0s"},
+		},
+		{
+			name: "UnknownCompression",
+			env:  map[string]string{"FRUITS_STORAGE_COMPRESSION": "lz4"},
+		},
+		{
+			name: "NegativeCompressionThreshold",
+			args: []string{"-storage.compression", "zstd", "-storage.compression-threshold", "-1"},
+		},
+		{
+			name: "NoShards",
+			args: []string{"-storage.shards", "0"},
+		},
+		{
+			name: "NegativeMaxKeys",
+			env:  map[string]string{"FRUITS_STORAGE_MAX_KEYS": "-1"},
+		},
+		{
+			name: "EncryptionWithoutReencryptInterval",
+			args: []string{"-storage.keyring-path", "keyring.json", "-storage.reencrypt-interval", "0s"},
+		},
+		{
+			name: "OutboxBackoffInverted",
+			args: []string{"-outbox.min-backoff", "10m", "-outbox.max-backoff", "1m"},
+		},
+		{
+			name: "NoWebhookWorkers",
+			env:  map[string]string{"FRUITS_WEBHOOKS_WORKERS": "0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append(args, "-config", writeFile(t, "config.yaml", tt.file))
+			}
+
+			_, _, err := Load(args, envMap(tt.env))
+
+			if err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
+
+func TestConfig_Print(t *testing.T) {
+	cfg := Default()
+	var buf bytes.Buffer
+
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.Contains(buf.String(), "shutdown_timeout: 30s") {
+		t.Errorf("Expected printed config to contain shutdown_timeout, got %s", buf.String())
+	}
+}
+
+func TestConfig_PrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.API.AdminToken = "s3cret"
+	var buf bytes.Buffer
+
+	if err := cfg.Print(&buf); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if strings.Contains(buf.String(), "s3cret") || !strings.Contains(buf.String(), "admin_token: '[REDACTED]'") {
+		t.Errorf("Expected the admin token to be redacted, got %s", buf.String())
+	}
+	if cfg.API.AdminToken != "s3cret" {
+		t.Errorf("Expected the configuration to keep the token, got %s", cfg.API.AdminToken)
+	}
+}
@@ -0,0 +1,244 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of every environment variable read by Load
+const EnvPrefix = "FRUITS_"
+
+// redactedValue replaces secret values when printing the configuration
+const redactedValue = "[REDACTED]"
+
+// Options holds the command-line switches that are not part of Config
+type Options struct {
+	// ConfigFile is the YAML or JSON file read on top of the defaults
+	ConfigFile string
+	// PrintConfig asks to print the effective configuration and exit
+	PrintConfig bool
+}
+
+// field describes a configurable leaf of Config
+type field struct {
+	path  string
+	flag  string
+	env   string
+	usage string
+	index []int
+}
+
+// Load builds the configuration from the defaults, then the config file,
+// then FRUITS_* environment variables, then command-line flags, each layer
+// overriding the previous one, and validates the result
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, Options, error) {
+	var opts Options
+	fields := configFields()
+
+	fs := flag.NewFlagSet("fruitsapi", flag.ContinueOnError)
+	fs.StringVar(&opts.ConfigFile, "config", "", "YAML or JSON configuration file (env "+EnvPrefix+"CONFIG)")
+	fs.BoolVar(&opts.PrintConfig, "print-config", false, "print the effective configuration with secrets redacted and exit")
+
+	// Flags are only recorded here and applied once the lower layers are loaded
+	type flagValue struct {
+		field field
+		value string
+	}
+	var setFlags []flagValue
+	for _, f := range fields {
+		f := f
+		fs.Func(f.flag, f.usage+" (env "+f.env+")", func(s string) error {
+			setFlags = append(setFlags, flagValue{field: f, value: s})
+			return nil
+		})
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, opts, err
+	}
+
+	cfg := Default()
+
+	if opts.ConfigFile == "" {
+		opts.ConfigFile, _ = lookupEnv(EnvPrefix + "CONFIG")
+	}
+	if opts.ConfigFile != "" {
+		if err := loadFile(cfg, opts.ConfigFile); err != nil {
+			return nil, opts, err
+		}
+	}
+
+	for _, f := range fields {
+		if v, ok := lookupEnv(f.env); ok {
+			if err := setField(cfg, f, v); err != nil {
+				return nil, opts, fmt.Errorf("invalid value for %s: %w", f.env, err)
+			}
+		}
+	}
+
+	for _, fv := range setFlags {
+		if err := setField(cfg, fv.field, fv.value); err != nil {
+			return nil, opts, fmt.Errorf("invalid value for -%s: %w", fv.field.flag, err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, opts, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	return cfg, opts, nil
+}
+
+// Print writes the configuration as YAML with secret fields redacted
+func (c *Config) Print(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return fmt.Errorf("error printing configuration: %w", err)
+	}
+	return enc.Close()
+}
+
+// Redacted returns a copy of the configuration where every field tagged
+// with `secret:"true"` is masked
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	redact(reflect.ValueOf(&redacted).Elem())
+	return &redacted
+}
+
+func redact(v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			redact(fv)
+			continue
+		}
+		if t.Field(i).Tag.Get("secret") != "true" {
+			continue
+		}
+		switch fv.Kind() {
+		case reflect.String:
+			if fv.String() != "" {
+				fv.SetString(redactedValue)
+			}
+		case reflect.Slice:
+			if fv.Len() > 0 {
+				fv.Set(reflect.ValueOf([]string{redactedValue}))
+			}
+		}
+	}
+}
+
+// loadFile decodes a YAML or JSON file on top of cfg, rejecting unknown keys
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return fmt.Errorf("unsupported config file extension %q, use .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// configFields lists the leaves of Config with their flag and env names,
+// derived from the json tags: server.read_timeout becomes the flag
+// -server.read-timeout and the env var FRUITS_SERVER_READ_TIMEOUT
+func configFields() []field {
+	var fields []field
+	var walk func(t reflect.Type, prefix string, index []int)
+	walk = func(t reflect.Type, prefix string, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			name := strings.Split(sf.Tag.Get("json"), ",")[0]
+			path := prefix + name
+			idx := append(append([]int{}, index...), i)
+
+			if sf.Type.Kind() == reflect.Struct {
+				walk(sf.Type, path+".", idx)
+				continue
+			}
+
+			fields = append(fields, field{
+				path:  path,
+				flag:  strings.ReplaceAll(path, "_", "-"),
+				env:   EnvPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_")),
+				usage: sf.Tag.Get("usage"),
+				index: idx,
+			})
+		}
+	}
+	walk(reflect.TypeOf(Config{}), "", nil)
+	return fields
+}
+
+// setField parses s into the field of cfg described by f
+func setField(cfg *Config, f field, s string) error {
+	v := reflect.ValueOf(cfg).Elem().FieldByIndex(f.index)
+
+	if setter, ok := v.Addr().Interface().(interface{ Set(string) error }); ok {
+		return setter.Set(s)
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// Operations recorded in the audit log
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// nullJSON is the value of the fields of a fruit that does not exist
+var nullJSON = json.RawMessage("null")
+
+// FieldChange is a field of a fruit before and after a change, as in its
+// JSON representation, null when the fruit did not exist
+type FieldChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// AuditEntry records a change of a fruit. Each entry holds the hash of the
+// previous one, so that altering, removing or inserting an entry breaks
+// the chain.
+type AuditEntry struct {
+	// Seq numbers the entries from 1, without gaps
+	Seq uint64 `json:"seq"`
+	// Actor is who made the change, and RequestID the request making it
+	Actor     string        `json:"actor"`
+	RequestID string        `json:"request_id"`
+	At        time.Time     `json:"at"`
+	Operation string        `json:"operation"`
+	FruitID   string        `json:"fruit_id"`
+	Changes   []FieldChange `json:"changes"`
+	// PrevHash is the Hash of the previous entry, empty for the first one
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
+}
+
+// NewAuditEntry creates the entry of a change of a fruit from before to
+// after, before being nil for a creation and after nil for a deletion. The
+// entry is chained when it is stored.
+func NewAuditEntry(actor, requestID, operation string, before, after *Fruit) (AuditEntry, error) {
+	changes, err := DiffFruits(before, after)
+	if err != nil {
+		return AuditEntry{}, err
+	}
+	fruitID := ""
+	if after != nil {
+		fruitID = after.ID
+	} else if before != nil {
+		fruitID = before.ID
+	}
+	return AuditEntry{
+		Actor:     actor,
+		RequestID: requestID,
+		At:        time.Now().UTC(),
+		Operation: operation,
+		FruitID:   fruitID,
+		Changes:   changes,
+	}, nil
+}
+
+// DiffFruits returns the fields that differ between before and after, by
+// name, either of them being nil when the fruit does not exist
+func DiffFruits(before, after *Fruit) ([]FieldChange, error) {
+	b, err := fruitFields(before)
+	if err != nil {
+		return nil, err
+	}
+	a, err := fruitFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(a)+len(b))
+	for name := range b {
+		names = append(names, name)
+	}
+	for name := range a {
+		if _, ok := b[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	changes := []FieldChange{}
+	for _, name := range names {
+		from, ok := b[name]
+		if !ok {
+			from = nullJSON
+		}
+		to, ok := a[name]
+		if !ok {
+			to = nullJSON
+		}
+		if !bytes.Equal(from, to) {
+			changes = append(changes, FieldChange{Field: name, Before: from, After: to})
+		}
+	}
+	return changes, nil
+}
+
+// fruitFields returns the JSON fields of fruit, none when it is nil
+func fruitFields(fruit *Fruit) (map[string]json.RawMessage, error) {
+	if fruit == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(fruit)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding fruit %s: %w", fruit.ID, err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("error decoding fruit %s: %w", fruit.ID, err)
+	}
+	return fields, nil
+}
+
+// ComputeHash returns the hex SHA-256 of the JSON of the entry without its
+// Hash, PrevHash included
+func (e AuditEntry) ComputeHash() (string, error) {
+	e.Hash = ""
+	e.At = e.At.UTC()
+	if e.Changes == nil {
+		e.Changes = []FieldChange{}
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("error encoding audit entry %d: %w", e.Seq, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
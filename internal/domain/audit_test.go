@@ -0,0 +1,85 @@
+package domain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiffFruits(t *testing.T) {
+	fruit := &Fruit{ID: "1", Name: "manzana", Quantity: 12, Price: 1000, Owner: "test", Status: "comestible"}
+	updated := *fruit
+	updated.Quantity = 5
+	updated.Status = "podrida"
+	all := "date_created:null>\"0001-01-01T00:00:00Z\",date_last_updated:null>\"0001-01-01T00:00:00Z\",id:null>\"1\"," +
+		"name:null>\"manzana\",owner:null>\"test\",price:null>1000,quantity:null>12,status:null>\"comestible\""
+
+	tests := []struct {
+		name     string
+		before   *Fruit
+		after    *Fruit
+		expected string
+	}{
+		{name: "Create", after: fruit, expected: all},
+		{name: "Update", before: fruit, after: &updated, expected: `quantity:12>5,status:"comestible">"podrida"`},
+		{name: "Delete", before: &updated, expected: `date_created:"0001-01-01T00:00:00Z">null,date_last_updated:"0001-01-01T00:00:00Z">null,id:"1">null,` +
+			`name:"manzana">null,owner:"test">null,price:1000>null,quantity:5>null,status:"podrida">null`},
+		{name: "NoChange", before: fruit, after: fruit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changes, err := DiffFruits(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			// Fields sorted by name
+			got := make([]string, len(changes))
+			for i, c := range changes {
+				got[i] = c.Field + ":" + string(c.Before) + ">" + string(c.After)
+			}
+			if strings.Join(got, ",") != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, strings.Join(got, ","))
+			}
+		})
+	}
+}
+
+func TestAuditEntryComputeHash(t *testing.T) {
+	entry, err := NewAuditEntry("owner:test", "req-1", AuditCreate, nil, NewFruit("1", "manzana", 12, 1000, "test"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	hash, err := entry.ComputeHash()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		change func(e *AuditEntry)
+		same   bool
+	}{
+		{name: "Hash", change: func(e *AuditEntry) { e.Hash = "other" }, same: true},
+		{name: "Actor", change: func(e *AuditEntry) { e.Actor = "owner:other" }},
+		{name: "PrevHash", change: func(e *AuditEntry) { e.PrevHash = "other" }},
+		{name: "Seq", change: func(e *AuditEntry) { e.Seq++ }},
+		{name: "Change", change: func(e *AuditEntry) { e.Changes[0].After = []byte(`"other"`) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := entry
+			changed.Changes = append([]FieldChange(nil), entry.Changes...)
+			tt.change(&changed)
+
+			got, err := changed.ComputeHash()
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if (got == hash) != tt.same {
+				t.Errorf("Expected the same hash to be %v, got %s and %s", tt.same, hash, got)
+			}
+		})
+	}
+}
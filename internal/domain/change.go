@@ -0,0 +1,29 @@
+package domain
+
+// ChangeType is the kind of a FruitChange
+type ChangeType string
+
+const (
+	// ChangeCreated is a new fruit
+	ChangeCreated ChangeType = "created"
+	// ChangeUpdated is a fruit updated without changing its quantity
+	ChangeUpdated ChangeType = "updated"
+	// ChangeStockChanged is a fruit updated with a new quantity
+	ChangeStockChanged ChangeType = "stock_changed"
+	// ChangeDeleted is a fruit removed
+	ChangeDeleted ChangeType = "deleted"
+	// ChangeLagged is the last change sent to a watcher that fell too far
+	// behind. Its Revision is the last one received, to watch again from.
+	ChangeLagged ChangeType = "lagged"
+)
+
+// FruitChange is a change of a stored fruit
+type FruitChange struct {
+	// Revision orders the changes of every fruit, watchers resume after it
+	Revision uint64
+	Type     ChangeType
+	// Fruit is the fruit after the change, or before it when deleted
+	Fruit *Fruit
+	// Previous is the fruit before an update, nil otherwise
+	Previous *Fruit
+}
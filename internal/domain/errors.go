@@ -0,0 +1,29 @@
+package domain
+
+import "errors"
+
+var (
+	// ErrFruitNotFound is returned when no fruit has the requested ID
+	ErrFruitNotFound = errors.New("fruit not found")
+	// ErrInvalidFruit is returned when a fruit breaks a validation rule
+	ErrInvalidFruit = errors.New("invalid fruit")
+	// ErrInvalidFruitID is returned when a fruit ID is not a UUID
+	ErrInvalidFruitID = errors.New("fruit ID must be a UUID")
+	// ErrImmutableField is returned when an update changes a field that is
+	// fixed at creation time
+	ErrImmutableField = errors.New("field is immutable")
+	// ErrStorageFull is returned when a write does not fit the storage quota
+	ErrStorageFull = errors.New("storage is full")
+	// ErrChangesExpired is returned when resuming a watch from a revision
+	// whose changes are no longer kept, the fruits must be reloaded
+	ErrChangesExpired = errors.New("changes are no longer available")
+	// ErrOutboxEntryNotFound is returned when no outbox entry has the
+	// requested ID
+	ErrOutboxEntryNotFound = errors.New("outbox entry not found")
+	// ErrWebhookNotFound is returned when the owner has no webhook with the
+	// requested ID
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrAuditChainBroken is returned when an audit entry does not match the
+	// hashes chaining it to the others
+	ErrAuditChainBroken = errors.New("audit chain is broken")
+)
@@ -0,0 +1,104 @@
+package domain
+
+import "time"
+
+// Names of the domain events
+const (
+	EventFruitCreated  = "fruit.created"
+	EventFruitUpdated  = "fruit.updated"
+	EventStockAdjusted = "fruit.stock_adjusted"
+	EventStatusChanged = "fruit.status_changed"
+	EventFruitDeleted  = "fruit.deleted"
+)
+
+// Event is a fact raised by a change of a fruit, the aggregate
+type Event interface {
+	// EventName is one of the Event* names
+	EventName() string
+	// AggregateID is the ID of the fruit changed
+	AggregateID() string
+	// AggregateOwner is the owner of the fruit changed
+	AggregateOwner() string
+	// OccurredAt is the time of the change
+	OccurredAt() time.Time
+}
+
+// FruitCreated is raised when a fruit is created
+type FruitCreated struct {
+	Fruit Fruit     `json:"fruit"`
+	At    time.Time `json:"at"`
+}
+
+// FruitUpdated is raised when a fruit is updated, along with StockAdjusted
+// and StatusChanged when they apply
+type FruitUpdated struct {
+	Previous Fruit     `json:"previous"`
+	Fruit    Fruit     `json:"fruit"`
+	At       time.Time `json:"at"`
+}
+
+// StockAdjusted is raised when the quantity of a fruit changes
+type StockAdjusted struct {
+	FruitID  string    `json:"fruit_id"`
+	Owner    string    `json:"owner"`
+	Previous int       `json:"previous"`
+	Quantity int       `json:"quantity"`
+	At       time.Time `json:"at"`
+}
+
+// StatusChanged is raised when the status of a fruit changes
+type StatusChanged struct {
+	FruitID  string    `json:"fruit_id"`
+	Owner    string    `json:"owner"`
+	Previous string    `json:"previous"`
+	Status   string    `json:"status"`
+	At       time.Time `json:"at"`
+}
+
+// FruitDeleted is raised when a fruit is deleted
+type FruitDeleted struct {
+	Fruit Fruit     `json:"fruit"`
+	At    time.Time `json:"at"`
+}
+
+func (e FruitCreated) EventName() string      { return EventFruitCreated }
+func (e FruitCreated) AggregateID() string    { return e.Fruit.ID }
+func (e FruitCreated) AggregateOwner() string { return e.Fruit.Owner }
+func (e FruitCreated) OccurredAt() time.Time  { return e.At }
+
+func (e FruitUpdated) EventName() string      { return EventFruitUpdated }
+func (e FruitUpdated) AggregateID() string    { return e.Fruit.ID }
+func (e FruitUpdated) AggregateOwner() string { return e.Fruit.Owner }
+func (e FruitUpdated) OccurredAt() time.Time  { return e.At }
+
+func (e StockAdjusted) EventName() string      { return EventStockAdjusted }
+func (e StockAdjusted) AggregateID() string    { return e.FruitID }
+func (e StockAdjusted) AggregateOwner() string { return e.Owner }
+func (e StockAdjusted) OccurredAt() time.Time  { return e.At }
+
+func (e StatusChanged) EventName() string      { return EventStatusChanged }
+func (e StatusChanged) AggregateID() string    { return e.FruitID }
+func (e StatusChanged) AggregateOwner() string { return e.Owner }
+func (e StatusChanged) OccurredAt() time.Time  { return e.At }
+
+func (e FruitDeleted) EventName() string      { return EventFruitDeleted }
+func (e FruitDeleted) AggregateID() string    { return e.Fruit.ID }
+func (e FruitDeleted) AggregateOwner() string { return e.Fruit.Owner }
+func (e FruitDeleted) OccurredAt() time.Time  { return e.At }
+
+// EventNames lists the names of every domain event
+var EventNames = []string{EventFruitCreated, EventFruitUpdated, EventStockAdjusted, EventStatusChanged, EventFruitDeleted}
+
+// UpdateEvents returns the events raised by the update of a fruit from
+// previous to fruit
+func UpdateEvents(previous, fruit Fruit) []Event {
+	at := fruit.DateLastUpdated
+	events := []Event{FruitUpdated{Previous: previous, Fruit: fruit, At: at}}
+	if fruit.Quantity != previous.Quantity {
+		events = append(events, StockAdjusted{FruitID: fruit.ID, Owner: fruit.Owner, Previous: previous.Quantity, Quantity: fruit.Quantity, At: at})
+	}
+	if fruit.Status != previous.Status {
+		events = append(events, StatusChanged{FruitID: fruit.ID, Owner: fruit.Owner, Previous: previous.Status, Status: fruit.Status, At: at})
+	}
+	return events
+}
@@ -3,9 +3,28 @@ package domain
 import (
 	"errors"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
+)
+
+// Statuses of a fruit, new fruits being comestible
+const (
+	StatusComestible = "comestible"
+	StatusPodrida    = "podrida"
 )
 
+// FruitStatuses lists the statuses a fruit can have
+var FruitStatuses = []string{StatusComestible, StatusPodrida}
+
+// NamePattern is the regular expression fruit names match: letters and
+// spaces only
+const NamePattern = `^[a-zA-Z\s]+$`
+
+var namePattern = regexp.MustCompile(NamePattern)
+
 // Fruit represents a fruit item in our inventory
 type Fruit struct {
 	ID             string    `json:"id"`
@@ -24,7 +43,6 @@ func (f *Fruit) Validate() error {
 	if f.Name == "" {
 		return errors.New("name cannot be empty")
 	}
-	namePattern := regexp.MustCompile(`^[a-zA-Z\s]+$`)
 	if !namePattern.MatchString(f.Name) {
 		return errors.New("name must contain only letters and spaces")
 	}
@@ -44,6 +62,11 @@ func (f *Fruit) Validate() error {
 		return errors.New("owner cannot be empty")
 	}
 
+	// Status validation: must be one of the fruit statuses
+	if !slices.Contains(FruitStatuses, f.Status) {
+		return errors.New("status must be one of " + strings.Join(FruitStatuses, ", "))
+	}
+
 	return nil
 }
 
@@ -58,6 +81,18 @@ func NewFruit(id, name string, quantity int, price float64, owner string) *Fruit
 		DateCreated:    now,
 		DateLastUpdated: now,
 		Owner:          owner,
-		Status:         "comestible", // Default status
+		Status:         StatusComestible, // Default status
+	}
+}
+
+// ValidateFruitID checks that id is a UUID in the canonical form given to
+// new fruits
+func ValidateFruitID(id string) error {
+	if len(id) != 36 {
+		return ErrInvalidFruitID
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		return ErrInvalidFruitID
 	}
+	return nil
 }
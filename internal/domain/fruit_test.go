@@ -18,9 +18,32 @@ func TestFruitValidate(t *testing.T) {
 				Quantity: 12,
 				Price:    1000,
 				Owner:    "test",
+				Status:   "comestible",
 			},
 			expectError: false,
 		},
+		{
+			name: "TestRottenFruit",
+			fruit: Fruit{
+				Name:     "manzana",
+				Quantity: 12,
+				Price:    1000,
+				Owner:    "test",
+				Status:   "podrida",
+			},
+			expectError: false,
+		},
+		{
+			name: "TestUnknownStatus",
+			fruit: Fruit{
+				Name:     "manzana",
+				Quantity: 12,
+				Price:    1000,
+				Owner:    "test",
+				Status:   "madura",
+			},
+			expectError: true,
+		},
 		{
 			name: "TestEmptyName",
 			fruit: Fruit{
@@ -158,3 +181,27 @@ func TestNewFruit(t *testing.T) {
 		t.Errorf("NewFruit() DateLastUpdated is too far from current time")
 	}
 }
+
+func TestValidateFruitID(t *testing.T) {
+	tests := []struct {
+		name        string
+		id          string
+		expectError bool
+	}{
+		{name: "TestUUID", id: "0b8f9c4e-6d0a-4f4e-9a53-2f5c1d3e7a10", expectError: false},
+		{name: "TestEmpty", id: "", expectError: true},
+		{name: "TestNotUUID", id: "manzana", expectError: true},
+		{name: "TestOtherRecordKey", id: "audit/head", expectError: true},
+		{name: "TestBracedUUID", id: "{0b8f9c4e-6d0a-4f4e-9a53-2f5c1d3e7a10}", expectError: true},
+		{name: "TestUUIDWithoutHyphens", id: "0b8f9c4e6d0a4f4e9a532f5c1d3e7a10", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFruitID(tt.id)
+			if (err != nil) != tt.expectError {
+				t.Errorf("ValidateFruitID() error = %v, expectError %v", err, tt.expectError)
+			}
+		})
+	}
+}
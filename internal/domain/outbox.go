@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OutboxEntry is a domain event stored in the same transaction as the
+// change raising it, until the relay has delivered it
+type OutboxEntry struct {
+	// ID orders the entries by the time they were recorded
+	ID          string          `json:"id"`
+	Event       string          `json:"event"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	RecordedAt  time.Time       `json:"recorded_at"`
+	// Attempts counts the failed deliveries, the next one being due at
+	// NextAttempt
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// NewOutboxEntry records e in an entry to deliver now
+func NewOutboxEntry(id string, e Event) (OutboxEntry, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return OutboxEntry{}, fmt.Errorf("error encoding event %s: %w", e.EventName(), err)
+	}
+	now := time.Now()
+	return OutboxEntry{
+		ID:          id,
+		Event:       e.EventName(),
+		AggregateID: e.AggregateID(),
+		Payload:     payload,
+		RecordedAt:  now,
+		NextAttempt: now,
+	}, nil
+}
+
+// DecodeEvent returns the event recorded in the entry
+func (o OutboxEntry) DecodeEvent() (Event, error) {
+	var e Event
+	var err error
+	switch o.Event {
+	case EventFruitCreated:
+		e, err = decodeEvent[FruitCreated](o.Payload)
+	case EventFruitUpdated:
+		e, err = decodeEvent[FruitUpdated](o.Payload)
+	case EventStockAdjusted:
+		e, err = decodeEvent[StockAdjusted](o.Payload)
+	case EventStatusChanged:
+		e, err = decodeEvent[StatusChanged](o.Payload)
+	case EventFruitDeleted:
+		e, err = decodeEvent[FruitDeleted](o.Payload)
+	default:
+		return nil, fmt.Errorf("unknown event %q", o.Event)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding event %s: %w", o.Event, err)
+	}
+	return e, nil
+}
+
+func decodeEvent[T Event](payload []byte) (Event, error) {
+	var e T
+	err := json.Unmarshal(payload, &e)
+	return e, err
+}
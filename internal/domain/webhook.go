@@ -0,0 +1,161 @@
+package domain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MinWebhookSecretLength is the shortest secret accepted to sign the
+// deliveries of a webhook
+const MinWebhookSecretLength = 16
+
+// Statuses of a webhook delivery
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook is the subscription of an owner to the events of its fruits,
+// posted to URL and signed with Secret
+type Webhook struct {
+	ID     string   `json:"id"`
+	Owner  string   `json:"owner"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret"`
+	// Active is cleared after too many consecutive failed attempts
+	Active              bool      `json:"active"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	DisabledAt          time.Time `json:"disabled_at"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// NewWebhook creates an active webhook
+func NewWebhook(id, owner, rawURL string, events []string, secret string) *Webhook {
+	return &Webhook{
+		ID:        id,
+		Owner:     owner,
+		URL:       rawURL,
+		Events:    events,
+		Secret:    secret,
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+}
+
+// Validate checks that the webhook can be delivered
+func (w *Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return errors.New("url must not point to the loopback interface")
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if err := CheckWebhookAddress(ip); err != nil {
+			return fmt.Errorf("url must point to a public address: %w", err)
+		}
+	}
+	if len(w.Events) == 0 {
+		return errors.New("events cannot be empty")
+	}
+	for _, name := range w.Events {
+		if !slices.Contains(EventNames, name) {
+			return fmt.Errorf("unknown event %q", name)
+		}
+	}
+	if len(w.Secret) < MinWebhookSecretLength {
+		return fmt.Errorf("secret must be at least %d characters long", MinWebhookSecretLength)
+	}
+	if w.Owner == "" {
+		return errors.New("owner cannot be empty")
+	}
+	return nil
+}
+
+// CheckWebhookAddress returns an error unless ip is a public unicast
+// address, so that webhooks cannot reach the loopback interface, the local
+// link or the private networks of the server
+func CheckWebhookAddress(ip netip.Addr) error {
+	ip = ip.Unmap()
+	switch {
+	case ip.IsLoopback():
+		return fmt.Errorf("%s is a loopback address", ip)
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast():
+		return fmt.Errorf("%s is a link-local address", ip)
+	case ip.IsPrivate():
+		return fmt.Errorf("%s is a private address", ip)
+	case ip.IsUnspecified():
+		return fmt.Errorf("%s is the unspecified address", ip)
+	case ip.IsMulticast():
+		return fmt.Errorf("%s is a multicast address", ip)
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(ip) {
+			return fmt.Errorf("%s is a reserved address", ip)
+		}
+	}
+	return nil
+}
+
+// reservedPrefixes are the networks that are neither public nor caught by
+// the netip predicates: "this network", the shared address space of
+// carrier-grade NAT, and NAT64, which reaches IPv4 addresses through IPv6
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// Wants reports whether the webhook is active and subscribed to e
+func (w *Webhook) Wants(e Event) bool {
+	return w.Active && e.AggregateOwner() == w.Owner && slices.Contains(w.Events, e.EventName())
+}
+
+// WebhookDelivery is an event to post to a webhook, with the outcome of
+// its attempts
+type WebhookDelivery struct {
+	// ID orders the deliveries by the time they were queued
+	ID        string          `json:"id"`
+	WebhookID string          `json:"webhook_id"`
+	Event     string          `json:"event"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+	// Status is DeliveryPending until the event is accepted or given up
+	Status      string    `json:"status"`
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	// StatusCode and LastError describe the last attempt, StatusCode being
+	// 0 when no response was received
+	StatusCode  int       `json:"status_code"`
+	LastError   string    `json:"last_error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// NewWebhookDelivery creates the pending delivery of e to a webhook, the
+// ID being given when it is queued
+func NewWebhookDelivery(webhookID string, e Event) (WebhookDelivery, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return WebhookDelivery{}, fmt.Errorf("error encoding event %s: %w", e.EventName(), err)
+	}
+	now := time.Now()
+	return WebhookDelivery{
+		WebhookID:   webhookID,
+		Event:       e.EventName(),
+		Payload:     payload,
+		CreatedAt:   now,
+		Status:      DeliveryPending,
+		NextAttempt: now,
+	}, nil
+}
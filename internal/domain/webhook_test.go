@@ -0,0 +1,155 @@
+package domain
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		webhook     *Webhook
+		expectError bool
+	}{
+		{
+			name:    "Valid",
+			webhook: NewWebhook("1", "test", "https://example.com/hook", []string{EventFruitCreated, EventStockAdjusted}, "0123456789abcdef"),
+		},
+		{
+			name:        "RelativeURL",
+			webhook:     NewWebhook("1", "test", "/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "UnsupportedScheme",
+			webhook:     NewWebhook("1", "test", "ftp://example.com/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:    "PublicAddress",
+			webhook: NewWebhook("1", "test", "http://93.184.216.34:8080/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+		},
+		{
+			name:        "Localhost",
+			webhook:     NewWebhook("1", "test", "http://localhost:8080/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "LoopbackAddress",
+			webhook:     NewWebhook("1", "test", "http://127.0.0.1:8080/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "LoopbackIPv6",
+			webhook:     NewWebhook("1", "test", "http://[::1]/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "MappedLoopback",
+			webhook:     NewWebhook("1", "test", "http://[::ffff:127.0.0.1]/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "LinkLocalAddress",
+			webhook:     NewWebhook("1", "test", "http://169.254.169.254/latest/meta-data", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "PrivateAddress",
+			webhook:     NewWebhook("1", "test", "https://10.0.0.1/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "UnspecifiedAddress",
+			webhook:     NewWebhook("1", "test", "http://0.0.0.0/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "NoEvents",
+			webhook:     NewWebhook("1", "test", "https://example.com/hook", nil, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "UnknownEvent",
+			webhook:     NewWebhook("1", "test", "https://example.com/hook", []string{"fruit.eaten"}, "0123456789abcdef"),
+			expectError: true,
+		},
+		{
+			name:        "ShortSecret",
+			webhook:     NewWebhook("1", "test", "https://example.com/hook", []string{EventFruitCreated}, "short"),
+			expectError: true,
+		},
+		{
+			name:        "NoOwner",
+			webhook:     NewWebhook("1", "", "https://example.com/hook", []string{EventFruitCreated}, "0123456789abcdef"),
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.webhook.Validate()
+			if tt.expectError && err == nil {
+				t.Error("Expected error, got nil")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookWants(t *testing.T) {
+	webhook := NewWebhook("1", "test", "https://example.com/hook", []string{EventStockAdjusted}, "0123456789abcdef")
+	disabled := *webhook
+	disabled.Active = false
+
+	tests := []struct {
+		name    string
+		webhook *Webhook
+		event   Event
+		want    bool
+	}{
+		{name: "Subscribed", webhook: webhook, event: StockAdjusted{FruitID: "a", Owner: "test"}, want: true},
+		{name: "OtherEvent", webhook: webhook, event: FruitCreated{Fruit: Fruit{ID: "a", Owner: "test"}}},
+		{name: "OtherOwner", webhook: webhook, event: StockAdjusted{FruitID: "a", Owner: "other"}},
+		{name: "Disabled", webhook: &disabled, event: StockAdjusted{FruitID: "a", Owner: "test"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.webhook.Wants(tt.event); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCheckWebhookAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		addr    string
+		wantErr bool
+	}{
+		{name: "Public IPv4", addr: "93.184.216.34"},
+		{name: "Public IPv6", addr: "2606:2800:220:1::1"},
+		{name: "Loopback", addr: "127.0.0.1", wantErr: true},
+		{name: "Mapped loopback", addr: "::ffff:127.0.0.1", wantErr: true},
+		{name: "Private", addr: "10.1.2.3", wantErr: true},
+		{name: "Link-local", addr: "169.254.169.254", wantErr: true},
+		{name: "Unspecified", addr: "::", wantErr: true},
+		{name: "This network", addr: "0.1.2.3", wantErr: true},
+		{name: "Carrier-grade NAT", addr: "100.100.1.1", wantErr: true},
+		{name: "NAT64", addr: "64:ff9b::7f00:1", wantErr: true},
+		{name: "Local NAT64", addr: "64:ff9b:1::a00:1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckWebhookAddress(netip.MustParseAddr(tt.addr))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+// Package events delivers the domain events raised by the service to the
+// subsystems reacting to them, such as metrics, webhooks and the audit log
+package events
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"slices"
+	"sync"
+
+	"fruitsapi/internal/domain"
+)
+
+var (
+	// publishedTotal counts the events published by name
+	publishedTotal = expvar.NewMap("events_published_total")
+	// handlerFailuresTotal counts the errors and panics of handlers by
+	// subscriber
+	handlerFailuresTotal = expvar.NewMap("events_handler_failures_total")
+)
+
+// ErrClosed is returned when delivering an event after Close
+var ErrClosed = errors.New("event bus is closed")
+
+// Handler handles an event. Errors and panics are logged and counted, they
+// never reach the other subscribers, and only the caller of Deliver learns
+// about those of synchronous subscribers.
+type Handler func(ctx context.Context, e domain.Event) error
+
+// SubscribeOption configures a subscriber
+type SubscribeOption func(*subscriber)
+
+// Async delivers the events from workers goroutines instead of the one of
+// the publisher, each with a queue of queue events. The events of a fruit
+// always go to the same worker, so that they are handled in order. Publish
+// waits while the queue of a worker is full.
+func Async(workers, queue int) SubscribeOption {
+	return func(s *subscriber) {
+		s.queues = make([]chan delivery, max(workers, 1))
+		for i := range s.queues {
+			s.queues[i] = make(chan delivery, max(queue, 0))
+		}
+	}
+}
+
+// Only delivers the events with one of the given names
+func Only(names ...string) SubscribeOption {
+	return func(s *subscriber) {
+		s.names = names
+	}
+}
+
+// subscriber is a handler with its delivery options
+type subscriber struct {
+	name    string
+	handler Handler
+	names   []string
+	// queues feed the workers of an async subscriber, nil when synchronous.
+	// They are closed under mu, once the subscription is cancelled.
+	queues []chan delivery
+	mu     sync.RWMutex
+	closed bool
+}
+
+// delivery is an event queued for an async subscriber
+type delivery struct {
+	ctx   context.Context
+	event domain.Event
+}
+
+// Bus is an in-process publish-subscribe bus of domain events
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers []*subscriber
+	closed      bool
+	workers     sync.WaitGroup
+}
+
+// NewBus creates a new instance of Bus
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe calls h with the events published from now on, synchronously
+// in the order of subscription unless Async is set, and returns the function
+// cancelling the subscription. name identifies the subscriber in logs and
+// metrics.
+func (b *Bus) Subscribe(name string, h Handler, opts ...SubscribeOption) (unsubscribe func()) {
+	s := &subscriber{name: name, handler: h}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return func() {}
+	}
+	b.subscribers = append(b.subscribers, s)
+	for _, queue := range s.queues {
+		b.workers.Add(1)
+		go func(queue chan delivery) {
+			defer b.workers.Done()
+			for d := range queue {
+				s.handle(d.ctx, d.event)
+			}
+		}(queue)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if i := slices.Index(b.subscribers, s); i >= 0 {
+				b.subscribers = slices.Delete(slices.Clone(b.subscribers), i, i+1)
+				s.close()
+			}
+		})
+	}
+}
+
+// Publish delivers events, in order, to every subscriber. Synchronous
+// subscribers have handled them when Publish returns. Async subscribers get
+// a context with the values of ctx that is not cancelled with it. Events
+// published after Close are dropped.
+func (b *Bus) Publish(ctx context.Context, events ...domain.Event) {
+	for _, e := range events {
+		b.Deliver(ctx, e)
+	}
+}
+
+// Deliver publishes e and returns the errors of the synchronous
+// subscribers, panics included, so that the caller can deliver it again.
+// Async subscribers count as served once e is queued. Deliver fails with
+// ErrClosed after Close.
+func (b *Bus) Deliver(ctx context.Context, e domain.Event) error {
+	// Handlers run without the lock of the bus, so that they can publish
+	// and subscribe
+	b.mu.RLock()
+	closed := b.closed
+	subscribers := slices.Clone(b.subscribers)
+	b.mu.RUnlock()
+	if closed {
+		return ErrClosed
+	}
+
+	publishedTotal.Add(e.EventName(), 1)
+	var errs []error
+	for _, s := range subscribers {
+		if s.names != nil && !slices.Contains(s.names, e.EventName()) {
+			continue
+		}
+		if s.queues == nil {
+			if err := s.handle(ctx, e); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		s.enqueue(delivery{ctx: context.WithoutCancel(ctx), event: e})
+	}
+	return errors.Join(errs...)
+}
+
+// Close stops accepting events and waits, until ctx is done, for the async
+// subscribers to handle the events already queued
+func (b *Bus) Close(ctx context.Context) error {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		for _, s := range b.subscribers {
+			s.close()
+		}
+		b.subscribers = nil
+	}
+	b.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		b.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handle calls the handler, isolating the publisher from its panics, and
+// returns its error, or the panic as an error
+func (s *subscriber) handle(ctx context.Context, e domain.Event) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			handlerFailuresTotal.Add(s.name, 1)
+			log.Printf("panic in event handler %s for %s of %s: %v\n%s", s.name, e.EventName(), e.AggregateID(), rec, debug.Stack())
+			err = fmt.Errorf("event handler %s panicked: %v", s.name, rec)
+		}
+	}()
+	if err := s.handler(ctx, e); err != nil {
+		handlerFailuresTotal.Add(s.name, 1)
+		log.Printf("Error in event handler %s for %s of %s: %v", s.name, e.EventName(), e.AggregateID(), err)
+		return fmt.Errorf("event handler %s: %w", s.name, err)
+	}
+	return nil
+}
+
+// enqueue queues d for the worker of its fruit, unless the subscription is
+// cancelled
+func (s *subscriber) enqueue(d delivery) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.closed {
+		s.queues[workerIndex(d.event.AggregateID(), len(s.queues))] <- d
+	}
+}
+
+// close stops the workers of an async subscriber once they have drained
+// their queues
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	for _, queue := range s.queues {
+		close(queue)
+	}
+}
+
+// workerIndex spreads aggregates over n workers with 32-bit FNV-1a
+func workerIndex(aggregateID string, n int) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(aggregateID); i++ {
+		h ^= uint32(aggregateID[i])
+		h *= 16777619
+	}
+	return int(h % uint32(n))
+}
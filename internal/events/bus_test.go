@@ -0,0 +1,248 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+)
+
+// stock returns a StockAdjusted event of fruit to quantity
+func stock(fruit string, quantity int) domain.Event {
+	return domain.StockAdjusted{FruitID: fruit, Quantity: quantity}
+}
+
+// recorder records the events it handles
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) handle(ctx context.Context, e domain.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := e.(domain.StockAdjusted)
+	r.events = append(r.events, fmt.Sprintf("%s=%d", s.FruitID, s.Quantity))
+	return nil
+}
+
+func (r *recorder) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.events, ",")
+}
+
+func TestBus_Sync(t *testing.T) {
+	// Setup
+	bus := NewBus()
+	var first, second, filtered recorder
+	bus.Subscribe("first", first.handle)
+	bus.Subscribe("second", second.handle)
+	bus.Subscribe("filtered", filtered.handle, Only(domain.EventFruitCreated))
+
+	// Action
+	bus.Publish(context.Background(), stock("a", 1), stock("b", 2))
+
+	// Assertions: handled before Publish returns
+	for _, r := range []*recorder{&first, &second} {
+		if got := r.String(); got != "a=1,b=2" {
+			t.Errorf("Expected a=1,b=2, got %s", got)
+		}
+	}
+	if got := filtered.String(); got != "" {
+		t.Errorf("Expected no event, got %s", got)
+	}
+}
+
+func TestBus_AsyncOrderedPerAggregate(t *testing.T) {
+	// Setup
+	bus := NewBus()
+	var mu sync.Mutex
+	last := make(map[string]int)
+	outOfOrder := 0
+	bus.Subscribe("async", func(ctx context.Context, e domain.Event) error {
+		s := e.(domain.StockAdjusted)
+		mu.Lock()
+		defer mu.Unlock()
+		if s.Quantity != last[s.FruitID]+1 {
+			outOfOrder++
+		}
+		last[s.FruitID] = s.Quantity
+		return nil
+	}, Async(4, 2))
+
+	// Action: publishers of different fruits, each in order
+	var wg sync.WaitGroup
+	for _, fruit := range []string{"a", "b", "c", "d", "e", "f"} {
+		wg.Add(1)
+		go func(fruit string) {
+			defer wg.Done()
+			for i := 1; i <= 100; i++ {
+				bus.Publish(context.Background(), stock(fruit, i))
+			}
+		}(fruit)
+	}
+	wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := bus.Close(ctx)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if outOfOrder != 0 {
+		t.Errorf("Expected the events of each fruit in order, got %d out of order", outOfOrder)
+	}
+	for fruit, n := range last {
+		if n != 100 {
+			t.Errorf("Expected 100 events of %s, got %d", fruit, n)
+		}
+	}
+}
+
+func TestBus_AsyncContext(t *testing.T) {
+	// Setup
+	type key struct{}
+	bus := NewBus()
+	got := make(chan context.Context, 1)
+	bus.Subscribe("async", func(ctx context.Context, e domain.Event) error {
+		got <- ctx
+		return nil
+	}, Async(1, 1))
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), key{}, "request"))
+
+	// Action: the request ends before the event is handled
+	bus.Publish(ctx, stock("a", 1))
+	cancel()
+
+	// Assertions
+	handlerCtx := <-got
+	if handlerCtx.Value(key{}) != "request" {
+		t.Errorf("Expected the values of the publisher context")
+	}
+	if handlerCtx.Err() != nil {
+		t.Errorf("Expected a context not cancelled with the publisher, got %v", handlerCtx.Err())
+	}
+}
+
+func TestBus_FailureIsolation(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler Handler
+		opts    []SubscribeOption
+	}{
+		{name: "Panic", handler: func(ctx context.Context, e domain.Event) error { panic("boom") }},
+		{name: "Async panic", handler: func(ctx context.Context, e domain.Event) error { panic("boom") }, opts: []SubscribeOption{Async(1, 1)}},
+		{name: "Error", handler: func(ctx context.Context, e domain.Event) error { return errors.New("boom") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			bus := NewBus()
+			name := "failing " + tt.name
+			bus.Subscribe(name, tt.handler, tt.opts...)
+			var after recorder
+			bus.Subscribe("after", after.handle)
+
+			// Action
+			bus.Publish(context.Background(), stock("a", 1), stock("a", 2))
+			if err := bus.Close(context.Background()); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+
+			// Assertions: the other subscriber gets every event
+			if got := after.String(); got != "a=1,a=2" {
+				t.Errorf("Expected a=1,a=2, got %s", got)
+			}
+			if failures, ok := handlerFailuresTotal.Get(name).(*expvar.Int); !ok || failures.Value() != 2 {
+				t.Errorf("Expected 2 failures counted, got %v", handlerFailuresTotal.Get(name))
+			}
+		})
+	}
+}
+
+func TestBus_Unsubscribe(t *testing.T) {
+	// Setup
+	bus := NewBus()
+	var direct, queued recorder
+	unsubscribeSync := bus.Subscribe("sync", direct.handle)
+	unsubscribeAsync := bus.Subscribe("async", queued.handle, Async(2, 4))
+	bus.Publish(context.Background(), stock("a", 1))
+
+	// Action
+	unsubscribeSync()
+	unsubscribeAsync()
+	unsubscribeAsync()
+	bus.Publish(context.Background(), stock("a", 2))
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assertions: queued events are still handled
+	for _, r := range []*recorder{&direct, &queued} {
+		if got := r.String(); got != "a=1" {
+			t.Errorf("Expected a=1, got %s", got)
+		}
+	}
+}
+
+func TestBus_PublishAfterClose(t *testing.T) {
+	// Setup
+	bus := NewBus()
+	var r recorder
+	bus.Subscribe("sync", r.handle)
+	if err := bus.Close(context.Background()); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Action
+	bus.Publish(context.Background(), stock("a", 1))
+	bus.Subscribe("late", r.handle)
+	bus.Publish(context.Background(), stock("a", 2))
+
+	// Assertions
+	if got := r.String(); got != "" {
+		t.Errorf("Expected no event, got %s", got)
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	// Setup
+	m := new(expvar.Map).Init()
+	handle := MetricsHandler(m)
+	ctx := context.Background()
+	fruit := domain.Fruit{ID: "a", Quantity: 10}
+
+	// Action
+	for _, e := range []domain.Event{
+		domain.FruitCreated{Fruit: fruit},
+		domain.StockAdjusted{FruitID: "a", Previous: 10, Quantity: 4},
+		domain.StockAdjusted{FruitID: "a", Previous: 4, Quantity: 7},
+		domain.FruitDeleted{Fruit: domain.Fruit{ID: "a", Quantity: 7}},
+	} {
+		if err := handle(ctx, e); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// Assertions
+	want := map[string]int64{
+		"fruits_created_total":      1,
+		"fruits_deleted_total":      1,
+		"stock_units_added_total":   13,
+		"stock_units_removed_total": 13,
+	}
+	for name, value := range want {
+		if got, ok := m.Get(name).(*expvar.Int); !ok || got.Value() != value {
+			t.Errorf("Expected %s to be %d, got %v", name, value, m.Get(name))
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package events
+
+import (
+	"context"
+	"expvar"
+
+	"fruitsapi/internal/domain"
+)
+
+// MetricsHandler counts the fruits created and deleted and the units of
+// stock added and removed in m
+func MetricsHandler(m *expvar.Map) Handler {
+	return func(ctx context.Context, e domain.Event) error {
+		switch e := e.(type) {
+		case domain.FruitCreated:
+			m.Add("fruits_created_total", 1)
+			m.Add("stock_units_added_total", int64(e.Fruit.Quantity))
+		case domain.FruitDeleted:
+			m.Add("fruits_deleted_total", 1)
+			m.Add("stock_units_removed_total", int64(e.Fruit.Quantity))
+		case domain.StockAdjusted:
+			if delta := e.Quantity - e.Previous; delta > 0 {
+				m.Add("stock_units_added_total", int64(delta))
+			} else {
+				m.Add("stock_units_removed_total", int64(-delta))
+			}
+		case domain.StatusChanged:
+			m.Add("status_changes_total", 1)
+		}
+		return nil
+	}
+}
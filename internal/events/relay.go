@@ -0,0 +1,225 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"fruitsapi/internal/backoff"
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+// Defaults of RelayConfig
+const (
+	DefaultRelayPollInterval = 200 * time.Millisecond
+	DefaultRelayMaxAttempts  = 8
+	DefaultRelayMinBackoff   = time.Second
+	DefaultRelayMaxBackoff   = 5 * time.Minute
+	DefaultRelayBatchSize    = 100
+)
+
+// relayTotal counts the delivered, retried and dead-lettered outbox entries
+var relayTotal = expvar.NewMap("outbox_relay_total")
+
+// Sink receives the events delivered by a Relay, such as a Bus
+type Sink interface {
+	Deliver(ctx context.Context, e domain.Event) error
+}
+
+// RelayConfig holds the settings of a Relay, zero fields taking the
+// defaults
+type RelayConfig struct {
+	// PollInterval is the interval between two reads of the outbox
+	PollInterval time.Duration
+	// MaxAttempts is the number of failed deliveries after which an entry
+	// is moved to the dead letters
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubling with every
+	// attempt up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// BatchSize is the number of pending entries attempted at most at
+	// every poll, and the size of the pages of the outbox read
+	BatchSize int
+}
+
+// Relay delivers the events of the outbox to a Sink, at least once: an
+// entry is removed only once delivered, so that events recorded before a
+// crash are delivered after the restart, possibly twice
+type Relay struct {
+	outbox repository.OutboxRepository
+	sink   Sink
+	cfg    RelayConfig
+	now    func() time.Time
+}
+
+// NewRelay creates a new instance of Relay
+func NewRelay(outbox repository.OutboxRepository, sink Sink, cfg RelayConfig) *Relay {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultRelayPollInterval
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultRelayMaxAttempts
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultRelayMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(DefaultRelayMaxBackoff, cfg.MinBackoff)
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = DefaultRelayBatchSize
+	}
+	return &Relay{
+		outbox: outbox,
+		sink:   sink,
+		cfg:    cfg,
+		now:    time.Now,
+	}
+}
+
+// Run relays the pending entries every poll interval until ctx is done
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.RelayPending(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error relaying outbox entries: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayPending delivers up to BatchSize pending entries that are due,
+// oldest first, and returns the number delivered. The outbox is read by
+// pages of BatchSize, past the entries held back, until BatchSize entries
+// were attempted or every entry was read. A failed delivery is retried
+// after an exponential backoff, and given up as a dead letter after
+// MaxAttempts. The later entries of the same fruit wait for it, so that
+// the events of a fruit are delivered in order: once it is a dead letter,
+// they are moved to the dead letters after it without being delivered, so
+// that replaying the dead letters of the fruit oldest first delivers them
+// in order.
+func (r *Relay) RelayPending(ctx context.Context) (int, error) {
+	delivered, attempted := 0, 0
+	blocked := make(map[string]bool)
+	// dead holds the oldest dead letter of each fruit met, "" if none
+	dead := make(map[string]string)
+	after := ""
+	for {
+		entries, err := r.outbox.PendingAfter(ctx, after, r.cfg.BatchSize)
+		if err != nil {
+			return delivered, err
+		}
+
+		for _, entry := range entries {
+			if attempted == r.cfg.BatchSize {
+				return delivered, nil
+			}
+			if err := ctx.Err(); err != nil {
+				return delivered, err
+			}
+			after = entry.ID
+			if blocked[entry.AggregateID] {
+				continue
+			}
+			oldest, ok := dead[entry.AggregateID]
+			if !ok {
+				if oldest, err = r.outbox.OldestDeadLetter(ctx, entry.AggregateID); err != nil {
+					return delivered, err
+				}
+				dead[entry.AggregateID] = oldest
+			}
+			if oldest != "" && oldest < entry.ID {
+				if err := r.hold(ctx, entry, oldest); err != nil {
+					return delivered, err
+				}
+				continue
+			}
+			if r.now().Before(entry.NextAttempt) {
+				blocked[entry.AggregateID] = true
+				continue
+			}
+
+			attempted++
+			e, err := entry.DecodeEvent()
+			if err != nil {
+				// Retrying cannot help
+				blocked[entry.AggregateID] = true
+				entry.Attempts++
+				entry.LastError = err.Error()
+				if err := r.deadLetter(ctx, entry); err != nil {
+					return delivered, err
+				}
+				continue
+			}
+			if err := r.sink.Deliver(ctx, e); err != nil {
+				blocked[entry.AggregateID] = true
+				if err := r.retry(ctx, entry, err); err != nil {
+					return delivered, err
+				}
+				continue
+			}
+
+			if err := r.outbox.Delivered(ctx, entry.ID); err != nil && !errors.Is(err, domain.ErrOutboxEntryNotFound) {
+				return delivered, err
+			}
+			relayTotal.Add("delivered", 1)
+			delivered++
+		}
+		if len(entries) < r.cfg.BatchSize {
+			return delivered, nil
+		}
+	}
+}
+
+// retry schedules the next delivery of an entry after a failure, or moves
+// it to the dead letters once it has no attempts left
+func (r *Relay) retry(ctx context.Context, entry domain.OutboxEntry, cause error) error {
+	entry.Attempts++
+	entry.LastError = cause.Error()
+	if entry.Attempts >= r.cfg.MaxAttempts {
+		return r.deadLetter(ctx, entry)
+	}
+
+	entry.NextAttempt = r.now().Add(backoff.Delay(r.cfg.MinBackoff, r.cfg.MaxBackoff, entry.Attempts))
+	log.Printf("Delivery of %s of %s failed, attempt %d of %d: %v", entry.Event, entry.AggregateID, entry.Attempts, r.cfg.MaxAttempts, cause)
+	relayTotal.Add("retried", 1)
+	if err := r.outbox.Reschedule(ctx, entry); err != nil && !errors.Is(err, domain.ErrOutboxEntryNotFound) {
+		return fmt.Errorf("error rescheduling outbox entry %s: %w", entry.ID, err)
+	}
+	return nil
+}
+
+// deadLetter gives up the delivery of an entry
+func (r *Relay) deadLetter(ctx context.Context, entry domain.OutboxEntry) error {
+	log.Printf("Giving up delivery of %s of %s after %d attempts: %s", entry.Event, entry.AggregateID, entry.Attempts, entry.LastError)
+	relayTotal.Add("dead_lettered", 1)
+	return r.moveToDeadLetters(ctx, entry)
+}
+
+// hold moves an entry to the dead letters without attempting its delivery,
+// behind the older dead letter of the same fruit
+func (r *Relay) hold(ctx context.Context, entry domain.OutboxEntry, oldest string) error {
+	entry.LastError = fmt.Sprintf("waiting for dead letter %s", oldest)
+	log.Printf("Holding back %s of %s behind dead letter %s", entry.Event, entry.AggregateID, oldest)
+	relayTotal.Add("held", 1)
+	return r.moveToDeadLetters(ctx, entry)
+}
+
+// moveToDeadLetters moves a pending entry to the dead letters
+func (r *Relay) moveToDeadLetters(ctx context.Context, entry domain.OutboxEntry) error {
+	if err := r.outbox.DeadLetter(ctx, entry); err != nil && !errors.Is(err, domain.ErrOutboxEntryNotFound) {
+		return fmt.Errorf("error dead-lettering outbox entry %s: %w", entry.ID, err)
+	}
+	return nil
+}
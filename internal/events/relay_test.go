@@ -0,0 +1,330 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/pkg/kvs"
+)
+
+// failingSink records the events it is given and fails for the fruits in
+// failing
+type failingSink struct {
+	mu      sync.Mutex
+	failing map[string]bool
+	events  []string
+}
+
+func (s *failingSink) Deliver(ctx context.Context, e domain.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := e.(domain.StockAdjusted)
+	if s.failing[a.FruitID] {
+		return errors.New("unavailable")
+	}
+	s.events = append(s.events, fmt.Sprintf("%s=%d", a.FruitID, a.Quantity))
+	return nil
+}
+
+func (s *failingSink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return strings.Join(s.events, ",")
+}
+
+// newOutbox returns a fruit repository and the outbox of its store
+func newOutbox() (*repository.KVSFruitRepository, *repository.KVSOutboxRepository) {
+	client := kvs.NewClient()
+	return repository.NewKVSFruitRepository(client), repository.NewKVSOutboxRepository(client)
+}
+
+// record stores stock adjustments in the outbox, one transaction each
+func record(t *testing.T, repo repository.FruitRepository, events ...domain.Event) {
+	t.Helper()
+	for _, e := range events {
+		if err := repo.Transaction(context.Background(), func(tx repository.FruitTx) error { return tx.AddToOutbox(e) }); err != nil {
+			t.Fatalf("Failed to record event: %v", err)
+		}
+	}
+}
+
+func TestRelay_RelayPending(t *testing.T) {
+	// Setup
+	repo, outbox := newOutbox()
+	sink := &failingSink{}
+	relay := NewRelay(outbox, sink, RelayConfig{})
+	record(t, repo, stock("a", 1), stock("b", 1), stock("a", 2))
+
+	// Action
+	delivered, err := relay.RelayPending(context.Background())
+
+	// Assertions: delivered in order and removed
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if delivered != 3 {
+		t.Errorf("Expected 3 delivered, got %d", delivered)
+	}
+	if got := sink.String(); got != "a=1,b=1,a=2" {
+		t.Errorf("Expected a=1,b=1,a=2, got %s", got)
+	}
+	if pending, _ := outbox.Pending(context.Background(), 0); len(pending) != 0 {
+		t.Errorf("Expected no pending entry, got %d", len(pending))
+	}
+}
+
+func TestRelay_Retry(t *testing.T) {
+	// Setup
+	repo, outbox := newOutbox()
+	sink := &failingSink{failing: map[string]bool{"a": true}}
+	relay := NewRelay(outbox, sink, RelayConfig{MaxAttempts: 3, MinBackoff: time.Second, MaxBackoff: time.Minute})
+	record(t, repo, stock("a", 1), stock("b", 1), stock("a", 2))
+	now := time.Now()
+	relay.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Action: the later event of the failing fruit waits for the first one
+	relay.RelayPending(ctx)
+	pending, _ := outbox.Pending(ctx, 0)
+
+	// Assertions
+	if got := sink.String(); got != "b=1" {
+		t.Errorf("Expected b=1, got %s", got)
+	}
+	if len(pending) != 2 || pending[0].Attempts != 1 || pending[0].LastError != "unavailable" || !pending[0].NextAttempt.Equal(now.Add(time.Second)) {
+		t.Fatalf("Expected the first entry retried in a second, got %+v", pending)
+	}
+	if pending[1].Attempts != 0 {
+		t.Errorf("Expected the second entry not attempted, got %d attempts", pending[1].Attempts)
+	}
+
+	// Action: not due yet
+	relay.RelayPending(ctx)
+	pending, _ = outbox.Pending(ctx, 0)
+
+	// Assertions
+	if pending[0].Attempts != 1 {
+		t.Errorf("Expected 1 attempt before the backoff elapses, got %d", pending[0].Attempts)
+	}
+
+	// Action: the backoff doubles
+	now = now.Add(time.Second)
+	relay.RelayPending(ctx)
+	pending, _ = outbox.Pending(ctx, 0)
+
+	// Assertions
+	if pending[0].Attempts != 2 || !pending[0].NextAttempt.Equal(now.Add(2*time.Second)) {
+		t.Errorf("Expected the second retry in 2s, got %+v", pending[0])
+	}
+
+	// Action: the fruit recovers
+	sink.mu.Lock()
+	sink.failing = nil
+	sink.mu.Unlock()
+	now = now.Add(2 * time.Second)
+	relay.RelayPending(ctx)
+
+	// Assertions
+	if got := sink.String(); got != "b=1,a=1,a=2" {
+		t.Errorf("Expected b=1,a=1,a=2, got %s", got)
+	}
+}
+
+func TestRelay_DeadLetter(t *testing.T) {
+	// Setup
+	repo, outbox := newOutbox()
+	sink := &failingSink{failing: map[string]bool{"a": true}}
+	relay := NewRelay(outbox, sink, RelayConfig{MaxAttempts: 2, MinBackoff: time.Second, MaxBackoff: time.Second})
+	record(t, repo, stock("a", 1))
+	now := time.Now()
+	relay.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Action
+	for i := 0; i < 2; i++ {
+		relay.RelayPending(ctx)
+		now = now.Add(time.Second)
+	}
+	pending, _ := outbox.Pending(ctx, 0)
+	dead, _ := outbox.DeadLetters(ctx, 0)
+
+	// Assertions
+	if len(pending) != 0 || len(dead) != 1 {
+		t.Fatalf("Expected 0 pending entries and 1 dead letter, got %d and %d", len(pending), len(dead))
+	}
+	if dead[0].Attempts != 2 || dead[0].LastError != "unavailable" {
+		t.Errorf("Expected 2 attempts and the last error, got %+v", dead[0])
+	}
+
+	// Action: replayed once the fruit recovers
+	sink.failing = nil
+	if _, err := outbox.Replay(ctx, dead[0].ID); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	relay.RelayPending(ctx)
+
+	// Assertions
+	if got := sink.String(); got != "a=1" {
+		t.Errorf("Expected a=1, got %s", got)
+	}
+}
+
+func TestRelay_DeadLetterHoldsFruit(t *testing.T) {
+	// Setup: the first event of a is dead-lettered, the next one waits
+	repo, outbox := newOutbox()
+	sink := &failingSink{failing: map[string]bool{"a": true}}
+	relay := NewRelay(outbox, sink, RelayConfig{MaxAttempts: 1})
+	record(t, repo, stock("a", 1), stock("b", 1), stock("a", 2))
+	ctx := context.Background()
+	relay.RelayPending(ctx)
+	sink.mu.Lock()
+	sink.failing = nil
+	sink.mu.Unlock()
+
+	// Action: the fruit recovers before its first event is replayed
+	relay.RelayPending(ctx)
+	dead, _ := outbox.DeadLetters(ctx, 0)
+
+	// Assertions: the later event follows the dead letter
+	if got := sink.String(); got != "b=1" {
+		t.Errorf("Expected b=1, got %s", got)
+	}
+	if len(dead) != 2 || dead[1].Attempts != 0 || dead[1].LastError != "waiting for dead letter "+dead[0].ID {
+		t.Fatalf("Expected the later event behind the dead letter, got %+v", dead)
+	}
+
+	// Action: replaying the later event first does not let it overtake
+	if _, err := outbox.Replay(ctx, dead[1].ID); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	relay.RelayPending(ctx)
+
+	// Assertions
+	if got := sink.String(); got != "b=1" {
+		t.Errorf("Expected b=1, got %s", got)
+	}
+
+	// Action: replayed oldest first
+	for _, entry := range dead {
+		if _, err := outbox.Replay(ctx, entry.ID); err != nil {
+			t.Fatalf("Failed to replay: %v", err)
+		}
+		relay.RelayPending(ctx)
+	}
+
+	// Assertions
+	if got := sink.String(); got != "b=1,a=1,a=2" {
+		t.Errorf("Expected b=1,a=1,a=2, got %s", got)
+	}
+}
+
+func TestRelay_BatchSize(t *testing.T) {
+	// Setup
+	repo, outbox := newOutbox()
+	sink := &failingSink{}
+	relay := NewRelay(outbox, sink, RelayConfig{BatchSize: 2})
+	record(t, repo, stock("a", 1), stock("b", 1), stock("a", 2))
+
+	// Action
+	delivered, err := relay.RelayPending(context.Background())
+
+	// Assertions: the oldest entries first
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if delivered != 2 {
+		t.Errorf("Expected 2 delivered, got %d", delivered)
+	}
+	if got := sink.String(); got != "a=1,b=1" {
+		t.Errorf("Expected a=1,b=1, got %s", got)
+	}
+}
+
+func TestRelay_BatchSizeBlocked(t *testing.T) {
+	// Setup: the first page only holds entries of a, which fails
+	repo, outbox := newOutbox()
+	sink := &failingSink{failing: map[string]bool{"a": true}}
+	relay := NewRelay(outbox, sink, RelayConfig{BatchSize: 2})
+	record(t, repo, stock("a", 1), stock("a", 2), stock("a", 3), stock("b", 1), stock("b", 2), stock("b", 3))
+
+	// Action
+	delivered, err := relay.RelayPending(context.Background())
+
+	// Assertions: the entries of b are read past a, up to the batch size
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if delivered != 1 {
+		t.Errorf("Expected 1 delivered, got %d", delivered)
+	}
+	if got := sink.String(); got != "b=1" {
+		t.Errorf("Expected b=1, got %s", got)
+	}
+}
+
+func TestRelay_Bus(t *testing.T) {
+	// Setup: a failing synchronous subscriber gets the event again
+	repo, outbox := newOutbox()
+	bus := NewBus()
+	var r recorder
+	calls := 0
+	bus.Subscribe("flaky", func(ctx context.Context, e domain.Event) error {
+		if calls++; calls == 1 {
+			return errors.New("boom")
+		}
+		return r.handle(ctx, e)
+	})
+	relay := NewRelay(outbox, bus, RelayConfig{MinBackoff: time.Nanosecond})
+	record(t, repo, stock("a", 1))
+	ctx := context.Background()
+
+	// Action
+	relay.RelayPending(ctx)
+	time.Sleep(time.Millisecond)
+	relay.RelayPending(ctx)
+	bus.Close(ctx)
+	record(t, repo, stock("a", 2))
+	relay.RelayPending(ctx)
+
+	// Assertions: the event published after Close stays in the outbox
+	if got := r.String(); got != "a=1" {
+		t.Errorf("Expected a=1, got %s", got)
+	}
+	if pending, _ := outbox.Pending(ctx, 0); len(pending) != 1 || pending[0].Attempts != 1 {
+		t.Errorf("Expected the last event pending, got %+v", pending)
+	}
+}
+
+func TestRelay_Run(t *testing.T) {
+	// Setup
+	repo, outbox := newOutbox()
+	sink := &failingSink{}
+	relay := NewRelay(outbox, sink, RelayConfig{PollInterval: time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		relay.Run(ctx)
+	}()
+
+	// Action
+	record(t, repo, stock("a", 1))
+
+	// Assertions
+	deadline := time.Now().Add(time.Second)
+	for sink.String() != "a=1" && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := sink.String(); got != "a=1" {
+		t.Errorf("Expected a=1, got %s", got)
+	}
+	cancel()
+	<-done
+}
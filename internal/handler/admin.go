@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+)
+
+const (
+	// DefaultOutboxLimit is the number of outbox entries listed when the
+	// request does not say
+	DefaultOutboxLimit = 100
+	// MaxOutboxLimit is the largest number of outbox entries listed at once
+	MaxOutboxLimit = 1000
+)
+
+// AdminHandler handles the operator endpoints under /admin and the audit
+// log, which require the admin token as a bearer token and are disabled
+// without one
+type AdminHandler struct {
+	outbox *service.OutboxService
+	audit  *service.AuditService
+	token  string
+}
+
+// NewAdminHandler creates a new instance of AdminHandler
+func NewAdminHandler(outbox *service.OutboxService, audit *service.AuditService, token string) *AdminHandler {
+	return &AdminHandler{
+		outbox: outbox,
+		audit:  audit,
+		token:  token,
+	}
+}
+
+// RegisterRoutes registers the admin endpoints in the route table
+func (h *AdminHandler) RegisterRoutes(r *router.Router) {
+	r.HandleFunc(http.MethodGet, "/admin/outbox", h.authorize(h.ListOutbox))
+	r.HandleFunc(http.MethodPost, "/admin/outbox/{id}/replay", h.authorize(h.ReplayOutboxEntry))
+	r.HandleFunc(http.MethodGet, "/audit", h.authorize(h.ListAudit))
+	r.HandleFunc(http.MethodGet, "/audit/verify", h.authorize(h.VerifyAudit))
+}
+
+// authorize answers 401 to the requests without the admin token, and 403
+// to every request when no token is configured
+func (h *AdminHandler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			writeJSONError(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			writeJSONError(w, "A valid admin token is required", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// ListOutbox handles GET /admin/outbox?state=pending|dead&limit=n requests,
+// listing the pending entries or the dead letters, oldest first
+func (h *AdminHandler) ListOutbox(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultOutboxLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > MaxOutboxLimit {
+			writeJSONError(w, fmt.Sprintf("limit must be between 1 and %d", MaxOutboxLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	var entries []domain.OutboxEntry
+	var err error
+	switch state := r.URL.Query().Get("state"); state {
+	case "", "pending":
+		entries, err = h.outbox.Pending(r.Context(), limit)
+	case "dead":
+		entries, err = h.outbox.DeadLetters(r.Context(), limit)
+	default:
+		writeJSONError(w, "state must be pending or dead", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := OutboxListResponse{Entries: make([]OutboxEntryResponse, len(entries))}
+	for i := range entries {
+		resp.Entries[i] = toOutboxEntryResponse(&entries[i])
+	}
+	writeJSON(w, "application/json", http.StatusOK, resp)
+}
+
+// ReplayOutboxEntry handles POST /admin/outbox/{id}/replay requests, moving
+// a dead letter back to the pending entries for the relay to deliver again
+func (h *AdminHandler) ReplayOutboxEntry(w http.ResponseWriter, r *http.Request) {
+	entry, err := h.outbox.Replay(r.Context(), router.Param(r, "id"))
+	switch {
+	case errors.Is(err, domain.ErrOutboxEntryNotFound):
+		writeJSONError(w, "Dead letter not found", http.StatusNotFound)
+		return
+	case errors.Is(err, domain.ErrStorageFull):
+		writeJSONError(w, "Storage is full", http.StatusInsufficientStorage)
+		return
+	case err != nil:
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, "application/json", http.StatusOK, toOutboxEntryResponse(entry))
+}
@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/kvs"
+)
+
+// newAdminRoutes serves the admin routes of a store holding one pending
+// entry and one dead letter, returning the ID of the dead letter
+func newAdminRoutes(t *testing.T, token string) (*router.Router, string) {
+	t.Helper()
+	client := kvs.NewClient()
+	fruits := service.NewFruitService(repository.NewKVSFruitRepository(client))
+	outbox := repository.NewKVSOutboxRepository(client)
+	ctx := context.Background()
+	if _, err := fruits.CreateFruit(ctx, "manzana", 12, 1000, "test"); err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+	pending, _ := outbox.Pending(ctx, 0)
+	if err := outbox.DeadLetter(ctx, pending[0]); err != nil {
+		t.Fatalf("Failed to dead-letter: %v", err)
+	}
+	if _, err := fruits.CreateFruit(ctx, "pera", 1, 10, "test"); err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+
+	routes := router.New(router.TrailingSlashRedirect)
+	NewAdminHandler(service.NewOutboxService(outbox), service.NewAuditService(repository.NewKVSAuditRepository(client)), token).RegisterRoutes(routes)
+	return routes, pending[0].ID
+}
+
+func TestAdminHandler_Authorization(t *testing.T) {
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "Disabled", authorization: "Bearer ", expectedStatus: http.StatusForbidden},
+		{name: "MissingToken", token: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "WrongToken", token: "secret", authorization: "Bearer other", expectedStatus: http.StatusUnauthorized},
+		{name: "NotBearer", token: "secret", authorization: "Basic secret", expectedStatus: http.StatusUnauthorized},
+		{name: "ValidToken", token: "secret", authorization: "Bearer secret", expectedStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			routes, _ := newAdminRoutes(t, tt.token)
+			req := httptest.NewRequest(http.MethodGet, "/admin/outbox", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ListOutbox(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedEvents int
+		expectedName   string
+	}{
+		{name: "Pending", query: "", expectedStatus: http.StatusOK, expectedEvents: 1, expectedName: "pera"},
+		{name: "DeadLetters", query: "?state=dead", expectedStatus: http.StatusOK, expectedEvents: 1, expectedName: "manzana"},
+		{name: "Limit", query: "?state=pending&limit=1", expectedStatus: http.StatusOK, expectedEvents: 1, expectedName: "pera"},
+		{name: "InvalidState", query: "?state=done", expectedStatus: http.StatusBadRequest},
+		{name: "InvalidLimit", query: "?limit=0", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			routes, _ := newAdminRoutes(t, "secret")
+			req := httptest.NewRequest(http.MethodGet, "/admin/outbox"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp OutboxListResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if len(resp.Entries) != tt.expectedEvents {
+				t.Fatalf("Expected %d entries, got %d", tt.expectedEvents, len(resp.Entries))
+			}
+			var payload struct {
+				Fruit struct {
+					Name string `json:"name"`
+				} `json:"fruit"`
+			}
+			json.Unmarshal(resp.Entries[0].Payload, &payload)
+			if resp.Entries[0].Event != "fruit.created" || payload.Fruit.Name != tt.expectedName {
+				t.Errorf("Expected fruit.created of %s, got %s of %s", tt.expectedName, resp.Entries[0].Event, payload.Fruit.Name)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ReplayOutboxEntry(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             func(dead string) string
+		expectedStatus int
+	}{
+		{name: "DeadLetter", id: func(dead string) string { return dead }, expectedStatus: http.StatusOK},
+		{name: "UnknownEntry", id: func(dead string) string { return "unknown" }, expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			routes, dead := newAdminRoutes(t, "secret")
+			req := httptest.NewRequest(http.MethodPost, "/admin/outbox/"+tt.id(dead)+"/replay", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var entry OutboxEntryResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &entry); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if entry.ID != dead || entry.Attempts != 0 {
+				t.Errorf("Expected entry %s with no attempts, got %+v", dead, entry)
+			}
+
+			// The entry is pending again, before the entries recorded after it
+			req = httptest.NewRequest(http.MethodGet, "/admin/outbox", nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec = httptest.NewRecorder()
+			routes.ServeHTTP(rec, req)
+			var resp OutboxListResponse
+			json.Unmarshal(rec.Body.Bytes(), &resp)
+			if len(resp.Entries) != 2 || resp.Entries[0].ID != dead {
+				t.Errorf("Expected %s first of 2 pending entries, got %+v", dead, resp.Entries)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_ListAudit(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedSeqs   []uint64
+		expectedCursor string
+	}{
+		{name: "All", expectedStatus: http.StatusOK, expectedSeqs: []uint64{1, 2}},
+		{name: "FirstPage", query: "?limit=1", expectedStatus: http.StatusOK, expectedSeqs: []uint64{1}, expectedCursor: "1"},
+		{name: "LastPage", query: "?limit=1&cursor=1", expectedStatus: http.StatusOK, expectedSeqs: []uint64{2}},
+		{name: "Actor", query: "?actor=anonymous", expectedStatus: http.StatusOK, expectedSeqs: []uint64{1, 2}},
+		{name: "OtherActor", query: "?actor=owner:test", expectedStatus: http.StatusOK},
+		{name: "From", query: "?from=2100-01-01T00:00:00Z", expectedStatus: http.StatusOK},
+		{name: "To", query: "?to=2100-01-01T00:00:00Z", expectedStatus: http.StatusOK, expectedSeqs: []uint64{1, 2}},
+		{name: "InvalidFrom", query: "?from=yesterday", expectedStatus: http.StatusBadRequest},
+		{name: "InvertedRange", query: "?from=2100-01-01T00:00:00Z&to=2000-01-01T00:00:00Z", expectedStatus: http.StatusBadRequest},
+		{name: "InvalidCursor", query: "?cursor=next", expectedStatus: http.StatusBadRequest},
+		{name: "InvalidLimit", query: "?limit=0", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup: the fruits of newAdminRoutes are audited
+			routes, _ := newAdminRoutes(t, "secret")
+			req := httptest.NewRequest(http.MethodGet, "/audit"+tt.query, nil)
+			req.Header.Set("Authorization", "Bearer secret")
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp AuditListResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("Error decoding response: %v", err)
+			}
+			if len(resp.Entries) != len(tt.expectedSeqs) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.expectedSeqs), len(resp.Entries))
+			}
+			for i, entry := range resp.Entries {
+				if entry.Seq != tt.expectedSeqs[i] || entry.Operation != "create" {
+					t.Errorf("Expected create entry %d, got %s entry %d", tt.expectedSeqs[i], entry.Operation, entry.Seq)
+				}
+			}
+			if resp.NextCursor != tt.expectedCursor {
+				t.Errorf("Expected cursor %q, got %q", tt.expectedCursor, resp.NextCursor)
+			}
+		})
+	}
+}
+
+func TestAdminHandler_VerifyAudit(t *testing.T) {
+	// Setup
+	routes, _ := newAdminRoutes(t, "secret")
+	req := httptest.NewRequest(http.MethodGet, "/audit/verify", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec := httptest.NewRecorder()
+
+	// Action
+	routes.ServeHTTP(rec, req)
+
+	// Assertions
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp AuditVerifyResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if !resp.Valid || resp.Entries != 2 || resp.HeadHash == "" {
+		t.Errorf("Expected a valid log of 2 entries, got %+v", resp)
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+const (
+	// DefaultAuditLimit is the number of audit entries listed when the
+	// request does not say
+	DefaultAuditLimit = 100
+	// MaxAuditLimit is the largest number of audit entries listed at once
+	MaxAuditLimit = 1000
+)
+
+// ListAudit handles GET /audit?fruit_id=&actor=&from=&to=&cursor=&limit=
+// requests, listing the matching entries of the audit log, oldest first
+func (h *AdminHandler) ListAudit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := repository.AuditFilter{FruitID: query.Get("fruit_id"), Actor: query.Get("actor")}
+	var err error
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if raw := query.Get(bound.name); raw != "" {
+			if *bound.t, err = time.Parse(time.RFC3339, raw); err != nil {
+				writeJSONError(w, bound.name+" must be an RFC 3339 date-time", http.StatusBadRequest)
+				return
+			}
+		}
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		writeJSONError(w, "from must not be after to", http.StatusBadRequest)
+		return
+	}
+	if raw := query.Get("cursor"); raw != "" {
+		if filter.After, err = strconv.ParseUint(raw, 10, 64); err != nil {
+			writeJSONError(w, "Invalid cursor", http.StatusBadRequest)
+			return
+		}
+	}
+	limit := DefaultAuditLimit
+	if raw := query.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > MaxAuditLimit {
+			writeJSONError(w, fmt.Sprintf("limit must be between 1 and %d", MaxAuditLimit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// One more entry tells whether there is a next page
+	entries, err := h.audit.List(r.Context(), filter, limit+1)
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp AuditListResponse
+	if len(entries) > limit {
+		entries = entries[:limit]
+		resp.NextCursor = strconv.FormatUint(entries[limit-1].Seq, 10)
+	}
+	resp.Entries = make([]AuditEntryResponse, len(entries))
+	for i := range entries {
+		resp.Entries[i] = toAuditEntryResponse(&entries[i])
+	}
+	writeJSON(w, "application/json", http.StatusOK, resp)
+}
+
+// VerifyAudit handles GET /audit/verify requests, checking the chain of
+// hashes of the whole audit log
+func (h *AdminHandler) VerifyAudit(w http.ResponseWriter, r *http.Request) {
+	n, hash, err := h.audit.Verify(r.Context())
+	switch {
+	case errors.Is(err, domain.ErrAuditChainBroken):
+		writeJSON(w, "application/json", http.StatusOK, AuditVerifyResponse{Error: err.Error()})
+		return
+	case err != nil:
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, "application/json", http.StatusOK, AuditVerifyResponse{Valid: true, Entries: n, HeadHash: hash})
+}
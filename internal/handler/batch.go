@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/jsonpatch"
+)
+
+// BatchFruits handles POST /fruits:batch requests. The body is an array of
+// create, update and delete operations and the response a 207 Multi-Status
+// with one result per operation, in order. With ?atomic=true either every
+// operation is applied or none is.
+func (h *FruitHandler) BatchFruits(w http.ResponseWriter, r *http.Request) {
+	// Get owner from header
+	owner := r.Header.Get("Owner")
+	if owner == "" {
+		writeJSONError(w, "Owner header is required", http.StatusBadRequest)
+		return
+	}
+
+	atomic := false
+	if raw := r.URL.Query().Get("atomic"); raw != "" {
+		var err error
+		if atomic, err = strconv.ParseBool(raw); err != nil {
+			writeJSONError(w, "atomic must be true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	var req []BatchOperation
+	if err := DecodeJSON(w, r, &req, h.maxBodyBytes); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+	if len(req) == 0 {
+		writeJSONError(w, "Batch must contain at least one operation", http.StatusBadRequest)
+		return
+	}
+
+	// Parse every operation with the DTOs of the request version, leaving
+	// the ones that fail out of the batch
+	v := h.versionFromContext(r.Context())
+	results := make([]BatchResult, len(req))
+	ops := make([]service.BatchOp, 0, len(req))
+	indexes := make([]int, 0, len(req))
+	for i, item := range req {
+		op, err := parseBatchOperation(v, item)
+		if err != nil {
+			results[i] = BatchResult{Status: err.Status, ID: item.ID, Error: err.Message}
+			continue
+		}
+		ops = append(ops, op)
+		indexes = append(indexes, i)
+	}
+
+	if atomic && len(ops) < len(req) {
+		// Nothing may be applied if any operation is invalid
+		for i := range results {
+			if results[i].Status == 0 {
+				results[i] = BatchResult{Status: http.StatusFailedDependency, ID: req[i].ID, Error: service.ErrBatchAborted.Error()}
+			}
+		}
+		writeJSON(w, "application/json", http.StatusMultiStatus, results)
+		return
+	}
+
+	for j, res := range h.service.Batch(r.Context(), owner, ops, atomic) {
+		i := indexes[j]
+		results[i] = batchResult(v, ops[j], res)
+	}
+	writeJSON(w, "application/json", http.StatusMultiStatus, results)
+}
+
+// parseBatchOperation maps a batch operation to its service counterpart
+func parseBatchOperation(v *APIVersion, item BatchOperation) (service.BatchOp, *DecodeError) {
+	op := service.BatchOp{Kind: service.BatchOpKind(item.Op), ID: item.ID}
+
+	switch op.Kind {
+	case service.BatchCreate:
+		if len(item.Fruit) == 0 {
+			return op, &DecodeError{Status: http.StatusBadRequest, Message: "fruit is required to create a fruit"}
+		}
+		req := v.newCreateRequest()
+		if err := decodeStrict(item.Fruit, req); err != nil {
+			err.Message = "Invalid fruit: " + err.Message
+			return op, err
+		}
+		fruit := req.toDomain()
+		op.Name, op.Quantity, op.Price = fruit.Name, fruit.Quantity, fruit.Price
+	case service.BatchUpdate:
+		if item.ID == "" || len(item.Patch) == 0 {
+			return op, &DecodeError{Status: http.StatusBadRequest, Message: "id and patch are required to update a fruit"}
+		}
+		if err := domain.ValidateFruitID(item.ID); err != nil {
+			return op, &DecodeError{Status: http.StatusBadRequest, Message: err.Error()}
+		}
+		patch := item.Patch
+		op.Apply = patchFruit(v, func(doc []byte) ([]byte, error) { return jsonpatch.MergePatch(doc, patch) })
+	case service.BatchDelete:
+		if item.ID == "" {
+			return op, &DecodeError{Status: http.StatusBadRequest, Message: "id is required to delete a fruit"}
+		}
+		if err := domain.ValidateFruitID(item.ID); err != nil {
+			return op, &DecodeError{Status: http.StatusBadRequest, Message: err.Error()}
+		}
+	default:
+		return op, &DecodeError{Status: http.StatusBadRequest, Message: "op must be one of create, update or delete"}
+	}
+	return op, nil
+}
+
+// batchResult maps the outcome of a batch operation to its response
+func batchResult(v *APIVersion, op service.BatchOp, res service.BatchResult) BatchResult {
+	if res.Err != nil {
+		status, message := patchErrorStatus(res.Err)
+		if errors.Is(res.Err, service.ErrBatchAborted) {
+			status = http.StatusFailedDependency
+		}
+		return BatchResult{Status: status, ID: op.ID, Error: message}
+	}
+
+	switch op.Kind {
+	case service.BatchCreate:
+		return BatchResult{Status: http.StatusCreated, ID: res.Fruit.ID, Fruit: v.toResponse(res.Fruit)}
+	case service.BatchUpdate:
+		return BatchResult{Status: http.StatusOK, ID: res.Fruit.ID, Fruit: v.toResponse(res.Fruit)}
+	default:
+		return BatchResult{Status: http.StatusNoContent, ID: op.ID}
+	}
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/kvs"
+)
+
+func TestFruitHandler_BatchFruits(t *testing.T) {
+	tests := []struct {
+		name             string
+		path             string
+		body             func(existing string) string
+		expectedStatus   int
+		expectedStatuses []int
+		expectedPrice    float64
+	}{
+		{
+			name: "PerItemResults",
+			path: "/fruits:batch",
+			body: func(existing string) string {
+				return `[
+					{"op":"create","fruit":{"name":"pera","quantity":1,"price":10}},
+					{"op":"update","id":"` + existing + `","patch":{"price":900}},
+					{"op":"create","fruit":{"name":"pera1","quantity":1,"price":10}},
+					{"op":"delete","id":"` + missingID + `"},
+					{"op":"create","fruit":{"name":"pera","quantity":1,"unit_price":10}},
+					{"op":"delete","id":"audit/head"}
+				]`
+			},
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusCreated, http.StatusOK, http.StatusBadRequest, http.StatusNotFound, http.StatusBadRequest, http.StatusBadRequest},
+			expectedPrice:    900,
+		},
+		{
+			name: "V2Representation",
+			path: "/v2/fruits:batch",
+			body: func(existing string) string {
+				return `[
+					{"op":"create","fruit":{"name":"pera","quantity":1,"unit_price":10}},
+					{"op":"update","id":"` + existing + `","patch":{"unit_price":800}}
+				]`
+			},
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusCreated, http.StatusOK},
+			expectedPrice:    800,
+		},
+		{
+			name: "AtomicSuccess",
+			path: "/fruits:batch?atomic=true",
+			body: func(existing string) string {
+				return `[
+					{"op":"update","id":"` + existing + `","patch":{"price":900}},
+					{"op":"delete","id":"` + existing + `"}
+				]`
+			},
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusOK, http.StatusNoContent},
+		},
+		{
+			name: "AtomicFailure",
+			path: "/fruits:batch?atomic=true",
+			body: func(existing string) string {
+				return `[
+					{"op":"update","id":"` + existing + `","patch":{"price":900}},
+					{"op":"update","id":"` + existing + `","patch":{"quantity":0}}
+				]`
+			},
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusFailedDependency, http.StatusBadRequest},
+			expectedPrice:    1000,
+		},
+		{
+			name: "AtomicInvalidOperation",
+			path: "/fruits:batch?atomic=true",
+			body: func(existing string) string {
+				return `[
+					{"op":"update","id":"` + existing + `","patch":{"price":900}},
+					{"op":"create"}
+				]`
+			},
+			expectedStatus:   http.StatusMultiStatus,
+			expectedStatuses: []int{http.StatusFailedDependency, http.StatusBadRequest},
+			expectedPrice:    1000,
+		},
+		{
+			name:           "EmptyBatch",
+			path:           "/fruits:batch",
+			body:           func(existing string) string { return `[]` },
+			expectedStatus: http.StatusBadRequest,
+			expectedPrice:  1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := kvs.NewClient()
+			repo := repository.NewKVSFruitRepository(client)
+			service := service.NewFruitService(repo)
+			handler := NewFruitHandler(service)
+			routes := router.New(router.TrailingSlashRedirect)
+			handler.RegisterRoutes(routes)
+			ctx := context.Background()
+			fruit, err := service.CreateFruit(ctx, "manzana", 12, 1000, "test")
+			if err != nil {
+				t.Fatalf("Failed to create fruit: %v", err)
+			}
+
+			// Prepare request
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body(fruit.ID)))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Owner", "test")
+			recorder := httptest.NewRecorder()
+
+			// Execute handler
+			routes.ServeHTTP(recorder, req)
+
+			// Check response
+			if recorder.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.expectedStatuses != nil {
+				var results []BatchResult
+				if err := json.NewDecoder(recorder.Body).Decode(&results); err != nil {
+					t.Fatalf("Error decoding response body: %v", err)
+				}
+				if len(results) != len(tt.expectedStatuses) {
+					t.Fatalf("Expected %d results, got %d", len(tt.expectedStatuses), len(results))
+				}
+				for i, expected := range tt.expectedStatuses {
+					if results[i].Status != expected {
+						t.Errorf("Result %d: expected status %d, got %d (%s)", i, expected, results[i].Status, results[i].Error)
+					}
+				}
+			}
+
+			// Check the stored fruit
+			stored, err := service.GetFruitByID(ctx, fruit.ID)
+			if tt.expectedPrice == 0 {
+				if err == nil {
+					t.Errorf("Expected fruit to be deleted")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to get fruit: %v", err)
+			}
+			if stored.Price != tt.expectedPrice {
+				t.Errorf("Expected price %f, got %f", tt.expectedPrice, stored.Price)
+			}
+		})
+	}
+}
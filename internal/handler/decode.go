@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// DefaultMaxBodyBytes is the request body limit used unless configured otherwise
+const DefaultMaxBodyBytes = 1 << 20
+
+// DecodeError is a request body that could not be decoded, with the status
+// code to answer
+type DecodeError struct {
+	Status  int
+	Message string
+}
+
+// Error implements the error interface
+func (e *DecodeError) Error() string {
+	return e.Message
+}
+
+// DecodeJSON strictly decodes the request body into dst: the body must hold
+// exactly one JSON value, no larger than maxBytes, without fields unknown
+// to dst. Failures are returned as *DecodeError.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, maxBytes int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err, maxBytes)
+	}
+
+	// Anything but EOF after the first value is trailing data
+	if err := dec.Decode(&struct{}{}); !errors.Is(err, io.EOF) {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return decodeError(err, maxBytes)
+		}
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body must contain a single JSON value"}
+	}
+	return nil
+}
+
+// decodeError turns a json.Decoder error into a client-facing message
+func decodeError(err error, maxBytes int64) *DecodeError {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var maxBytesErr *http.MaxBytesError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return &DecodeError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Message: fmt.Sprintf("Request body must not be larger than %d bytes", maxBytes),
+		}
+	case errors.Is(err, io.EOF):
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body must not be empty"}
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body contains badly-formed JSON"}
+	case errors.As(err, &syntaxErr):
+		return &DecodeError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Request body contains badly-formed JSON at position %d", syntaxErr.Offset),
+		}
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return &DecodeError{
+				Status:  http.StatusBadRequest,
+				Message: fmt.Sprintf("Request body must be of type %s, got %s", jsonType(typeErr.Type), typeErr.Value),
+			}
+		}
+		return &DecodeError{
+			Status:  http.StatusBadRequest,
+			Message: fmt.Sprintf("Field %q must be of type %s, got %s", typeErr.Field, jsonType(typeErr.Type), typeErr.Value),
+		}
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for unknown fields
+		field := strings.TrimPrefix(err.Error(), "json: unknown field ")
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Request body contains unknown field " + field}
+	default:
+		return &DecodeError{Status: http.StatusBadRequest, Message: "Invalid request body: " + err.Error()}
+	}
+}
+
+// jsonType names the JSON type expected for a Go type
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return t.Kind().String()
+	}
+}
+
+// writeDecodeError writes the response for a DecodeJSON failure
+func writeDecodeError(w http.ResponseWriter, err error) {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		writeJSONError(w, decodeErr.Message, decodeErr.Status)
+		return
+	}
+	writeJSONError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+}
@@ -0,0 +1,100 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name            string
+		body            string
+		maxBytes        int64
+		expectedStatus  int
+		expectedMessage string
+	}{
+		{
+			name:     "ValidBody",
+			body:     `{"name":"manzana","quantity":12,"price":1000}`,
+			maxBytes: DefaultMaxBodyBytes,
+		},
+		{
+			name:            "UnknownField",
+			body:            `{"nmae":"x"}`,
+			maxBytes:        DefaultMaxBodyBytes,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `Request body contains unknown field "nmae"`,
+		},
+		{
+			name:            "TrailingData",
+			body:            `{"name":"manzana"}{"name":"pera"}`,
+			maxBytes:        DefaultMaxBodyBytes,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request body must contain a single JSON value",
+		},
+		{
+			name:            "TypeMismatch",
+			body:            `{"quantity":"12"}`,
+			maxBytes:        DefaultMaxBodyBytes,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: `Field "quantity" must be of type integer, got string`,
+		},
+		{
+			name:            "WrongTopLevelType",
+			body:            `[]`,
+			maxBytes:        DefaultMaxBodyBytes,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request body must be of type object, got array",
+		},
+		{
+			name:            "EmptyBody",
+			body:            ``,
+			maxBytes:        DefaultMaxBodyBytes,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request body must not be empty",
+		},
+		{
+			name:            "BadlyFormed",
+			body:            `{"name":}`,
+			maxBytes:        DefaultMaxBodyBytes,
+			expectedStatus:  http.StatusBadRequest,
+			expectedMessage: "Request body contains badly-formed JSON at position 9",
+		},
+		{
+			name:            "TooLarge",
+			body:            `{"name":"` + strings.Repeat("a", 64) + `"}`,
+			maxBytes:        32,
+			expectedStatus:  http.StatusRequestEntityTooLarge,
+			expectedMessage: "Request body must not be larger than 32 bytes",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/fruits", strings.NewReader(tt.body))
+			var dst CreateFruitRequest
+
+			err := DecodeJSON(httptest.NewRecorder(), req, &dst, tt.maxBytes)
+
+			if tt.expectedStatus == 0 {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("Expected *DecodeError, got %v", err)
+			}
+			if decodeErr.Status != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, decodeErr.Status)
+			}
+			if decodeErr.Message != tt.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tt.expectedMessage, decodeErr.Message)
+			}
+		})
+	}
+}
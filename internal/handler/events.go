@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fruitsapi/internal/domain"
+)
+
+// DefaultHeartbeatInterval is the interval of the comments keeping event
+// streams alive unless configured otherwise
+const DefaultHeartbeatInterval = 15 * time.Second
+
+// eventWriteTimeout bounds each write of an event stream, so that a client
+// that stops reading is disconnected instead of holding a watcher forever
+const eventWriteTimeout = 10 * time.Second
+
+// SetHeartbeatInterval sets the interval of the comments sent on idle event
+// streams, so that proxies do not close them
+func (h *FruitHandler) SetHeartbeatInterval(d time.Duration) {
+	h.heartbeat = d
+}
+
+// CloseEventStreams ends the event streams and WebSocket connections, and
+// refuses new ones, so that the server can shut down. Clients reconnect,
+// to another instance, with Last-Event-ID.
+func (h *FruitHandler) CloseEventStreams() {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	select {
+	case <-h.streamsClosed:
+	default:
+		close(h.streamsClosed)
+	}
+}
+
+// WaitEventStreams closes the event streams and waits until they have
+// ended, WebSocket connections being out of reach of http.Server.Shutdown
+// once upgraded
+func (h *FruitHandler) WaitEventStreams(ctx context.Context) error {
+	h.CloseEventStreams()
+	done := make(chan struct{})
+	go func() {
+		h.streams.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// openStream counts a new event stream, unless streams are closed
+func (h *FruitHandler) openStream() bool {
+	h.streamsMu.Lock()
+	defer h.streamsMu.Unlock()
+	select {
+	case <-h.streamsClosed:
+		return false
+	default:
+		h.streams.Add(1)
+		return true
+	}
+}
+
+// FruitEvents handles GET /fruits/events requests, streaming the changes of
+// the fruits of the Owner as Server-Sent Events. Each event has the
+// revision of the change as ID, so that a client reconnecting with
+// Last-Event-ID gets the changes it missed, or a resync event when they are
+// no longer available and the fruits must be reloaded.
+func (h *FruitHandler) FruitEvents(w http.ResponseWriter, r *http.Request) {
+	owner := r.Header.Get("Owner")
+	if owner == "" {
+		writeJSONError(w, "Owner header is required", http.StatusBadRequest)
+		return
+	}
+	var revision uint64
+	if lastID := r.Header.Get("Last-Event-ID"); lastID != "" {
+		var err error
+		if revision, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			writeJSONError(w, "Last-Event-ID must be an event ID", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !h.openStream() {
+		writeJSONError(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.streams.Done()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	resync := false
+	changes, err := h.service.WatchFruits(ctx, owner, revision)
+	if errors.Is(err, domain.ErrChangesExpired) {
+		resync = true
+		changes, err = h.service.WatchFruits(ctx, owner, 0)
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	stream := &eventStream{w: w, rc: http.NewResponseController(w)}
+	if resync {
+		stream.write("event: resync\ndata: {}\n\n")
+	} else {
+		stream.write(": connected\n\n")
+	}
+
+	v := h.versionFromContext(r.Context())
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for stream.err == nil {
+		select {
+		case change, ok := <-changes:
+			// A lagging stream ends, the client resumes from its last ID
+			if !ok || change.Type == domain.ChangeLagged {
+				return
+			}
+			event := FruitEvent{
+				Type:     string(change.Type),
+				Revision: change.Revision,
+				Fruit:    v.toResponse(change.Fruit),
+			}
+			if change.Type == domain.ChangeStockChanged {
+				event.PreviousQuantity = &change.Previous.Quantity
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				return
+			}
+			stream.write(fmt.Sprintf("id: %d\nevent: %s\ndata: %s\n\n", change.Revision, change.Type, data))
+		case <-heartbeat.C:
+			stream.write(": heartbeat\n\n")
+		case <-h.streamsClosed:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// eventStream writes the events of a stream, keeping the first error
+type eventStream struct {
+	w   http.ResponseWriter
+	rc  *http.ResponseController
+	err error
+}
+
+// write sends data to the client right away, within eventWriteTimeout
+func (s *eventStream) write(data string) {
+	if s.err != nil {
+		return
+	}
+	// Not every ResponseWriter supports deadlines, as in tests
+	_ = s.rc.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+	if _, s.err = s.w.Write([]byte(data)); s.err == nil {
+		s.err = s.rc.Flush()
+	}
+}
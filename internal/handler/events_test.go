@@ -0,0 +1,279 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/kvs"
+)
+
+// sseMessage is a block of a Server-Sent Events stream, an event or a comment
+type sseMessage struct {
+	id, event, data, comment string
+}
+
+// sseClient reads the messages of an event stream
+type sseClient struct {
+	messages chan sseMessage
+}
+
+// openEvents opens GET /fruits/events on server with the given headers
+func openEvents(t *testing.T, server *httptest.Server, headers map[string]string) (*http.Response, *sseClient) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/fruits/events", nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	client := &sseClient{messages: make(chan sseMessage, 64)}
+	go func() {
+		defer close(client.messages)
+		scanner := bufio.NewScanner(resp.Body)
+		var msg sseMessage
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				client.messages <- msg
+				msg = sseMessage{}
+			case strings.HasPrefix(line, ":"):
+				msg.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id: "):
+				msg.id = line[len("id: "):]
+			case strings.HasPrefix(line, "event: "):
+				msg.event = line[len("event: "):]
+			case strings.HasPrefix(line, "data: "):
+				msg.data = line[len("data: "):]
+			}
+		}
+	}()
+	return resp, client
+}
+
+// next returns the next event of the stream, skipping comments
+func (c *sseClient) next(t *testing.T) sseMessage {
+	t.Helper()
+	for {
+		msg := c.nextMessage(t)
+		if msg.comment == "" {
+			return msg
+		}
+	}
+}
+
+// nextMessage returns the next message of the stream, failing after a second
+func (c *sseClient) nextMessage(t *testing.T) sseMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		if !ok {
+			t.Fatalf("Expected a message, got the end of the stream")
+		}
+		return msg
+	case <-time.After(time.Second):
+		t.Fatalf("Expected a message, got none")
+	}
+	return sseMessage{}
+}
+
+// newEventsServer serves the fruit routes of a new store
+func newEventsServer(t *testing.T) (*FruitHandler, *service.FruitService, *httptest.Server) {
+	t.Helper()
+	svc := service.NewFruitService(repository.NewKVSFruitRepository(kvs.NewClient()))
+	handler := NewFruitHandler(svc)
+	routes := router.New(router.TrailingSlashRedirect)
+	handler.RegisterRoutes(routes)
+	server := httptest.NewServer(routes)
+	t.Cleanup(server.Close)
+	t.Cleanup(handler.CloseEventStreams)
+	return handler, svc, server
+}
+
+func TestFruitHandler_FruitEvents(t *testing.T) {
+	// Setup
+	_, svc, server := newEventsServer(t)
+	ctx := context.Background()
+	resp, events := openEvents(t, server, map[string]string{"Owner": "test"})
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Errorf("Expected Content-Type text/event-stream, got %s", got)
+	}
+	// The stream is open once the connection comment is received
+	events.nextMessage(t)
+
+	// Action
+	if _, err := svc.CreateFruit(ctx, "pera", 1, 1, "other"); err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+	fruit, err := svc.CreateFruit(ctx, "manzana", 12, 1000, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+	for _, apply := range []func(f *domain.Fruit) error{
+		func(f *domain.Fruit) error { f.Quantity = 5; return nil },
+		func(f *domain.Fruit) error { f.Price = 900; return nil },
+	} {
+		if _, err := svc.UpdateFruit(ctx, "test", fruit.ID, apply); err != nil {
+			t.Fatalf("Failed to update fruit: %v", err)
+		}
+	}
+	if err := svc.DeleteFruit(ctx, "test", fruit.ID); err != nil {
+		t.Fatalf("Failed to delete fruit: %v", err)
+	}
+
+	// Assertions: only the fruits of the owner, with increasing revisions
+	// as IDs
+	tests := []struct {
+		event            string
+		quantity         int
+		previousQuantity int
+	}{
+		{event: "created", quantity: 12},
+		{event: "stock_changed", quantity: 5, previousQuantity: 12},
+		{event: "updated", quantity: 5},
+		{event: "deleted", quantity: 5},
+	}
+	var lastID uint64
+	for _, tt := range tests {
+		msg := events.next(t)
+		id, err := strconv.ParseUint(msg.id, 10, 64)
+		if msg.event != tt.event || err != nil || id <= lastID {
+			t.Errorf("Expected event %s with an ID above %d, got %s with ID %s", tt.event, lastID, msg.event, msg.id)
+		}
+		lastID = id
+		var data struct {
+			Type             string        `json:"type"`
+			Fruit            FruitResponse `json:"fruit"`
+			PreviousQuantity *int          `json:"previous_quantity"`
+		}
+		if err := json.Unmarshal([]byte(msg.data), &data); err != nil {
+			t.Fatalf("Error decoding event data: %v", err)
+		}
+		if data.Type != tt.event || data.Fruit.ID != fruit.ID || data.Fruit.Quantity != tt.quantity {
+			t.Errorf("Expected %s of fruit %s with quantity %d, got %+v", tt.event, fruit.ID, tt.quantity, data)
+		}
+		if tt.previousQuantity != 0 && (data.PreviousQuantity == nil || *data.PreviousQuantity != tt.previousQuantity) {
+			t.Errorf("Expected previous quantity %d, got %v", tt.previousQuantity, data.PreviousQuantity)
+		}
+	}
+}
+
+func TestFruitHandler_FruitEventsResume(t *testing.T) {
+	tests := []struct {
+		name        string
+		lastEventID string
+		wantEvents  []string
+	}{
+		{name: "Missed changes", lastEventID: "1", wantEvents: []string{"stock_changed", "deleted"}},
+		{name: "Expired changes", lastEventID: "999", wantEvents: []string{"resync"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup: changes made while disconnected
+			_, svc, server := newEventsServer(t)
+			ctx := context.Background()
+			fruit, err := svc.CreateFruit(ctx, "manzana", 12, 1000, "test")
+			if err != nil {
+				t.Fatalf("Failed to create fruit: %v", err)
+			}
+			if _, err := svc.UpdateFruit(ctx, "test", fruit.ID, func(f *domain.Fruit) error { f.Quantity = 5; return nil }); err != nil {
+				t.Fatalf("Failed to update fruit: %v", err)
+			}
+			if err := svc.DeleteFruit(ctx, "test", fruit.ID); err != nil {
+				t.Fatalf("Failed to delete fruit: %v", err)
+			}
+
+			// Action
+			_, events := openEvents(t, server, map[string]string{"Owner": "test", "Last-Event-ID": tt.lastEventID})
+
+			// Assertions
+			for _, want := range tt.wantEvents {
+				if msg := events.next(t); msg.event != want {
+					t.Errorf("Expected event %s, got %s", want, msg.event)
+				}
+			}
+		})
+	}
+}
+
+func TestFruitHandler_FruitEventsHeartbeat(t *testing.T) {
+	// Setup
+	handler, _, server := newEventsServer(t)
+	handler.SetHeartbeatInterval(10 * time.Millisecond)
+
+	// Action
+	_, events := openEvents(t, server, map[string]string{"Owner": "test"})
+	events.nextMessage(t)
+
+	// Assertions
+	if msg := events.nextMessage(t); msg.comment != "heartbeat" {
+		t.Errorf("Expected a heartbeat, got %+v", msg)
+	}
+}
+
+func TestFruitHandler_CloseEventStreams(t *testing.T) {
+	// Setup
+	handler, _, server := newEventsServer(t)
+	_, events := openEvents(t, server, map[string]string{"Owner": "test"})
+	events.nextMessage(t)
+
+	// Action
+	handler.CloseEventStreams()
+
+	// Assertions
+	select {
+	case _, ok := <-events.messages:
+		if ok {
+			t.Errorf("Expected no message")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected the stream to end")
+	}
+}
+
+func TestFruitHandler_FruitEventsError(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{name: "Missing owner", headers: map[string]string{}},
+		{name: "Invalid Last-Event-ID", headers: map[string]string{"Owner": "test", "Last-Event-ID": "abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			_, _, server := newEventsServer(t)
+
+			// Action
+			resp, _ := openEvents(t, server, tt.headers)
+
+			// Assertions
+			if resp.StatusCode != http.StatusBadRequest {
+				t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+			}
+		})
+	}
+}
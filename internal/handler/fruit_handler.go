@@ -2,31 +2,75 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"sync"
+	"time"
 
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/router"
 	"fruitsapi/internal/service"
 )
 
+// MaxListIDs is the largest number of IDs accepted by ListFruits
+const MaxListIDs = 100
+
 // FruitHandler handles HTTP requests for fruit operations
 type FruitHandler struct {
-	service *service.FruitService
+	service      *service.FruitService
+	versions     []*APIVersion
+	maxBodyBytes int64
+
+	// heartbeat is the interval of the keepalives of event streams, which
+	// are counted in streams and closed with streamsClosed
+	heartbeat     time.Duration
+	streamsMu     sync.Mutex
+	streams       sync.WaitGroup
+	streamsClosed chan struct{}
 }
 
 // NewFruitHandler creates a new instance of FruitHandler
 func NewFruitHandler(service *service.FruitService) *FruitHandler {
 	return &FruitHandler{
-		service: service,
+		service:       service,
+		versions:      defaultVersions(),
+		maxBodyBytes:  DefaultMaxBodyBytes,
+		heartbeat:     DefaultHeartbeatInterval,
+		streamsClosed: make(chan struct{}),
 	}
 }
 
-// CreateFruit handles POST /fruits requests
-func (h *FruitHandler) CreateFruit(w http.ResponseWriter, r *http.Request) {
-	// Check request method
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
+// SetMaxBodyBytes sets the largest request body accepted, larger bodies get a 413
+func (h *FruitHandler) SetMaxBodyBytes(n int64) {
+	h.maxBodyBytes = n
+}
+
+// RegisterRoutes registers the fruit endpoints in the route table, once
+// per version under its prefix and once unversioned with Accept negotiation
+func (h *FruitHandler) RegisterRoutes(r *router.Router) {
+	for _, v := range h.versions {
+		prefix := "/" + v.Name
+		r.HandleFunc(http.MethodPost, prefix+"/fruits", h.withVersion(v, h.CreateFruit))
+		r.HandleFunc(http.MethodGet, prefix+"/fruits", h.withVersion(v, h.ListFruits))
+		r.HandleFunc(http.MethodGet, prefix+"/fruits/events", h.withVersion(v, h.FruitEvents))
+		r.HandleFunc(http.MethodPost, prefix+"/fruits:batch", h.withVersion(v, h.BatchFruits))
+		r.HandleFunc(http.MethodGet, prefix+"/fruits/{id}", h.withVersion(v, h.GetFruitByID))
+		r.HandleFunc(http.MethodPatch, prefix+"/fruits/{id}", h.withVersion(v, h.PatchFruit))
 	}
 
+	r.HandleFunc(http.MethodPost, "/fruits", h.negotiate(h.CreateFruit))
+	r.HandleFunc(http.MethodGet, "/fruits", h.negotiate(h.ListFruits))
+	r.HandleFunc(http.MethodGet, "/fruits/events", h.negotiate(h.FruitEvents))
+	r.HandleFunc(http.MethodGet, "/ws", h.negotiate(h.Subscribe))
+	r.HandleFunc(http.MethodPost, "/fruits:batch", h.negotiate(h.BatchFruits))
+	r.HandleFunc(http.MethodGet, "/fruits/{id}", h.negotiate(h.GetFruitByID))
+	r.HandleFunc(http.MethodPatch, "/fruits/{id}", h.negotiate(h.PatchFruit))
+}
+
+// CreateFruit handles POST /fruits requests
+func (h *FruitHandler) CreateFruit(w http.ResponseWriter, r *http.Request) {
 	// Get owner from header
 	owner := r.Header.Get("Owner")
 	if owner == "" {
@@ -34,59 +78,100 @@ func (h *FruitHandler) CreateFruit(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Parse request body
-	var req CreateFruitRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeJSONError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+	// Parse request body using the DTO of the request version
+	req := h.versionFromContext(r.Context()).newCreateRequest()
+	if err := DecodeJSON(w, r, req, h.maxBodyBytes); err != nil {
+		writeDecodeError(w, err)
 		return
 	}
+	input := req.toDomain()
 
 	// Create fruit using service
-	fruit, err := h.service.CreateFruit(r.Context(), req.Name, req.Quantity, req.Price, owner)
+	fruit, err := h.service.CreateFruit(r.Context(), input.Name, input.Quantity, input.Price, owner)
+	if errors.Is(err, domain.ErrStorageFull) {
+		writeJSONError(w, "Storage is full", http.StatusInsufficientStorage)
+		return
+	}
 	if err != nil {
 		writeJSONError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	// Write response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(fruit)
+	h.writeFruit(w, r, http.StatusCreated, fruit)
 }
 
 // GetFruitByID handles GET /fruits/{id} requests
 func (h *FruitHandler) GetFruitByID(w http.ResponseWriter, r *http.Request) {
-	// Check request method
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	// Extract ID from the route parameters
+	id := router.Param(r, "id")
+
+	// Get fruit using service
+	fruit, err := h.service.GetFruitByID(r.Context(), id)
+	if errors.Is(err, domain.ErrInvalidFruitID) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, "Fruit not found", http.StatusNotFound)
 		return
 	}
 
-	// Extract ID from path
-	// Expected path format: /fruits/{id}
-	path := r.URL.Path
-	if len(path) < 8 {
-		writeJSONError(w, "Invalid path", http.StatusBadRequest)
+	// Write response
+	h.writeFruit(w, r, http.StatusOK, fruit)
+}
+
+// ListFruits handles GET /fruits?ids=a,b,c requests, fetching every fruit
+// in one call and listing the IDs that do not exist
+func (h *FruitHandler) ListFruits(w http.ResponseWriter, r *http.Request) {
+	// Parse the comma-separated IDs, dropping duplicates
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		id = strings.TrimSpace(id)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		writeJSONError(w, "ids query parameter is required", http.StatusBadRequest)
+		return
+	}
+	if len(ids) > MaxListIDs {
+		writeJSONError(w, fmt.Sprintf("ids must not list more than %d IDs", MaxListIDs), http.StatusBadRequest)
 		return
 	}
-	id := path[8:] // Skip "/fruits/"
 
-	// Get fruit using service
-	fruit, err := h.service.GetFruitByID(r.Context(), id)
+	// Get fruits using service
+	fruits, notFound, err := h.service.GetFruitsByIDs(r.Context(), ids)
+	if errors.Is(err, domain.ErrInvalidFruitID) {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		writeJSONError(w, "Fruit not found", http.StatusNotFound)
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Write response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(fruit)
+	// Write response using the representation of the request version
+	v := h.versionFromContext(r.Context())
+	resp := FruitListResponse{Fruits: make([]interface{}, len(fruits)), NotFound: notFound}
+	for i, fruit := range fruits {
+		resp.Fruits[i] = v.toResponse(fruit)
+	}
+	writeJSON(w, h.contentType(v), http.StatusOK, resp)
+}
+
+// Helper function to write JSON responses
+func writeJSON(w http.ResponseWriter, contentType string, status int, body interface{}) {
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
 }
 
 // Helper function to write JSON error responses
 func writeJSONError(w http.ResponseWriter, message string, status int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(ErrorResponse{Error: message})
+	writeJSON(w, "application/json", status, ErrorResponse{Error: message})
 }
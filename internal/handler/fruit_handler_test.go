@@ -4,12 +4,16 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"fruitsapi/internal/domain"
 	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
 	"fruitsapi/internal/service"
 	"fruitsapi/pkg/kvs"
 )
@@ -85,7 +89,7 @@ func TestFruitHandler_CreateFruit(t *testing.T) {
 			reqBody, _ := json.Marshal(tt.requestBody)
 			req := httptest.NewRequest(http.MethodPost, "/fruits", bytes.NewBuffer(reqBody))
 			req.Header.Set("Content-Type", "application/json")
-			
+
 			if tt.ownerHeader != "" {
 				req.Header.Set("Owner", tt.ownerHeader)
 			}
@@ -132,12 +136,36 @@ func TestFruitHandler_CreateFruit(t *testing.T) {
 	}
 }
 
+func TestFruitHandler_CreateFruitStorageFull(t *testing.T) {
+	// Setup: a store that is already full
+	client := kvs.NewClient(kvs.WithQuota(kvs.Quota{MaxKeys: 1}))
+	if err := client.Set(context.Background(), "existing", "value"); err != nil {
+		t.Fatalf("Failed to fill the store: %v", err)
+	}
+	handler := NewFruitHandler(service.NewFruitService(repository.NewKVSFruitRepository(client)))
+
+	req := httptest.NewRequest(http.MethodPost, "/fruits", strings.NewReader(`{"name":"manzana","quantity":12,"price":1000}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Owner", "test")
+	recorder := httptest.NewRecorder()
+
+	// Action
+	handler.CreateFruit(recorder, req)
+
+	// Assertions
+	if recorder.Code != http.StatusInsufficientStorage {
+		t.Errorf("Expected status code %d, got %d", http.StatusInsufficientStorage, recorder.Code)
+	}
+}
+
 func TestFruitHandler_GetFruitByID(t *testing.T) {
 	// Setup
 	client := kvs.NewClient()
 	repo := repository.NewKVSFruitRepository(client)
 	service := service.NewFruitService(repo)
 	handler := NewFruitHandler(service)
+	routes := router.New(router.TrailingSlashRedirect)
+	handler.RegisterRoutes(routes)
 
 	// Create a fruit first
 	ctx := context.Background()
@@ -158,9 +186,14 @@ func TestFruitHandler_GetFruitByID(t *testing.T) {
 		},
 		{
 			name:           "NonExistentFruit",
-			id:             "non-existent-id",
+			id:             missingID,
 			expectedStatus: http.StatusNotFound,
 		},
+		{
+			name:           "InvalidID",
+			id:             "non-existent-id",
+			expectedStatus: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -171,8 +204,8 @@ func TestFruitHandler_GetFruitByID(t *testing.T) {
 			// Prepare response recorder
 			recorder := httptest.NewRecorder()
 
-			// Execute handler
-			handler.GetFruitByID(recorder, req)
+			// Execute handler through the route table to extract the ID
+			routes.ServeHTTP(recorder, req)
 
 			// Check status code
 			if recorder.Code != tt.expectedStatus {
@@ -209,3 +242,200 @@ func TestFruitHandler_GetFruitByID(t *testing.T) {
 		})
 	}
 }
+
+func TestFruitHandler_Versioning(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := repository.NewKVSFruitRepository(client)
+	service := service.NewFruitService(repo)
+	handler := NewFruitHandler(service)
+	sunset := time.Date(2027, 6, 30, 0, 0, 0, 0, time.UTC)
+	if err := handler.DeprecateVersion("v1", sunset); err != nil {
+		t.Fatalf("Failed to deprecate version: %v", err)
+	}
+	routes := router.New(router.TrailingSlashRedirect)
+	handler.RegisterRoutes(routes)
+
+	tests := []struct {
+		name                string
+		path                string
+		accept              string
+		body                string
+		expectedContentType string
+		expectedField       string
+		expectedDeprecation bool
+	}{
+		{
+			name:                "UnversionedAliasesV1",
+			path:                "/fruits",
+			body:                `{"name":"manzana","quantity":12,"price":1000}`,
+			expectedContentType: "application/json",
+			expectedField:       "price",
+			expectedDeprecation: true,
+		},
+		{
+			name:                "V1Prefix",
+			path:                "/v1/fruits",
+			body:                `{"name":"manzana","quantity":12,"price":1000}`,
+			expectedContentType: "application/json",
+			expectedField:       "price",
+			expectedDeprecation: true,
+		},
+		{
+			name:                "V2Prefix",
+			path:                "/v2/fruits",
+			body:                `{"name":"manzana","quantity":12,"unit_price":1000}`,
+			expectedContentType: "application/vnd.fruits.v2+json",
+			expectedField:       "unit_price",
+		},
+		{
+			name:                "V2AcceptNegotiation",
+			path:                "/fruits",
+			accept:              "application/vnd.fruits.v2+json",
+			body:                `{"name":"manzana","quantity":12,"unit_price":1000}`,
+			expectedContentType: "application/vnd.fruits.v2+json",
+			expectedField:       "unit_price",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare request
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Owner", "test")
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+
+			// Execute handler
+			routes.ServeHTTP(recorder, req)
+
+			// Check response
+			if recorder.Code != http.StatusCreated {
+				t.Fatalf("Expected status code %d, got %d: %s", http.StatusCreated, recorder.Code, recorder.Body.String())
+			}
+			if ct := recorder.Header().Get("Content-Type"); ct != tt.expectedContentType {
+				t.Errorf("Expected content type %s, got %s", tt.expectedContentType, ct)
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			if response[tt.expectedField] != float64(1000) {
+				t.Errorf("Expected %s %v, got %v", tt.expectedField, 1000, response[tt.expectedField])
+			}
+
+			deprecated := recorder.Header().Get("Deprecation") == "true"
+			if deprecated != tt.expectedDeprecation {
+				t.Errorf("Expected deprecation %v, got %v", tt.expectedDeprecation, deprecated)
+			}
+			if tt.expectedDeprecation && recorder.Header().Get("Sunset") != sunset.Format(http.TimeFormat) {
+				t.Errorf("Expected Sunset %s, got %s", sunset.Format(http.TimeFormat), recorder.Header().Get("Sunset"))
+			}
+		})
+	}
+}
+
+func TestFruitHandler_ListFruits(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := repository.NewKVSFruitRepository(client)
+	service := service.NewFruitService(repo)
+	handler := NewFruitHandler(service)
+	routes := router.New(router.TrailingSlashRedirect)
+	handler.RegisterRoutes(routes)
+
+	// Create fruits first
+	ctx := context.Background()
+	manzana, err := service.CreateFruit(ctx, "manzana", 12, 1000, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+	pera, err := service.CreateFruit(ctx, "pera", 5, 300, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedStatus   int
+		expectedIDs      []string
+		expectedNotFound []string
+	}{
+		{
+			name:             "FoundAndMissing",
+			query:            "?ids=" + pera.ID + "," + missingID + "," + manzana.ID + "," + pera.ID,
+			expectedStatus:   http.StatusOK,
+			expectedIDs:      []string{pera.ID, manzana.ID},
+			expectedNotFound: []string{missingID},
+		},
+		{
+			name:           "InvalidID",
+			query:          "?ids=" + pera.ID + ",audit/head",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "MissingIDs",
+			query:          "?ids=",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "TooManyIDs",
+			query:          "?ids=" + manyIDs(MaxListIDs+1),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare request
+			req := httptest.NewRequest(http.MethodGet, "/fruits"+tt.query, nil)
+			recorder := httptest.NewRecorder()
+
+			// Execute handler
+			routes.ServeHTTP(recorder, req)
+
+			// Check response
+			if recorder.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Fruits   []FruitResponse `json:"fruits"`
+				NotFound []string        `json:"not_found"`
+			}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			var ids []string
+			for _, fruit := range response.Fruits {
+				ids = append(ids, fruit.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(tt.expectedIDs, ",") {
+				t.Errorf("Expected fruits %v, got %v", tt.expectedIDs, ids)
+			}
+			if strings.Join(response.NotFound, ",") != strings.Join(tt.expectedNotFound, ",") {
+				t.Errorf("Expected not found %v, got %v", tt.expectedNotFound, response.NotFound)
+			}
+		})
+	}
+}
+
+// missingID is a fruit ID that no test creates
+const missingID = "00000000-0000-4000-8000-000000000000"
+
+// manyIDs returns n distinct comma-separated IDs
+func manyIDs(n int) string {
+	ids := make([]string, n)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("id-%d", i)
+	}
+	return strings.Join(ids, ",")
+}
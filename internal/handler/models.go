@@ -1,13 +1,324 @@
 package handler
 
-// CreateFruitRequest represents the request body for creating a new fruit
+import (
+	"encoding/json"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/openapi"
+)
+
+// CreateFruitRequest represents the v1 request body for creating a new fruit
 type CreateFruitRequest struct {
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
+	Name     string  `json:"name" schema:"minLength=1" description:"Letters and spaces only"`
+	Quantity int     `json:"quantity" schema:"exclusiveMinimum=0"`
+	Price    float64 `json:"price" schema:"exclusiveMinimum=0" description:"Price per unit"`
+}
+
+// FruitResponse represents the v1 representation of a fruit
+type FruitResponse struct {
+	ID              string    `json:"id" schema:"format=uuid"`
+	Name            string    `json:"name"`
+	Quantity        int       `json:"quantity"`
+	Price           float64   `json:"price"`
+	DateCreated     time.Time `json:"date_created"`
+	DateLastUpdated time.Time `json:"date_last_updated"`
+	Owner           string    `json:"owner"`
+	Status          string    `json:"status"`
+}
+
+// ConstrainSchema reads the name pattern from the domain
+func (CreateFruitRequest) ConstrainSchema(s *openapi.Schema) {
+	s.Properties["name"].Pattern = domain.NamePattern
+}
+
+// ConstrainSchema reads the statuses from the domain
+func (FruitResponse) ConstrainSchema(s *openapi.Schema) {
+	s.Properties["status"].Enum = domain.FruitStatuses
+}
+
+// FruitListResponse represents the fruits listed by GET /fruits?ids=
+type FruitListResponse struct {
+	// Fruits holds the fruits found, in the representation of the API version
+	Fruits []interface{} `json:"fruits"`
+	// NotFound lists the requested IDs that do not exist
+	NotFound []string `json:"not_found,omitempty"`
+}
+
+// BatchOperation represents one operation of a POST /fruits:batch request
+type BatchOperation struct {
+	Op    string          `json:"op" schema:"enum=create|update|delete"`
+	ID    string          `json:"id,omitempty" schema:"format=uuid" description:"ID of the fruit to update or delete"`
+	Fruit json.RawMessage `json:"fruit,omitempty" description:"Create request of the API version, for create"`
+	Patch json.RawMessage `json:"patch,omitempty" description:"JSON Merge Patch of the fruit representation, for update"`
+}
+
+// BatchResult represents the outcome of one operation of a batch
+type BatchResult struct {
+	// Status is the status code the operation would have answered on its own
+	Status int    `json:"status"`
+	ID     string `json:"id,omitempty"`
+	// Fruit is the created or updated fruit in the representation of the API version
+	Fruit interface{} `json:"fruit,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// FruitEvent represents the data of an event of GET /fruits/events
+type FruitEvent struct {
+	Type     string `json:"type" schema:"enum=created|updated|stock_changed|deleted"`
+	Revision uint64 `json:"revision" description:"Position of the change, sent as the event ID"`
+	// Fruit is the fruit after the change, or before it when deleted, in
+	// the representation of the API version
+	Fruit interface{} `json:"fruit"`
+	// PreviousQuantity is the quantity before a stock_changed event
+	PreviousQuantity *int `json:"previous_quantity,omitempty"`
+}
+
+// SubscriptionFilter selects the fruit events of a WebSocket subscription,
+// every field set must match
+type SubscriptionFilter struct {
+	Owner    string   `json:"owner,omitempty" description:"Must be the Owner header of the connection"`
+	FruitIDs []string `json:"fruit_ids,omitempty" description:"IDs of the fruits to watch"`
+	Status   string   `json:"status,omitempty"`
+	// LowStock matches the fruits whose quantity is at most this threshold
+	LowStock *int `json:"low_stock,omitempty" schema:"minimum=0" description:"Largest quantity matched"`
+}
+
+// ClientMessage represents a message sent by WebSocket clients
+type ClientMessage struct {
+	Type string `json:"type" schema:"enum=subscribe|unsubscribe"`
+	// ID names the subscription in the replies and events
+	ID     string              `json:"id" schema:"minLength=1"`
+	Filter *SubscriptionFilter `json:"filter,omitempty" description:"Filter of a subscribe message"`
+}
+
+// ServerMessage represents a message sent to WebSocket clients
+type ServerMessage struct {
+	Type string `json:"type" schema:"enum=subscribed|unsubscribed|event|resync|error"`
+	// ID is the subscription a reply is about
+	ID string `json:"id,omitempty"`
+	// Subscriptions lists the subscriptions matching an event
+	Subscriptions []string    `json:"subscriptions,omitempty"`
+	Event         *FruitEvent `json:"event,omitempty"`
+	Error         string      `json:"error,omitempty"`
 }
 
 // ErrorResponse represents a standard error response structure
 type ErrorResponse struct {
 	Error string `json:"error"`
+	// Violations lists every schema violation when request validation fails
+	Violations []openapi.Violation `json:"violations,omitempty"`
+}
+
+// ProblemResponse represents an RFC 7807 problem details response
+type ProblemResponse struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// toDomain maps the v1 request to the fruit fields it sets
+func (req CreateFruitRequest) toDomain() domain.Fruit {
+	return domain.Fruit{
+		Name:     req.Name,
+		Quantity: req.Quantity,
+		Price:    req.Price,
+	}
+}
+
+// NewFruitResponse maps a domain fruit to its v1 representation
+func NewFruitResponse(f *domain.Fruit) FruitResponse {
+	return FruitResponse{
+		ID:              f.ID,
+		Name:            f.Name,
+		Quantity:        f.Quantity,
+		Price:           f.Price,
+		DateCreated:     f.DateCreated,
+		DateLastUpdated: f.DateLastUpdated,
+		Owner:           f.Owner,
+		Status:          f.Status,
+	}
+}
+
+// toDomain maps the v1 representation back to a domain fruit
+func (resp FruitResponse) toDomain() domain.Fruit {
+	return domain.Fruit{
+		ID:              resp.ID,
+		Name:            resp.Name,
+		Quantity:        resp.Quantity,
+		Price:           resp.Price,
+		DateCreated:     resp.DateCreated,
+		DateLastUpdated: resp.DateLastUpdated,
+		Owner:           resp.Owner,
+		Status:          resp.Status,
+	}
+}
+
+// OutboxEntryResponse represents an entry of the outbox of domain events
+type OutboxEntryResponse struct {
+	ID          string          `json:"id"`
+	Event       string          `json:"event" description:"Name of the domain event, such as fruit.created"`
+	AggregateID string          `json:"aggregate_id" description:"ID of the fruit the event is about"`
+	Payload     json.RawMessage `json:"payload"`
+	RecordedAt  time.Time       `json:"recorded_at"`
+	Attempts    int             `json:"attempts" description:"Failed deliveries so far"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+// OutboxListResponse represents the entries listed by GET /admin/outbox
+type OutboxListResponse struct {
+	Entries []OutboxEntryResponse `json:"entries"`
+}
+
+// toOutboxEntryResponse converts an outbox entry to its representation
+func toOutboxEntryResponse(entry *domain.OutboxEntry) OutboxEntryResponse {
+	return OutboxEntryResponse{
+		ID:          entry.ID,
+		Event:       entry.Event,
+		AggregateID: entry.AggregateID,
+		Payload:     entry.Payload,
+		RecordedAt:  entry.RecordedAt,
+		Attempts:    entry.Attempts,
+		NextAttempt: entry.NextAttempt,
+		LastError:   entry.LastError,
+	}
+}
+
+// CreateWebhookRequest represents the request body for creating a webhook
+type CreateWebhookRequest struct {
+	URL    string   `json:"url" schema:"minLength=1" description:"Absolute http or https URL the events are posted to"`
+	Events []string `json:"events" schema:"minItems=1" description:"Names of the domain events posted"`
+	Secret string   `json:"secret" description:"Key of the HMAC-SHA256 signature sent in X-Signature, never returned"`
+}
+
+// WebhookResponse represents a webhook, without its secret
+type WebhookResponse struct {
+	ID                  string     `json:"id" schema:"format=uuid"`
+	URL                 string     `json:"url"`
+	Events              []string   `json:"events"`
+	Active              bool       `json:"active" description:"False once disabled after too many consecutive failed attempts"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+}
+
+// WebhookListResponse represents the webhooks listed by GET /webhooks
+type WebhookListResponse struct {
+	Webhooks []WebhookResponse `json:"webhooks"`
+}
+
+// WebhookDeliveryResponse represents a delivery of an event to a webhook
+type WebhookDeliveryResponse struct {
+	ID          string          `json:"id" description:"Sent in X-Webhook-Delivery and as the id of the body"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+	Status      string          `json:"status" schema:"enum=pending|succeeded|failed"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt *time.Time      `json:"next_attempt,omitempty" description:"Time of the next attempt while pending"`
+	StatusCode  int             `json:"status_code,omitempty" description:"Status code of the last response"`
+	LastError   string          `json:"last_error,omitempty"`
+	CompletedAt *time.Time      `json:"completed_at,omitempty"`
+}
+
+// WebhookDeliveryListResponse represents the deliveries listed by
+// GET /webhooks/{id}/deliveries
+type WebhookDeliveryListResponse struct {
+	Deliveries []WebhookDeliveryResponse `json:"deliveries"`
+}
+
+// toWebhookResponse converts a webhook to its representation
+func toWebhookResponse(webhook *domain.Webhook) WebhookResponse {
+	resp := WebhookResponse{
+		ID:                  webhook.ID,
+		URL:                 webhook.URL,
+		Events:              webhook.Events,
+		Active:              webhook.Active,
+		ConsecutiveFailures: webhook.ConsecutiveFailures,
+		CreatedAt:           webhook.CreatedAt,
+	}
+	if !webhook.DisabledAt.IsZero() {
+		resp.DisabledAt = &webhook.DisabledAt
+	}
+	return resp
+}
+
+// toWebhookDeliveryResponse converts a webhook delivery to its
+// representation
+func toWebhookDeliveryResponse(delivery *domain.WebhookDelivery) WebhookDeliveryResponse {
+	resp := WebhookDeliveryResponse{
+		ID:         delivery.ID,
+		Event:      delivery.Event,
+		Payload:    delivery.Payload,
+		CreatedAt:  delivery.CreatedAt,
+		Status:     delivery.Status,
+		Attempts:   delivery.Attempts,
+		StatusCode: delivery.StatusCode,
+		LastError:  delivery.LastError,
+	}
+	if delivery.Status == domain.DeliveryPending {
+		resp.NextAttempt = &delivery.NextAttempt
+	}
+	if !delivery.CompletedAt.IsZero() {
+		resp.CompletedAt = &delivery.CompletedAt
+	}
+	return resp
+}
+
+// AuditFieldChangeResponse represents a field changed by an audited change
+type AuditFieldChangeResponse struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before" description:"Value before the change, null on creation"`
+	After  json.RawMessage `json:"after" description:"Value after the change, null on deletion"`
+}
+
+// AuditEntryResponse represents an entry of the audit log
+type AuditEntryResponse struct {
+	Seq       uint64                     `json:"seq" description:"Position in the log, from 1"`
+	Actor     string                     `json:"actor" description:"owner:<Owner>, unverified_api_key:<fingerprint> or anonymous"`
+	RequestID string                     `json:"request_id"`
+	At        time.Time                  `json:"at"`
+	Operation string                     `json:"operation" schema:"enum=create|update|delete"`
+	FruitID   string                     `json:"fruit_id"`
+	Changes   []AuditFieldChangeResponse `json:"changes"`
+	PrevHash  string                     `json:"prev_hash" description:"Hash of the previous entry, empty for the first one"`
+	Hash      string                     `json:"hash" description:"Hex SHA-256 of the JSON of the entry without its hash"`
+}
+
+// AuditListResponse represents the entries listed by GET /audit
+type AuditListResponse struct {
+	Entries []AuditEntryResponse `json:"entries"`
+	// NextCursor is set when more entries match, to pass as cursor
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// AuditVerifyResponse represents the outcome of GET /audit/verify
+type AuditVerifyResponse struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries" description:"Number of entries checked"`
+	HeadHash string `json:"head_hash,omitempty" description:"Hash of the last entry, to keep outside the API"`
+	Error    string `json:"error,omitempty" description:"First break of the chain"`
+}
+
+// toAuditEntryResponse converts an audit entry to its representation
+func toAuditEntryResponse(entry *domain.AuditEntry) AuditEntryResponse {
+	resp := AuditEntryResponse{
+		Seq:       entry.Seq,
+		Actor:     entry.Actor,
+		RequestID: entry.RequestID,
+		At:        entry.At,
+		Operation: entry.Operation,
+		FruitID:   entry.FruitID,
+		Changes:   make([]AuditFieldChangeResponse, len(entry.Changes)),
+		PrevHash:  entry.PrevHash,
+		Hash:      entry.Hash,
+	}
+	for i, c := range entry.Changes {
+		resp.Changes[i] = AuditFieldChangeResponse{Field: c.Field, Before: c.Before, After: c.After}
+	}
+	return resp
 }
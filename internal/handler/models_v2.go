@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/openapi"
+)
+
+// CreateFruitRequestV2 represents the v2 request body for creating a new fruit
+type CreateFruitRequestV2 struct {
+	Name      string  `json:"name" schema:"minLength=1" description:"Letters and spaces only"`
+	Quantity  int     `json:"quantity" schema:"exclusiveMinimum=0"`
+	UnitPrice float64 `json:"unit_price" schema:"exclusiveMinimum=0" description:"Price per unit"`
+}
+
+// FruitResponseV2 represents the v2 representation of a fruit, which
+// renames price to unit_price and the timestamps to created_at/updated_at
+type FruitResponseV2 struct {
+	ID        string    `json:"id" schema:"format=uuid"`
+	Name      string    `json:"name"`
+	Quantity  int       `json:"quantity"`
+	UnitPrice float64   `json:"unit_price"`
+	Owner     string    `json:"owner"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ConstrainSchema reads the name pattern from the domain
+func (CreateFruitRequestV2) ConstrainSchema(s *openapi.Schema) {
+	s.Properties["name"].Pattern = domain.NamePattern
+}
+
+// ConstrainSchema reads the statuses from the domain
+func (FruitResponseV2) ConstrainSchema(s *openapi.Schema) {
+	s.Properties["status"].Enum = domain.FruitStatuses
+}
+
+// toDomain maps the v2 request to the fruit fields it sets
+func (req CreateFruitRequestV2) toDomain() domain.Fruit {
+	return domain.Fruit{
+		Name:     req.Name,
+		Quantity: req.Quantity,
+		Price:    req.UnitPrice,
+	}
+}
+
+// NewFruitResponseV2 maps a domain fruit to its v2 representation
+func NewFruitResponseV2(f *domain.Fruit) FruitResponseV2 {
+	return FruitResponseV2{
+		ID:        f.ID,
+		Name:      f.Name,
+		Quantity:  f.Quantity,
+		UnitPrice: f.Price,
+		Owner:     f.Owner,
+		Status:    f.Status,
+		CreatedAt: f.DateCreated,
+		UpdatedAt: f.DateLastUpdated,
+	}
+}
+
+// toDomain maps the v2 representation back to a domain fruit
+func (resp FruitResponseV2) toDomain() domain.Fruit {
+	return domain.Fruit{
+		ID:              resp.ID,
+		Name:            resp.Name,
+		Quantity:        resp.Quantity,
+		Price:           resp.UnitPrice,
+		DateCreated:     resp.CreatedAt,
+		DateLastUpdated: resp.UpdatedAt,
+		Owner:           resp.Owner,
+		Status:          resp.Status,
+	}
+}
@@ -0,0 +1,504 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/openapi"
+	"fruitsapi/internal/repository"
+)
+
+// ownerParameter describes the Owner header required to create fruits
+func ownerParameter() *openapi.Parameter {
+	minLength := 1
+	return &openapi.Parameter{
+		Name:        "Owner",
+		In:          "header",
+		Description: "Owner of the fruit record",
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "string", MinLength: &minLength},
+	}
+}
+
+// idParameter describes the fruit ID path parameter
+func idParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "Fruit ID",
+		Required:    true,
+		Schema:      &openapi.Schema{Type: "string", Format: "uuid"},
+	}
+}
+
+// errorResponse describes an ErrorResponse body
+func errorResponse(doc *openapi.Document, description string) *openapi.Response {
+	return openapi.JSONResponse(description, "application/json", doc.Component(ErrorResponse{}))
+}
+
+// idsParameter describes the IDs listed by ListFruits
+func idsParameter() *openapi.Parameter {
+	minItems := 1
+	explode := false
+	return &openapi.Parameter{
+		Name:        "ids",
+		In:          "query",
+		Description: "Comma-separated fruit IDs, at most " + strconv.Itoa(MaxListIDs),
+		Required:    true,
+		Explode:     &explode,
+		Schema: &openapi.Schema{
+			Type:     "array",
+			Items:    &openapi.Schema{Type: "string", Format: "uuid"},
+			MinItems: &minItems,
+		},
+	}
+}
+
+// lastEventIDParameter describes the ID of the last event received by a
+// reconnecting event stream
+func lastEventIDParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "Last-Event-ID",
+		In:          "header",
+		Description: "ID of the last event received, to resume after it",
+		Schema:      &openapi.Schema{Type: "string", Pattern: "^[0-9]+$"},
+	}
+}
+
+// atomicParameter describes the atomic query parameter of batches
+func atomicParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "atomic",
+		In:          "query",
+		Description: "Apply every operation or none",
+		Schema:      &openapi.Schema{Type: "boolean"},
+	}
+}
+
+// patchBody describes the two patch formats accepted by PatchFruit
+func patchBody(doc *openapi.Document) *openapi.RequestBody {
+	if _, ok := doc.Components.Schemas["JSONPatchOperation"]; !ok {
+		minItems := 1
+		doc.Components.Schemas["JSONPatchOperation"] = &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{
+				"op":    {Type: "string", Enum: []string{"add", "remove", "replace", "move", "copy", "test"}},
+				"path":  {Type: "string", Description: "JSON Pointer to the target location"},
+				"from":  {Type: "string", Description: "JSON Pointer to the source location of move and copy"},
+				"value": {Description: "Value of add, replace and test"},
+			},
+			Required: []string{"op", "path"},
+		}
+		doc.Components.Schemas["JSONPatch"] = &openapi.Schema{
+			Type:     "array",
+			Items:    openapi.Ref("JSONPatchOperation"),
+			MinItems: &minItems,
+		}
+	}
+
+	return &openapi.RequestBody{
+		Required: true,
+		Content: map[string]*openapi.MediaType{
+			MergePatchMediaType: {Schema: &openapi.Schema{
+				Type:        "object",
+				Description: "Members of the fruit representation to change, null removes a member",
+			}},
+			JSONPatchMediaType: {Schema: openapi.Ref("JSONPatch")},
+		},
+	}
+}
+
+// DescribeRoutes adds the operations registered by RegisterRoutes to the
+// OpenAPI document
+func (h *FruitHandler) DescribeRoutes(doc *openapi.Document) {
+	for _, v := range h.versions {
+		h.describeVersion(doc, "/"+v.Name, v, v.Name)
+	}
+	h.describeVersion(doc, "", h.versions[0], "")
+
+	doc.AddOperation(http.MethodGet, "/ws", &openapi.Operation{
+		OperationID: "subscribe",
+		Summary:     "Subscribe to the fruit events of the owner over a WebSocket",
+		Description: "Upgrades to a WebSocket. Clients send a ClientMessage, see " + doc.Component(ClientMessage{}).Ref +
+			", to subscribe to a filter or unsubscribe, and receive a ServerMessage, see " + doc.Component(ServerMessage{}).Ref +
+			", for each reply and each event matching their subscriptions. The server pings every " + DefaultHeartbeatInterval.String() +
+			" and closes connections that fall " + strconv.Itoa(wsSendQueue) + " messages behind. " +
+			"Fruits use the representation of the version requested with Accept.",
+		Tags:       []string{"fruits"},
+		Parameters: []*openapi.Parameter{ownerParameter()},
+		Responses: map[string]*openapi.Response{
+			"101": {Description: "Switching to the WebSocket protocol"},
+			"400": {Description: "Missing Owner or not a WebSocket handshake"},
+			"403": {Description: "A browser connecting from another origin"},
+			"503": errorResponse(doc, "The server is shutting down"),
+		},
+	})
+}
+
+// describeVersion adds the fruit operations of a version under a path prefix
+func (h *FruitHandler) describeVersion(doc *openapi.Document, prefix string, v *APIVersion, suffix string) {
+	mediaType := h.contentType(v)
+	fruit := doc.Component(v.toResponse(&domain.Fruit{}))
+	problem := openapi.JSONResponse("Unexpected error", "application/problem+json", doc.Component(ProblemResponse{}))
+
+	description := ""
+	if prefix == "" {
+		description = "Alias of the " + v.Name + " operation. Send Accept: " + h.versions[len(h.versions)-1].MediaType + " to use another version, for the request body as well."
+	}
+
+	doc.AddOperation(http.MethodPost, prefix+"/fruits", &openapi.Operation{
+		OperationID: "createFruit" + suffix,
+		Summary:     "Create a fruit",
+		Description: description,
+		Tags:        []string{"fruits"},
+		Deprecated:  v.Deprecated,
+		Parameters:  []*openapi.Parameter{ownerParameter()},
+		RequestBody: openapi.JSONBody(doc.Component(v.newCreateRequest())),
+		Responses: map[string]*openapi.Response{
+			"201": openapi.JSONResponse("Fruit created", mediaType, fruit),
+			"400": errorResponse(doc, "Invalid input data or validation failure"),
+			"413": errorResponse(doc, "Request body is too large"),
+			"415": errorResponse(doc, "Content-Type is not application/json"),
+			"500": problem,
+			"507": errorResponse(doc, "The storage quota is reached"),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, prefix+"/fruits", &openapi.Operation{
+		OperationID: "listFruits" + suffix,
+		Summary:     "Get several fruits by ID",
+		Description: description,
+		Tags:        []string{"fruits"},
+		Deprecated:  v.Deprecated,
+		Parameters:  []*openapi.Parameter{idsParameter()},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Fruits found, and the IDs that do not exist", mediaType, &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{
+					"fruits":    {Type: "array", Items: fruit},
+					"not_found": {Type: "array", Items: openapi.String()},
+				},
+				Required: []string{"fruits"},
+			}),
+			"400": errorResponse(doc, "Missing or too many IDs"),
+			"500": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, prefix+"/fruits/events", &openapi.Operation{
+		OperationID: "fruitEvents" + suffix,
+		Summary:     "Stream the changes of the fruits of the owner",
+		Description: strings.TrimSpace("Server-Sent Events named after their type, with the revision of the change as ID and a FruitEvent as data. " +
+			"Reconnecting with Last-Event-ID replays the missed changes, or sends a resync event when they are no longer available. " + description),
+		Tags:       []string{"fruits"},
+		Deprecated: v.Deprecated,
+		Parameters: []*openapi.Parameter{ownerParameter(), lastEventIDParameter()},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Stream of fruit events", "text/event-stream", &openapi.Schema{
+				Type:        "string",
+				Description: "Events whose data is a FruitEvent, see " + doc.Component(FruitEvent{}).Ref,
+			}),
+			"400": errorResponse(doc, "Missing Owner or invalid Last-Event-ID"),
+			"500": problem,
+			"503": errorResponse(doc, "The server is shutting down"),
+		},
+	})
+
+	minItems := 1
+	doc.AddOperation(http.MethodPost, prefix+"/fruits:batch", &openapi.Operation{
+		OperationID: "batchFruits" + suffix,
+		Summary:     "Create, update and delete fruits in bulk",
+		Description: strings.TrimSpace("Runs the operations in order and answers one result per operation. " +
+			"Updates take a JSON Merge Patch of the fruit representation. " + description),
+		Tags:       []string{"fruits"},
+		Deprecated: v.Deprecated,
+		Parameters: []*openapi.Parameter{ownerParameter(), atomicParameter()},
+		RequestBody: openapi.JSONBody(&openapi.Schema{
+			Type:     "array",
+			Items:    doc.Component(BatchOperation{}),
+			MinItems: &minItems,
+		}),
+		Responses: map[string]*openapi.Response{
+			"207": openapi.JSONResponse("Result of each operation", "application/json", &openapi.Schema{
+				Type:  "array",
+				Items: doc.Component(BatchResult{}),
+			}),
+			"400": errorResponse(doc, "Invalid request body"),
+			"413": errorResponse(doc, "Request body is too large"),
+			"415": errorResponse(doc, "Content-Type is not application/json"),
+			"500": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, prefix+"/fruits/{id}", &openapi.Operation{
+		OperationID: "getFruit" + suffix,
+		Summary:     "Get a fruit by ID",
+		Description: description,
+		Tags:        []string{"fruits"},
+		Deprecated:  v.Deprecated,
+		Parameters:  []*openapi.Parameter{idParameter()},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Fruit found", mediaType, fruit),
+			"404": errorResponse(doc, "Fruit with the specified ID does not exist"),
+			"500": problem,
+		},
+	})
+
+	doc.AddOperation(http.MethodPatch, prefix+"/fruits/{id}", &openapi.Operation{
+		OperationID: "patchFruit" + suffix,
+		Summary:     "Partially update a fruit",
+		Description: strings.TrimSpace("Applies a JSON Merge Patch (RFC 7396) or a JSON Patch (RFC 6902) to the fruit representation. " +
+			"id, owner and the creation date cannot be changed. " + description),
+		Tags:        []string{"fruits"},
+		Deprecated:  v.Deprecated,
+		Parameters:  []*openapi.Parameter{idParameter(), ownerParameter()},
+		RequestBody: patchBody(doc),
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Fruit updated", mediaType, fruit),
+			"400": errorResponse(doc, "Missing Owner, invalid patch or the patched fruit fails validation"),
+			"404": errorResponse(doc, "The owner has no fruit with the specified ID"),
+			"409": errorResponse(doc, "A test operation of the JSON Patch failed"),
+			"413": errorResponse(doc, "Request body is too large"),
+			"415": errorResponse(doc, "Content-Type is not a supported patch format"),
+			"500": problem,
+			"507": errorResponse(doc, "The storage quota is reached"),
+		},
+	})
+}
+
+// authorizationParameter describes the bearer token of the admin endpoints
+func authorizationParameter() *openapi.Parameter {
+	return &openapi.Parameter{
+		Name:        "Authorization",
+		In:          "header",
+		Description: "Bearer followed by the admin token",
+		Schema:      &openapi.Schema{Type: "string", Pattern: "^Bearer .+$"},
+	}
+}
+
+// DescribeRoutes adds the operations registered by RegisterRoutes to the
+// OpenAPI document
+func (h *AdminHandler) DescribeRoutes(doc *openapi.Document) {
+	minimum, maximum := 1.0, float64(MaxOutboxLimit)
+	entry := doc.Component(OutboxEntryResponse{})
+	unauthorized := errorResponse(doc, "Missing or invalid admin token")
+	disabled := errorResponse(doc, "No admin token is configured")
+
+	doc.AddOperation(http.MethodGet, "/admin/outbox", &openapi.Operation{
+		OperationID: "listOutbox",
+		Summary:     "List the pending entries or the dead letters of the outbox",
+		Description: "Domain events are stored in the outbox with the change raising them, until the relay delivers them. " +
+			"Entries whose delivery keeps failing are moved to the dead letters.",
+		Tags: []string{"admin"},
+		Parameters: []*openapi.Parameter{
+			authorizationParameter(),
+			{
+				Name:        "state",
+				In:          "query",
+				Description: "pending, the default, or dead",
+				Schema:      &openapi.Schema{Type: "string", Enum: []string{"pending", "dead"}},
+			},
+			{
+				Name:        "limit",
+				In:          "query",
+				Description: "Number of entries listed, oldest first, " + strconv.Itoa(DefaultOutboxLimit) + " by default",
+				Schema:      &openapi.Schema{Type: "integer", Minimum: &minimum, Maximum: &maximum},
+			},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Outbox entries", "application/json", doc.Component(OutboxListResponse{})),
+			"400": errorResponse(doc, "Invalid state or limit"),
+			"401": unauthorized,
+			"403": disabled,
+			"500": errorResponse(doc, "The outbox cannot be read"),
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/admin/outbox/{id}/replay", &openapi.Operation{
+		OperationID: "replayOutboxEntry",
+		Summary:     "Deliver a dead letter again",
+		Description: "Moves the dead letter back to the pending entries, due now and with its attempts reset.",
+		Tags:        []string{"admin"},
+		Parameters: []*openapi.Parameter{
+			authorizationParameter(),
+			{
+				Name:        "id",
+				In:          "path",
+				Description: "Outbox entry ID",
+				Required:    true,
+				Schema:      openapi.String(),
+			},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Entry queued for delivery", "application/json", entry),
+			"401": unauthorized,
+			"403": disabled,
+			"404": errorResponse(doc, "No dead letter has the ID"),
+			"500": errorResponse(doc, "The entry cannot be moved"),
+			"507": errorResponse(doc, "The storage quota is reached"),
+		},
+	})
+
+	auditMaximum := float64(MaxAuditLimit)
+	dateTime := &openapi.Schema{Type: "string", Format: "date-time"}
+	doc.AddOperation(http.MethodGet, "/audit", &openapi.Operation{
+		OperationID: "listAudit",
+		Summary:     "List the entries of the audit log",
+		Description: "Every change of a fruit is recorded with its actor, request ID, time and changed fields, " +
+			"in the same transaction as the change. Each entry holds the hash of the previous one.",
+		Tags: []string{"admin"},
+		Parameters: []*openapi.Parameter{
+			authorizationParameter(),
+			{Name: "fruit_id", In: "query", Description: "Only the changes of this fruit", Schema: openapi.String()},
+			{Name: "actor", In: "query", Description: "Only the changes of this actor, such as owner:test", Schema: openapi.String()},
+			{Name: "from", In: "query", Description: "Only the changes made at or after this time", Schema: dateTime},
+			{Name: "to", In: "query", Description: "Only the changes made at or before this time", Schema: dateTime},
+			{Name: "cursor", In: "query", Description: "next_cursor of the previous page", Schema: &openapi.Schema{Type: "string", Pattern: "^[0-9]+$"}},
+			{
+				Name:        "limit",
+				In:          "query",
+				Description: "Number of entries listed, oldest first, " + strconv.Itoa(DefaultAuditLimit) + " by default",
+				Schema:      &openapi.Schema{Type: "integer", Minimum: &minimum, Maximum: &auditMaximum},
+			},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Audit entries", "application/json", doc.Component(AuditListResponse{})),
+			"400": errorResponse(doc, "Invalid time, cursor or limit"),
+			"401": unauthorized,
+			"403": disabled,
+			"500": errorResponse(doc, "The audit log cannot be read"),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/audit/verify", &openapi.Operation{
+		OperationID: "verifyAudit",
+		Summary:     "Check that the audit log was not tampered with",
+		Description: "Recomputes the hash of every entry and checks that each one chains to the previous one. " +
+			"Keeping head_hash outside the API also detects a rewrite of the whole log.",
+		Tags:       []string{"admin"},
+		Parameters: []*openapi.Parameter{authorizationParameter()},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Outcome of the check", "application/json", doc.Component(AuditVerifyResponse{})),
+			"401": unauthorized,
+			"403": disabled,
+			"500": errorResponse(doc, "The audit log cannot be read"),
+		},
+	})
+}
+
+// DescribeRoutes adds the operations registered by RegisterRoutes to the
+// OpenAPI document
+func (h *WebhookHandler) DescribeRoutes(doc *openapi.Document) {
+	minimum, maximum := 1.0, float64(repository.MaxWebhookLog)
+	secretLength := domain.MinWebhookSecretLength
+	create := doc.Component(CreateWebhookRequest{})
+	doc.Resolve(create).Properties["events"].Items.Enum = domain.EventNames
+	doc.Resolve(create).Properties["secret"].MinLength = &secretLength
+	webhook := doc.Component(WebhookResponse{})
+	owner := ownerParameter()
+	owner.Description = "Owner of the webhooks, whose fruit events they receive"
+	id := &openapi.Parameter{
+		Name:        "id",
+		In:          "path",
+		Description: "Webhook ID",
+		Required:    true,
+		Schema:      openapi.String(),
+	}
+	missingOwner := errorResponse(doc, "Missing Owner header")
+	notFound := errorResponse(doc, "The owner has no webhook with the ID")
+
+	doc.AddOperation(http.MethodPost, "/webhooks", &openapi.Operation{
+		OperationID: "createWebhook",
+		Summary:     "Subscribe a URL to the events of the fruits of the owner",
+		Description: "Each event is posted as JSON with its name in X-Webhook-Event, the delivery ID in X-Webhook-Delivery " +
+			"and sha256= followed by the hex HMAC-SHA256 of the body, keyed with the secret, in X-Signature. " +
+			"Failed deliveries are retried with an exponential backoff, and the webhook is disabled after too many consecutive failures. " +
+			"A delivery may be posted more than once.",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*openapi.Parameter{owner},
+		RequestBody: openapi.JSONBody(create),
+		Responses: map[string]*openapi.Response{
+			"201": openapi.JSONResponse("Webhook created", "application/json", webhook),
+			"400": errorResponse(doc, "Missing Owner or invalid webhook"),
+			"413": errorResponse(doc, "Request body is too large"),
+			"507": errorResponse(doc, "The storage quota is reached"),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/webhooks", &openapi.Operation{
+		OperationID: "listWebhooks",
+		Summary:     "List the webhooks of the owner, oldest first",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*openapi.Parameter{owner},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Webhooks", "application/json", doc.Component(WebhookListResponse{})),
+			"400": missingOwner,
+			"500": errorResponse(doc, "The webhooks cannot be read"),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/webhooks/{id}", &openapi.Operation{
+		OperationID: "getWebhook",
+		Summary:     "Get a webhook of the owner",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*openapi.Parameter{owner, id},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Webhook", "application/json", webhook),
+			"400": missingOwner,
+			"404": notFound,
+		},
+	})
+
+	doc.AddOperation(http.MethodDelete, "/webhooks/{id}", &openapi.Operation{
+		OperationID: "deleteWebhook",
+		Summary:     "Delete a webhook of the owner with its deliveries",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*openapi.Parameter{owner, id},
+		Responses: map[string]*openapi.Response{
+			"204": {Description: "Webhook deleted"},
+			"400": missingOwner,
+			"404": notFound,
+			"500": errorResponse(doc, "The webhook cannot be deleted"),
+		},
+	})
+
+	doc.AddOperation(http.MethodPost, "/webhooks/{id}/enable", &openapi.Operation{
+		OperationID: "enableWebhook",
+		Summary:     "Enable a webhook disabled after failing",
+		Description: "Resets the consecutive failures; the deliveries still pending are then attempted again.",
+		Tags:        []string{"webhooks"},
+		Parameters:  []*openapi.Parameter{owner, id},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Webhook enabled", "application/json", webhook),
+			"400": missingOwner,
+			"404": notFound,
+			"507": errorResponse(doc, "The storage quota is reached"),
+		},
+	})
+
+	doc.AddOperation(http.MethodGet, "/webhooks/{id}/deliveries", &openapi.Operation{
+		OperationID: "listWebhookDeliveries",
+		Summary:     "List the recent deliveries of a webhook",
+		Description: "The pending deliveries come first, then the last " + strconv.Itoa(repository.MaxWebhookLog) +
+			" completed ones, most recent first.",
+		Tags: []string{"webhooks"},
+		Parameters: []*openapi.Parameter{
+			owner,
+			id,
+			{
+				Name:        "limit",
+				In:          "query",
+				Description: "Number of deliveries listed, " + strconv.Itoa(DefaultDeliveriesLimit) + " by default",
+				Schema:      &openapi.Schema{Type: "integer", Minimum: &minimum, Maximum: &maximum},
+			},
+		},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Deliveries", "application/json", doc.Component(WebhookDeliveryListResponse{})),
+			"400": errorResponse(doc, "Missing Owner or invalid limit"),
+			"404": notFound,
+			"500": errorResponse(doc, "The deliveries cannot be read"),
+		},
+	})
+}
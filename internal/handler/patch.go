@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/router"
+	"fruitsapi/pkg/jsonpatch"
+)
+
+const (
+	// MergePatchMediaType is the media type of RFC 7396 JSON Merge Patch bodies
+	MergePatchMediaType = "application/merge-patch+json"
+	// JSONPatchMediaType is the media type of RFC 6902 JSON Patch bodies
+	JSONPatchMediaType = "application/json-patch+json"
+)
+
+// PatchFruit handles PATCH /fruits/{id} requests on the fruits of the
+// owner. The patch applies to the representation of the request version, so
+// v2 clients patch unit_price while v1 clients patch price.
+func (h *FruitHandler) PatchFruit(w http.ResponseWriter, r *http.Request) {
+	// Get owner from header
+	owner := r.Header.Get("Owner")
+	if owner == "" {
+		writeJSONError(w, "Owner header is required", http.StatusBadRequest)
+		return
+	}
+
+	// Extract ID from the route parameters
+	id := router.Param(r, "id")
+
+	// Parse the patch according to its media type
+	var apply func(doc []byte) ([]byte, error)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case MergePatchMediaType:
+		var patch json.RawMessage
+		if err := DecodeJSON(w, r, &patch, h.maxBodyBytes); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		apply = func(doc []byte) ([]byte, error) { return jsonpatch.MergePatch(doc, patch) }
+	case JSONPatchMediaType:
+		var ops []jsonpatch.Operation
+		if err := DecodeJSON(w, r, &ops, h.maxBodyBytes); err != nil {
+			writeDecodeError(w, err)
+			return
+		}
+		apply = func(doc []byte) ([]byte, error) { return jsonpatch.Apply(doc, ops) }
+	default:
+		writeJSONError(w, "Content-Type must be "+MergePatchMediaType+" or "+JSONPatchMediaType, http.StatusUnsupportedMediaType)
+		return
+	}
+
+	// Patch the versioned representation and map it back to the fruit
+	fruit, err := h.service.UpdateFruit(r.Context(), owner, id, patchFruit(h.versionFromContext(r.Context()), apply))
+	if err != nil {
+		status, message := patchErrorStatus(err)
+		writeJSONError(w, message, status)
+		return
+	}
+
+	// Write response
+	h.writeFruit(w, r, http.StatusOK, fruit)
+}
+
+// patchFruit returns a change applying a patch to the representation of
+// the fruit in version v
+func patchFruit(v *APIVersion, apply func(doc []byte) ([]byte, error)) func(f *domain.Fruit) error {
+	return func(f *domain.Fruit) error {
+		doc, err := json.Marshal(v.toResponse(f))
+		if err != nil {
+			return err
+		}
+		patched, err := apply(doc)
+		if err != nil {
+			return err
+		}
+		resp := v.newResponse()
+		if err := decodeStrict(patched, resp); err != nil {
+			err.Message = "Patched fruit is invalid: " + err.Message
+			return err
+		}
+		*f = resp.toDomain()
+		return nil
+	}
+}
+
+// decodeStrict decodes a JSON value rejecting unknown fields, so that
+// patches adding unknown members or changing the type of a field fail
+func decodeStrict(data []byte, dst interface{}) *DecodeError {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		return decodeError(err, 0)
+	}
+	return nil
+}
+
+// patchErrorStatus returns the status code and message for a failed update
+func patchErrorStatus(err error) (int, string) {
+	var decodeErr *DecodeError
+	switch {
+	case errors.Is(err, domain.ErrFruitNotFound):
+		return http.StatusNotFound, "Fruit not found"
+	case errors.Is(err, domain.ErrInvalidFruitID):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, jsonpatch.ErrTestFailed):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, domain.ErrImmutableField):
+		return http.StatusBadRequest, "Cannot change immutable field: " + err.Error()
+	case errors.Is(err, domain.ErrInvalidFruit),
+		errors.Is(err, jsonpatch.ErrInvalidPatch),
+		errors.Is(err, jsonpatch.ErrPathNotFound):
+		return http.StatusBadRequest, err.Error()
+	case errors.Is(err, domain.ErrStorageFull):
+		return http.StatusInsufficientStorage, "Storage is full"
+	case errors.As(err, &decodeErr):
+		return decodeErr.Status, decodeErr.Message
+	default:
+		return http.StatusInternalServerError, err.Error()
+	}
+}
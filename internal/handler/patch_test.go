@@ -0,0 +1,250 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/jsonpatch"
+	"fruitsapi/pkg/kvs"
+)
+
+func TestFruitHandler_PatchFruit(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := repository.NewKVSFruitRepository(client)
+	service := service.NewFruitService(repo)
+	handler := NewFruitHandler(service)
+	routes := router.New(router.TrailingSlashRedirect)
+	handler.RegisterRoutes(routes)
+
+	// Create a fruit first
+	ctx := context.Background()
+	fruit, err := service.CreateFruit(ctx, "manzana", 12, 1000, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		path           string
+		owner          string
+		contentType    string
+		body           string
+		expectedStatus int
+		expectedField  string
+		expectedValue  float64
+	}{
+		{
+			name:           "MergePatch",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"price":900}`,
+			expectedStatus: http.StatusOK,
+			expectedField:  "price",
+			expectedValue:  900,
+		},
+		{
+			name:           "JSONPatchWithTest",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    JSONPatchMediaType,
+			body:           `[{"op":"test","path":"/price","value":900},{"op":"replace","path":"/quantity","value":20}]`,
+			expectedStatus: http.StatusOK,
+			expectedField:  "quantity",
+			expectedValue:  20,
+		},
+		{
+			name:           "V2Representation",
+			path:           "/v2/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"unit_price":800}`,
+			expectedStatus: http.StatusOK,
+			expectedField:  "unit_price",
+			expectedValue:  800,
+		},
+		{
+			name:           "FailedTest",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    JSONPatchMediaType,
+			body:           `[{"op":"test","path":"/price","value":1},{"op":"replace","path":"/price","value":2}]`,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name:           "ImmutableOwner",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"owner":"someone else"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ImmutableDateCreated",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    JSONPatchMediaType,
+			body:           `[{"op":"replace","path":"/date_created","value":"2020-01-01T00:00:00Z"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "InvalidResult",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"quantity":0}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "RemovedField",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"price":null}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "UnknownField",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"prize":1}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "WrongFieldType",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"price":"cheap"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "UnsupportedMediaType",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    "application/json",
+			body:           `{"price":1}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+		{
+			name:           "UnknownStatus",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"status":"madura"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "MissingOwner",
+			path:           "/fruits/" + fruit.ID,
+			contentType:    MergePatchMediaType,
+			body:           `{"price":1}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "OtherOwner",
+			path:           "/fruits/" + fruit.ID,
+			owner:          "other",
+			contentType:    MergePatchMediaType,
+			body:           `{"price":1}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "NonExistentFruit",
+			path:           "/fruits/" + missingID,
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"price":1}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "InvalidID",
+			path:           "/fruits/non-existent-id",
+			owner:          "test",
+			contentType:    MergePatchMediaType,
+			body:           `{"price":1}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Prepare request
+			req := httptest.NewRequest(http.MethodPatch, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			if tt.owner != "" {
+				req.Header.Set("Owner", tt.owner)
+			}
+			recorder := httptest.NewRecorder()
+
+			// Execute handler
+			routes.ServeHTTP(recorder, req)
+
+			// Check response
+			if recorder.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.expectedField == "" {
+				return
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			if response[tt.expectedField] != tt.expectedValue {
+				t.Errorf("Expected %s %v, got %v", tt.expectedField, tt.expectedValue, response[tt.expectedField])
+			}
+			if response["id"] != fruit.ID || response["owner"] != fruit.Owner {
+				t.Errorf("Expected id %s and owner %s to be unchanged, got %v", fruit.ID, fruit.Owner, response)
+			}
+		})
+	}
+
+	// Failed patches must leave the stored fruit untouched
+	stored, err := service.GetFruitByID(ctx, fruit.ID)
+	if err != nil {
+		t.Fatalf("Failed to get fruit: %v", err)
+	}
+	if stored.Price != 800 || stored.Quantity != 20 {
+		t.Errorf("Expected price 800 and quantity 20, got %f and %d", stored.Price, stored.Quantity)
+	}
+	if !stored.DateCreated.Equal(fruit.DateCreated) {
+		t.Errorf("Expected date_created %v, got %v", fruit.DateCreated, stored.DateCreated)
+	}
+}
+
+func TestPatchErrorStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{name: "NotFound", err: domain.ErrFruitNotFound, expectedStatus: http.StatusNotFound},
+		{name: "InvalidFruit", err: fmt.Errorf("%w: status must be one of comestible, podrida", domain.ErrInvalidFruit), expectedStatus: http.StatusBadRequest},
+		{name: "InvalidPatch", err: fmt.Errorf("operation 0: %w", jsonpatch.ErrPathNotFound), expectedStatus: http.StatusBadRequest},
+		{name: "TestFailed", err: jsonpatch.ErrTestFailed, expectedStatus: http.StatusConflict},
+		{name: "StorageFull", err: domain.ErrStorageFull, expectedStatus: http.StatusInsufficientStorage},
+		{name: "StorageError", err: errors.New("error saving fruit to KVS: boom"), expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, _ := patchErrorStatus(tt.err); status != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, status)
+			}
+		})
+	}
+}
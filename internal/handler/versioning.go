@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/router"
+)
+
+// createRequest is implemented by every versioned create request DTO
+type createRequest interface {
+	toDomain() domain.Fruit
+}
+
+// fruitResponse is implemented by every versioned fruit representation
+type fruitResponse interface {
+	toDomain() domain.Fruit
+}
+
+// APIVersion describes a version of the fruits representation
+type APIVersion struct {
+	// Name is the path prefix of the version, e.g. "v1"
+	Name string
+	// MediaType is the vendor media type used for Accept negotiation
+	MediaType string
+	// Deprecated versions answer with Deprecation and Sunset headers
+	Deprecated bool
+	Sunset     time.Time
+
+	// newCreateRequest returns an empty create request DTO to decode into
+	newCreateRequest func() createRequest
+	// toResponse maps a domain fruit to the response DTO
+	toResponse func(f *domain.Fruit) interface{}
+	// newResponse returns an empty response DTO to decode a patched
+	// representation into
+	newResponse func() fruitResponse
+}
+
+// defaultVersions returns the supported versions, oldest first. The
+// unversioned paths are aliases of the first one.
+func defaultVersions() []*APIVersion {
+	return []*APIVersion{
+		{
+			Name:             "v1",
+			MediaType:        "application/vnd.fruits.v1+json",
+			newCreateRequest: func() createRequest { return &CreateFruitRequest{} },
+			toResponse:       func(f *domain.Fruit) interface{} { return NewFruitResponse(f) },
+			newResponse:      func() fruitResponse { return &FruitResponse{} },
+		},
+		{
+			Name:             "v2",
+			MediaType:        "application/vnd.fruits.v2+json",
+			newCreateRequest: func() createRequest { return &CreateFruitRequestV2{} },
+			toResponse:       func(f *domain.Fruit) interface{} { return NewFruitResponseV2(f) },
+			newResponse:      func() fruitResponse { return &FruitResponseV2{} },
+		},
+	}
+}
+
+// DeprecateVersion marks a version as deprecated with the given sunset date
+func (h *FruitHandler) DeprecateVersion(name string, sunset time.Time) error {
+	for _, v := range h.versions {
+		if v.Name == name {
+			v.Deprecated = true
+			v.Sunset = sunset
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown API version %q", name)
+}
+
+type versionKey struct{}
+
+// versionFromContext returns the version selected for the request, the
+// default one when none was selected
+func (h *FruitHandler) versionFromContext(ctx context.Context) *APIVersion {
+	if v, ok := ctx.Value(versionKey{}).(*APIVersion); ok {
+		return v
+	}
+	return h.versions[0]
+}
+
+// withVersion serves next with a fixed version, as selected by the path prefix
+func (h *FruitHandler) withVersion(v *APIVersion, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setDeprecationHeaders(w, v, h.versions[len(h.versions)-1])
+		next(w, r.WithContext(context.WithValue(r.Context(), versionKey{}, v)))
+	}
+}
+
+// negotiate serves next with the version requested in the Accept header,
+// falling back to the default version for unversioned paths
+func (h *FruitHandler) negotiate(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept")
+		v := h.versions[0]
+		if accepted := h.acceptedVersion(r.Header.Get("Accept")); accepted != nil {
+			v = accepted
+		}
+		h.withVersion(v, next)(w, r)
+	}
+}
+
+// OperationPath returns the path of the OpenAPI operation describing r. For
+// the unversioned fruit routes it is the path of the version negotiated
+// with Accept, so that the request is validated against the schemas of the
+// version that serves it.
+func (h *FruitHandler) OperationPath(r *http.Request) string {
+	path := router.Template(r)
+	if !strings.HasPrefix(path, "/fruits") {
+		return path
+	}
+	if v := h.acceptedVersion(r.Header.Get("Accept")); v != nil {
+		return "/" + v.Name + path
+	}
+	return path
+}
+
+// acceptedVersion returns the version whose vendor media type appears in the Accept header
+func (h *FruitHandler) acceptedVersion(accept string) *APIVersion {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for _, v := range h.versions {
+			if mediaType == v.MediaType {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// setDeprecationHeaders advertises the deprecation of v and its successor
+func setDeprecationHeaders(w http.ResponseWriter, v, latest *APIVersion) {
+	if !v.Deprecated {
+		return
+	}
+	w.Header().Set("Deprecation", "true")
+	if !v.Sunset.IsZero() {
+		w.Header().Set("Sunset", v.Sunset.UTC().Format(http.TimeFormat))
+	}
+	if latest != v {
+		w.Header().Set("Link", fmt.Sprintf(`</%s/fruits>; rel="successor-version"`, latest.Name))
+	}
+}
+
+// contentType returns the media type of the responses of version v, plain
+// JSON for the default version
+func (h *FruitHandler) contentType(v *APIVersion) string {
+	if v != h.versions[0] {
+		return v.MediaType
+	}
+	return "application/json"
+}
+
+// writeFruit writes a fruit using the representation of the request version
+func (h *FruitHandler) writeFruit(w http.ResponseWriter, r *http.Request, status int, fruit *domain.Fruit) {
+	v := h.versionFromContext(r.Context())
+	writeJSON(w, h.contentType(v), status, v.toResponse(fruit))
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+)
+
+// DefaultDeliveriesLimit is the number of deliveries listed when the
+// request does not say
+const DefaultDeliveriesLimit = 20
+
+// WebhookHandler handles the webhook endpoints, each request acting on the
+// webhooks of its Owner
+type WebhookHandler struct {
+	service      *service.WebhookService
+	maxBodyBytes int64
+}
+
+// NewWebhookHandler creates a new instance of WebhookHandler
+func NewWebhookHandler(service *service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		service:      service,
+		maxBodyBytes: DefaultMaxBodyBytes,
+	}
+}
+
+// SetMaxBodyBytes sets the largest request body accepted, larger bodies get a 413
+func (h *WebhookHandler) SetMaxBodyBytes(n int64) {
+	h.maxBodyBytes = n
+}
+
+// RegisterRoutes registers the webhook endpoints in the route table
+func (h *WebhookHandler) RegisterRoutes(r *router.Router) {
+	r.HandleFunc(http.MethodPost, "/webhooks", h.withOwner(h.CreateWebhook))
+	r.HandleFunc(http.MethodGet, "/webhooks", h.withOwner(h.ListWebhooks))
+	r.HandleFunc(http.MethodGet, "/webhooks/{id}", h.withOwner(h.GetWebhook))
+	r.HandleFunc(http.MethodDelete, "/webhooks/{id}", h.withOwner(h.DeleteWebhook))
+	r.HandleFunc(http.MethodPost, "/webhooks/{id}/enable", h.withOwner(h.EnableWebhook))
+	r.HandleFunc(http.MethodGet, "/webhooks/{id}/deliveries", h.withOwner(h.ListDeliveries))
+}
+
+// withOwner answers 400 to the requests without an Owner header
+func (h *WebhookHandler) withOwner(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Owner") == "" {
+			writeJSONError(w, "Owner header is required", http.StatusBadRequest)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// CreateWebhook handles POST /webhooks requests
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req CreateWebhookRequest
+	if err := DecodeJSON(w, r, &req, h.maxBodyBytes); err != nil {
+		writeDecodeError(w, err)
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(r.Context(), r.Header.Get("Owner"), req.URL, req.Events, req.Secret)
+	if errors.Is(err, domain.ErrStorageFull) {
+		writeJSONError(w, "Storage is full", http.StatusInsufficientStorage)
+		return
+	}
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, "application/json", http.StatusCreated, toWebhookResponse(webhook))
+}
+
+// ListWebhooks handles GET /webhooks requests, oldest first
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.service.ListWebhooks(r.Context(), r.Header.Get("Owner"))
+	if err != nil {
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := WebhookListResponse{Webhooks: make([]WebhookResponse, len(webhooks))}
+	for i := range webhooks {
+		resp.Webhooks[i] = toWebhookResponse(&webhooks[i])
+	}
+	writeJSON(w, "application/json", http.StatusOK, resp)
+}
+
+// GetWebhook handles GET /webhooks/{id} requests
+func (h *WebhookHandler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.service.GetWebhook(r.Context(), r.Header.Get("Owner"), router.Param(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, "application/json", http.StatusOK, toWebhookResponse(webhook))
+}
+
+// DeleteWebhook handles DELETE /webhooks/{id} requests, dropping the
+// deliveries not attempted yet
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteWebhook(r.Context(), r.Header.Get("Owner"), router.Param(r, "id")); err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EnableWebhook handles POST /webhooks/{id}/enable requests, activating a
+// webhook disabled for failing
+func (h *WebhookHandler) EnableWebhook(w http.ResponseWriter, r *http.Request) {
+	webhook, err := h.service.EnableWebhook(r.Context(), r.Header.Get("Owner"), router.Param(r, "id"))
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+	writeJSON(w, "application/json", http.StatusOK, toWebhookResponse(webhook))
+}
+
+// ListDeliveries handles GET /webhooks/{id}/deliveries?limit=n requests,
+// listing the pending deliveries then the completed ones, most recent first
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit := DefaultDeliveriesLimit
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > repository.MaxWebhookLog {
+			writeJSONError(w, fmt.Sprintf("limit must be between 1 and %d", repository.MaxWebhookLog), http.StatusBadRequest)
+			return
+		}
+	}
+
+	deliveries, err := h.service.Deliveries(r.Context(), r.Header.Get("Owner"), router.Param(r, "id"), limit)
+	if err != nil {
+		writeWebhookError(w, err)
+		return
+	}
+
+	resp := WebhookDeliveryListResponse{Deliveries: make([]WebhookDeliveryResponse, len(deliveries))}
+	for i := range deliveries {
+		resp.Deliveries[i] = toWebhookDeliveryResponse(&deliveries[i])
+	}
+	writeJSON(w, "application/json", http.StatusOK, resp)
+}
+
+// writeWebhookError writes the response for a failure of the webhook
+// service
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrWebhookNotFound):
+		writeJSONError(w, "Webhook not found", http.StatusNotFound)
+	case errors.Is(err, domain.ErrStorageFull):
+		writeJSONError(w, "Storage is full", http.StatusInsufficientStorage)
+	default:
+		writeJSONError(w, err.Error(), http.StatusInternalServerError)
+	}
+}
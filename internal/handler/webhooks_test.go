@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/kvs"
+)
+
+// newWebhookRoutes serves the webhook routes of a store holding a disabled
+// webhook of alice with one delivery, returning its ID
+func newWebhookRoutes(t *testing.T) (*router.Router, string) {
+	t.Helper()
+	repo := repository.NewKVSWebhookRepository(kvs.NewClient())
+	webhooks := service.NewWebhookService(repo)
+	ctx := context.Background()
+	webhook, err := webhooks.CreateWebhook(ctx, "alice", "https://example.com/hook", []string{domain.EventFruitCreated}, "0123456789abcdef")
+	if err != nil {
+		t.Fatalf("Failed to create webhook: %v", err)
+	}
+	delivery, _ := domain.NewWebhookDelivery(webhook.ID, domain.FruitCreated{Fruit: domain.Fruit{ID: "a", Owner: "alice"}})
+	if err := repo.Enqueue(ctx, delivery); err != nil {
+		t.Fatalf("Failed to enqueue delivery: %v", err)
+	}
+	_, err = repo.Update(ctx, webhook.ID, func(w *domain.Webhook) error {
+		w.Active = false
+		w.ConsecutiveFailures = 20
+		w.DisabledAt = time.Now()
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to disable webhook: %v", err)
+	}
+
+	routes := router.New(router.TrailingSlashRedirect)
+	NewWebhookHandler(webhooks).RegisterRoutes(routes)
+	return routes, webhook.ID
+}
+
+func TestWebhookHandler_CreateWebhook(t *testing.T) {
+	tests := []struct {
+		name           string
+		owner          string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "Valid",
+			owner:          "alice",
+			body:           `{"url":"https://example.com/other","events":["fruit.deleted","fruit.deleted"],"secret":"fedcba9876543210"}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "MissingOwner",
+			body:           `{"url":"https://example.com/other","events":["fruit.deleted"],"secret":"fedcba9876543210"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "UnknownEvent",
+			owner:          "alice",
+			body:           `{"url":"https://example.com/other","events":["fruit.eaten"],"secret":"fedcba9876543210"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "ShortSecret",
+			owner:          "alice",
+			body:           `{"url":"https://example.com/other","events":["fruit.deleted"],"secret":"short"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "InvalidURL",
+			owner:          "alice",
+			body:           `{"url":"example.com","events":["fruit.deleted"],"secret":"fedcba9876543210"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			routes, _ := newWebhookRoutes(t)
+			req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(tt.body))
+			req.Header.Set("Owner", tt.owner)
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+			if tt.expectedStatus != http.StatusCreated {
+				return
+			}
+			if strings.Contains(rec.Body.String(), "fedcba9876543210") {
+				t.Errorf("Expected the secret not to be returned, got %s", rec.Body.String())
+			}
+			var resp WebhookResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			if !resp.Active || len(resp.Events) != 1 || resp.Events[0] != domain.EventFruitDeleted {
+				t.Errorf("Expected an active webhook of %s, got %+v", domain.EventFruitDeleted, resp)
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_Owner(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		owner          string
+		expectedStatus int
+	}{
+		{name: "List", method: http.MethodGet, path: "/webhooks", owner: "alice", expectedStatus: http.StatusOK},
+		{name: "Get", method: http.MethodGet, path: "/webhooks/{id}", owner: "alice", expectedStatus: http.StatusOK},
+		{name: "GetOtherOwner", method: http.MethodGet, path: "/webhooks/{id}", owner: "bob", expectedStatus: http.StatusNotFound},
+		{name: "GetUnknown", method: http.MethodGet, path: "/webhooks/unknown", owner: "alice", expectedStatus: http.StatusNotFound},
+		{name: "GetWithoutOwner", method: http.MethodGet, path: "/webhooks/{id}", expectedStatus: http.StatusBadRequest},
+		{name: "DeleteOtherOwner", method: http.MethodDelete, path: "/webhooks/{id}", owner: "bob", expectedStatus: http.StatusNotFound},
+		{name: "Delete", method: http.MethodDelete, path: "/webhooks/{id}", owner: "alice", expectedStatus: http.StatusNoContent},
+		{name: "EnableOtherOwner", method: http.MethodPost, path: "/webhooks/{id}/enable", owner: "bob", expectedStatus: http.StatusNotFound},
+		{name: "DeliveriesOtherOwner", method: http.MethodGet, path: "/webhooks/{id}/deliveries", owner: "bob", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			routes, id := newWebhookRoutes(t)
+			req := httptest.NewRequest(tt.method, strings.Replace(tt.path, "{id}", id, 1), nil)
+			req.Header.Set("Owner", tt.owner)
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestWebhookHandler_EnableWebhook(t *testing.T) {
+	// Setup
+	routes, id := newWebhookRoutes(t)
+	req := httptest.NewRequest(http.MethodPost, "/webhooks/"+id+"/enable", nil)
+	req.Header.Set("Owner", "alice")
+	rec := httptest.NewRecorder()
+
+	// Action
+	routes.ServeHTTP(rec, req)
+
+	// Assertions
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rec.Code)
+	}
+	var resp WebhookResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("Error decoding response body: %v", err)
+	}
+	if !resp.Active || resp.ConsecutiveFailures != 0 || resp.DisabledAt != nil {
+		t.Errorf("Expected an enabled webhook, got %+v", resp)
+	}
+}
+
+func TestWebhookHandler_ListDeliveries(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+	}{
+		{name: "Default", expectedStatus: http.StatusOK},
+		{name: "Limit", query: "?limit=1", expectedStatus: http.StatusOK},
+		{name: "ZeroLimit", query: "?limit=0", expectedStatus: http.StatusBadRequest},
+		{name: "LimitTooLarge", query: "?limit=101", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			routes, id := newWebhookRoutes(t)
+			req := httptest.NewRequest(http.MethodGet, "/webhooks/"+id+"/deliveries"+tt.query, nil)
+			req.Header.Set("Owner", "alice")
+			rec := httptest.NewRecorder()
+
+			// Action
+			routes.ServeHTTP(rec, req)
+
+			// Assertions
+			if rec.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d", tt.expectedStatus, rec.Code)
+			}
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+			var resp WebhookDeliveryListResponse
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			if len(resp.Deliveries) != 1 || resp.Deliveries[0].Status != domain.DeliveryPending || resp.Deliveries[0].NextAttempt == nil {
+				t.Errorf("Expected one pending delivery, got %+v", resp.Deliveries)
+			}
+		})
+	}
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"fruitsapi/internal/domain"
+)
+
+const (
+	// MaxSubscriptions is the largest number of subscriptions of a
+	// WebSocket connection
+	MaxSubscriptions = 32
+	// wsSendQueue is the number of messages a WebSocket connection can fall
+	// behind before it is closed, so that a slow client cannot stall others
+	wsSendQueue = 64
+	// wsMaxMessageBytes bounds the messages read from clients
+	wsMaxMessageBytes = 4096
+)
+
+// upgrader accepts WebSocket connections, checking that browsers connect
+// from the origin of the API
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// Subscribe handles GET /ws requests, upgrading to a WebSocket on which the
+// client subscribes to and unsubscribes from filters with ClientMessages and
+// receives the matching events of the fruits of the owner as ServerMessages.
+// The connection is kept alive with pings at the heartbeat interval.
+func (h *FruitHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	owner := r.Header.Get("Owner")
+	if owner == "" {
+		writeJSONError(w, "Owner header is required", http.StatusBadRequest)
+		return
+	}
+
+	if !h.openStream() {
+		writeJSONError(w, "Server is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer h.streams.Done()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has answered with the error
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	c := &wsConn{
+		conn:          conn,
+		owner:         owner,
+		version:       h.versionFromContext(r.Context()),
+		send:          make(chan ServerMessage, wsSendQueue),
+		overflow:      make(chan struct{}),
+		subscriptions: make(map[string]SubscriptionFilter),
+	}
+	changes, err := h.service.WatchFruits(ctx, owner, 0)
+	if err != nil {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseInternalServerErr, err.Error()), time.Now().Add(eventWriteTimeout))
+		return
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		c.readMessages(h.heartbeat * 2)
+	}()
+	go func() {
+		defer wg.Done()
+		defer cancel()
+		h.forwardChanges(ctx, c, changes)
+	}()
+
+	closeCode, reason := c.writeMessages(ctx, h.heartbeat, h.streamsClosed)
+	cancel()
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(closeCode, reason), time.Now().Add(eventWriteTimeout))
+	// Unblock the reader, which waits for the next message of the client
+	conn.Close()
+	wg.Wait()
+}
+
+// wsConn is a WebSocket connection with its subscriptions to the fruits of
+// owner
+type wsConn struct {
+	conn    *websocket.Conn
+	owner   string
+	version *APIVersion
+	// send is the queue of the messages to the client
+	send chan ServerMessage
+	// overflow is closed when send is full
+	overflow     chan struct{}
+	overflowOnce sync.Once
+
+	mu            sync.Mutex
+	subscriptions map[string]SubscriptionFilter
+}
+
+// enqueue queues msg without blocking, closing the connection when the
+// client falls too far behind
+func (c *wsConn) enqueue(msg ServerMessage) {
+	select {
+	case c.send <- msg:
+	default:
+		c.overflowOnce.Do(func() { close(c.overflow) })
+	}
+}
+
+// readMessages applies the messages of the client until the connection
+// fails or no pong arrives within pongWait
+func (c *wsConn) readMessages(pongWait time.Duration) {
+	c.conn.SetReadLimit(wsMaxMessageBytes)
+	c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		var msg ClientMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.enqueue(ServerMessage{Type: "error", Error: "invalid message: " + err.Error()})
+			continue
+		}
+		c.enqueue(c.apply(msg))
+	}
+}
+
+// apply changes the subscriptions as asked by msg and returns the reply
+func (c *wsConn) apply(msg ClientMessage) ServerMessage {
+	if msg.ID == "" {
+		return ServerMessage{Type: "error", Error: "id is required"}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch msg.Type {
+	case "subscribe":
+		if _, ok := c.subscriptions[msg.ID]; !ok && len(c.subscriptions) >= MaxSubscriptions {
+			return ServerMessage{Type: "error", ID: msg.ID, Error: fmt.Sprintf("at most %d subscriptions are allowed", MaxSubscriptions)}
+		}
+		var filter SubscriptionFilter
+		if msg.Filter != nil {
+			filter = *msg.Filter
+		}
+		if filter.Owner != "" && filter.Owner != c.owner {
+			return ServerMessage{Type: "error", ID: msg.ID, Error: "owner must be the Owner of the connection"}
+		}
+		if filter.LowStock != nil && *filter.LowStock < 0 {
+			return ServerMessage{Type: "error", ID: msg.ID, Error: "low_stock must not be negative"}
+		}
+		c.subscriptions[msg.ID] = filter
+		return ServerMessage{Type: "subscribed", ID: msg.ID}
+	case "unsubscribe":
+		if _, ok := c.subscriptions[msg.ID]; !ok {
+			return ServerMessage{Type: "error", ID: msg.ID, Error: "unknown subscription"}
+		}
+		delete(c.subscriptions, msg.ID)
+		return ServerMessage{Type: "unsubscribed", ID: msg.ID}
+	default:
+		return ServerMessage{Type: "error", ID: msg.ID, Error: "type must be subscribe or unsubscribe"}
+	}
+}
+
+// matching returns the subscriptions whose filter matches fruit, sorted
+func (c *wsConn) matching(fruit *domain.Fruit) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var ids []string
+	for id, filter := range c.subscriptions {
+		if filter.matches(fruit) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// matches reports whether fruit satisfies every field set in the filter
+func (f SubscriptionFilter) matches(fruit *domain.Fruit) bool {
+	switch {
+	case f.Owner != "" && fruit.Owner != f.Owner:
+		return false
+	case len(f.FruitIDs) > 0 && !slices.Contains(f.FruitIDs, fruit.ID):
+		return false
+	case f.Status != "" && fruit.Status != f.Status:
+		return false
+	case f.LowStock != nil && fruit.Quantity > *f.LowStock:
+		return false
+	}
+	return true
+}
+
+// forwardChanges queues the changes matching the subscriptions of c until
+// ctx is done. A lagging watch is resumed, or the client told to resync
+// when the changes it missed are no longer kept.
+func (h *FruitHandler) forwardChanges(ctx context.Context, c *wsConn, changes <-chan domain.FruitChange) {
+	for {
+		change, ok := <-changes
+		if !ok {
+			return
+		}
+		if change.Type == domain.ChangeLagged {
+			var err error
+			changes, err = h.service.WatchFruits(ctx, c.owner, change.Revision)
+			if errors.Is(err, domain.ErrChangesExpired) {
+				c.enqueue(ServerMessage{Type: "resync"})
+				changes, err = h.service.WatchFruits(ctx, c.owner, 0)
+			}
+			if err != nil {
+				return
+			}
+			continue
+		}
+
+		ids := c.matching(change.Fruit)
+		if len(ids) == 0 {
+			continue
+		}
+		event := &FruitEvent{
+			Type:     string(change.Type),
+			Revision: change.Revision,
+			Fruit:    c.version.toResponse(change.Fruit),
+		}
+		if change.Type == domain.ChangeStockChanged {
+			event.PreviousQuantity = &change.Previous.Quantity
+		}
+		c.enqueue(ServerMessage{Type: "event", Subscriptions: ids, Event: event})
+	}
+}
+
+// writeMessages sends the queued messages and the pings until ctx is done,
+// the streams are closed or the client falls behind, and returns the close
+// code to send
+func (c *wsConn) writeMessages(ctx context.Context, pingInterval time.Duration, closed <-chan struct{}) (int, string) {
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case msg := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				return websocket.CloseGoingAway, ""
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventWriteTimeout)); err != nil {
+				return websocket.CloseGoingAway, ""
+			}
+		case <-c.overflow:
+			return websocket.CloseTryAgainLater, "too slow, reconnect"
+		case <-closed:
+			return websocket.CloseGoingAway, "server shutting down"
+		case <-ctx.Done():
+			return websocket.CloseNormalClosure, ""
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"fruitsapi/internal/domain"
+)
+
+// dialWS opens a WebSocket on the /ws endpoint of server for the fruits of
+// owner test
+func dialWS(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"/ws", http.Header{"Owner": {"test"}})
+	if err != nil {
+		t.Fatalf("Failed to dial WebSocket: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status code %d, got %d", http.StatusSwitchingProtocols, resp.StatusCode)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readWS returns the next message of conn, failing after a second
+func readWS(t *testing.T, conn *websocket.Conn) ServerMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var msg ServerMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("Failed to read message: %v", err)
+	}
+	return msg
+}
+
+// sendWS sends msg on conn and returns the reply
+func sendWS(t *testing.T, conn *websocket.Conn, msg ClientMessage) ServerMessage {
+	t.Helper()
+	if err := conn.WriteJSON(msg); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	return readWS(t, conn)
+}
+
+func TestFruitHandler_Subscribe(t *testing.T) {
+	// Setup
+	_, svc, server := newEventsServer(t)
+	ctx := context.Background()
+	conn := dialWS(t, server.URL)
+	lowStock := 5
+	replies := []ServerMessage{
+		sendWS(t, conn, ClientMessage{Type: "subscribe", ID: "mine", Filter: &SubscriptionFilter{Owner: "test"}}),
+		sendWS(t, conn, ClientMessage{Type: "subscribe", ID: "low", Filter: &SubscriptionFilter{LowStock: &lowStock}}),
+	}
+	for _, reply := range replies {
+		if reply.Type != "subscribed" {
+			t.Fatalf("Expected subscribed, got %+v", reply)
+		}
+	}
+
+	// Action
+	fruit, err := svc.CreateFruit(ctx, "manzana", 12, 1000, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+	if _, err := svc.CreateFruit(ctx, "pera", 1, 1000, "other"); err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+	if _, err := svc.UpdateFruit(ctx, "test", fruit.ID, func(f *domain.Fruit) error { f.Quantity = 2; return nil }); err != nil {
+		t.Fatalf("Failed to update fruit: %v", err)
+	}
+	kiwi, err := svc.CreateFruit(ctx, "kiwi", 20, 1000, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+
+	// Assertions: the fruit of another owner is skipped even though it
+	// matches the low stock filter
+	tests := []struct {
+		event         string
+		id            string
+		subscriptions string
+	}{
+		{event: "created", id: fruit.ID, subscriptions: "mine"},
+		{event: "stock_changed", id: fruit.ID, subscriptions: "low,mine"},
+		{event: "created", id: kiwi.ID, subscriptions: "mine"},
+	}
+	for _, tt := range tests {
+		msg := readWS(t, conn)
+		if msg.Type != "event" || msg.Event == nil {
+			t.Fatalf("Expected an event, got %+v", msg)
+		}
+		fruitID := msg.Event.Fruit.(map[string]interface{})["id"]
+		if msg.Event.Type != tt.event || fruitID != tt.id {
+			t.Errorf("Expected %s of %s, got %s of %v", tt.event, tt.id, msg.Event.Type, fruitID)
+		}
+		if got := strings.Join(msg.Subscriptions, ","); got != tt.subscriptions {
+			t.Errorf("Expected subscriptions %s, got %s", tt.subscriptions, got)
+		}
+	}
+}
+
+func TestFruitHandler_SubscribeWithoutOwner(t *testing.T) {
+	// Setup
+	_, _, server := newEventsServer(t)
+
+	// Action
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/ws", nil)
+
+	// Assertions
+	if err == nil {
+		t.Fatalf("Expected the upgrade to fail")
+	}
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, resp.StatusCode)
+	}
+}
+
+func TestFruitHandler_Unsubscribe(t *testing.T) {
+	// Setup
+	_, svc, server := newEventsServer(t)
+	conn := dialWS(t, server.URL)
+	sendWS(t, conn, ClientMessage{Type: "subscribe", ID: "all"})
+
+	// Action
+	reply := sendWS(t, conn, ClientMessage{Type: "unsubscribe", ID: "all"})
+	if _, err := svc.CreateFruit(context.Background(), "manzana", 12, 1000, "test"); err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+
+	// Assertions: the next message is the reply to a later message, not an event
+	if reply.Type != "unsubscribed" || reply.ID != "all" {
+		t.Errorf("Expected unsubscribed all, got %+v", reply)
+	}
+	if msg := sendWS(t, conn, ClientMessage{Type: "unsubscribe", ID: "all"}); msg.Type != "error" {
+		t.Errorf("Expected an error for an unknown subscription, got %+v", msg)
+	}
+}
+
+func TestFruitHandler_SubscribeError(t *testing.T) {
+	negative := -1
+	tests := []struct {
+		name    string
+		message interface{}
+	}{
+		{name: "Invalid JSON", message: "not an object"},
+		{name: "Missing ID", message: ClientMessage{Type: "subscribe"}},
+		{name: "Unknown type", message: ClientMessage{Type: "publish", ID: "a"}},
+		{name: "Negative low stock", message: ClientMessage{Type: "subscribe", ID: "a", Filter: &SubscriptionFilter{LowStock: &negative}}},
+		{name: "Unknown subscription", message: ClientMessage{Type: "unsubscribe", ID: "a"}},
+		{name: "Other owner", message: ClientMessage{Type: "subscribe", ID: "a", Filter: &SubscriptionFilter{Owner: "other"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			_, _, server := newEventsServer(t)
+			conn := dialWS(t, server.URL)
+
+			// Action
+			if err := conn.WriteJSON(tt.message); err != nil {
+				t.Fatalf("Failed to send message: %v", err)
+			}
+
+			// Assertions: the connection stays open after an error
+			if msg := readWS(t, conn); msg.Type != "error" || msg.Error == "" {
+				t.Errorf("Expected an error, got %+v", msg)
+			}
+			if msg := sendWS(t, conn, ClientMessage{Type: "subscribe", ID: "b"}); msg.Type != "subscribed" {
+				t.Errorf("Expected subscribed, got %+v", msg)
+			}
+		})
+	}
+}
+
+func TestFruitHandler_SubscribePing(t *testing.T) {
+	// Setup
+	handler, _, server := newEventsServer(t)
+	handler.SetHeartbeatInterval(10 * time.Millisecond)
+	conn := dialWS(t, server.URL)
+	pings := make(chan struct{}, 1)
+	conn.SetPingHandler(func(string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	// Action: control frames are handled while reading
+	go conn.ReadMessage()
+
+	// Assertions
+	select {
+	case <-pings:
+	case <-time.After(time.Second):
+		t.Errorf("Expected a ping")
+	}
+}
+
+func TestFruitHandler_SubscribeShutdown(t *testing.T) {
+	// Setup
+	handler, _, server := newEventsServer(t)
+	conn := dialWS(t, server.URL)
+	sendWS(t, conn, ClientMessage{Type: "subscribe", ID: "all"})
+
+	// Action
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := handler.WaitEventStreams(ctx)
+
+	// Assertions
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, readErr := conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(readErr, &closeErr) || closeErr.Code != websocket.CloseGoingAway {
+		t.Errorf("Expected a going away close frame, got %v", readErr)
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/ws", nil)
+	req.Header.Set("Owner", "test")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected status code %d after shutdown, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
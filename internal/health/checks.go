@@ -0,0 +1,62 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"fruitsapi/pkg/kvs"
+)
+
+// kvsProbeKey is the key written and read back by the KVS check
+const kvsProbeKey = "health:probe"
+
+// errProbeDone rolls back the transaction of the KVS check
+var errProbeDone = errors.New("probe done")
+
+// KVSChecker verifies that the store answers reads and that a value written
+// in a transaction is read back through the codec, compression and
+// encryption of the store. The transaction is rolled back, so the check
+// never changes the store, its quota or its change feed.
+func KVSChecker(client *kvs.Client) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var got int64
+		if err := client.Get(ctx, kvsProbeKey, &got); err != nil && !errors.Is(err, kvs.ErrNotFound) {
+			return fmt.Errorf("error reading probe key: %w", err)
+		}
+
+		err := client.Update(ctx, func(tx *kvs.Tx) error {
+			want := time.Now().UnixNano()
+			if err := tx.Set(kvsProbeKey, want); err != nil {
+				return fmt.Errorf("error writing probe key: %w", err)
+			}
+			if err := tx.Get(kvsProbeKey, &got); err != nil {
+				return fmt.Errorf("error reading probe key: %w", err)
+			}
+			if got != want {
+				return fmt.Errorf("probe key round-trip mismatch: wrote %d, read %d", want, got)
+			}
+			return errProbeDone
+		}, kvs.Optimistic())
+		if !errors.Is(err, errProbeDone) {
+			return err
+		}
+		return nil
+	})
+}
+
+// DiskChecker verifies that the filesystem holding dir has at least
+// minFree bytes available. It always fails unless DiskSupported.
+func DiskChecker(dir string, minFree uint64) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		free, err := freeBytes(dir)
+		if err != nil {
+			return fmt.Errorf("error reading free disk space of %s: %w", dir, err)
+		}
+		if free < minFree {
+			return fmt.Errorf("only %d bytes free in %s, need %d", free, dir, minFree)
+		}
+		return nil
+	})
+}
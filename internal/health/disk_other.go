@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd)
+
+package health
+
+import "errors"
+
+// DiskSupported reports whether DiskChecker can read the free disk space
+const DiskSupported = false
+
+// freeBytes is not supported on this platform
+func freeBytes(dir string) (uint64, error) {
+	return 0, errors.New("free disk space check is not supported on this platform")
+}
@@ -0,0 +1,17 @@
+//go:build linux || darwin || freebsd
+
+package health
+
+import "syscall"
+
+// DiskSupported reports whether DiskChecker can read the free disk space
+const DiskSupported = true
+
+// freeBytes returns the bytes available to unprivileged users in the filesystem holding dir
+func freeBytes(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil
+}
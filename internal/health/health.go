@@ -0,0 +1,192 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fruitsapi/internal/openapi"
+)
+
+// Status values reported by the health endpoints
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusReady    = "ready"
+	StatusNotReady = "not_ready"
+)
+
+// Checker probes a single dependency
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to the Checker interface
+type CheckerFunc func(ctx context.Context) error
+
+// Check calls f(ctx)
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// CheckResult is the outcome of a single readiness check
+type CheckResult struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status" schema:"enum=ok|failing"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// Report is the body returned by the health endpoints
+type Report struct {
+	Status string        `json:"status" schema:"enum=ok|ready|not_ready"`
+	Checks []CheckResult `json:"checks,omitempty"`
+}
+
+// namedChecker is a Checker registered under a name
+type namedChecker struct {
+	name    string
+	checker Checker
+}
+
+// Registry holds the readiness checks and the draining state of the process
+type Registry struct {
+	timeout  time.Duration
+	draining atomic.Bool
+
+	mu       sync.RWMutex
+	checkers []namedChecker
+}
+
+// NewRegistry creates a new instance of Registry where every check is
+// bounded by timeout
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{
+		timeout: timeout,
+	}
+}
+
+// Register adds a readiness check
+func (r *Registry) Register(name string, checker Checker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checkers = append(r.checkers, namedChecker{name: name, checker: checker})
+}
+
+// Drain marks the process as not ready, so load balancers stop sending
+// traffic while in-flight requests complete
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// Check runs every registered check concurrently and returns the report
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checkers := append([]namedChecker(nil), r.checkers...)
+	r.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(checkers))
+	var wg sync.WaitGroup
+	for i, c := range checkers {
+		wg.Add(1)
+		go func(i int, c namedChecker) {
+			defer wg.Done()
+			results[i] = runCheck(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusReady, Checks: results}
+	if r.draining.Load() {
+		report.Status = StatusNotReady
+	}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusNotReady
+		}
+	}
+	return report
+}
+
+// runCheck executes a check, turning a timeout into a failure
+func runCheck(ctx context.Context, c namedChecker) CheckResult {
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.checker.Check(ctx) }()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	result := CheckResult{
+		Name:      c.name,
+		Status:    StatusOK,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// LivenessHandler answers GET /healthz: the process is up and serving
+func (r *Registry) LivenessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		writeReport(w, Report{Status: StatusOK}, http.StatusOK)
+	})
+}
+
+// ReadinessHandler answers GET /readyz with the result of every check,
+// using 503 when any check fails or the process is draining
+func (r *Registry) ReadinessHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		report := r.Check(req.Context())
+
+		status := http.StatusOK
+		if report.Status != StatusReady {
+			status = http.StatusServiceUnavailable
+		}
+		writeReport(w, report, status)
+	})
+}
+
+// writeReport writes a health report as JSON
+func writeReport(w http.ResponseWriter, report Report, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// DescribeRoutes adds the health endpoints to the OpenAPI document
+func (r *Registry) DescribeRoutes(doc *openapi.Document, livenessPath, readinessPath string) {
+	report := doc.Component(Report{})
+
+	doc.AddOperation(http.MethodGet, livenessPath, &openapi.Operation{
+		OperationID: "liveness",
+		Summary:     "Report whether the process is serving",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Process is alive", "application/json", report),
+		},
+	})
+	doc.AddOperation(http.MethodGet, readinessPath, &openapi.Operation{
+		OperationID: "readiness",
+		Summary:     "Probe the dependencies and report whether the process accepts traffic",
+		Tags:        []string{"health"},
+		Responses: map[string]*openapi.Response{
+			"200": openapi.JSONResponse("Every check passed", "application/json", report),
+			"503": openapi.JSONResponse("A check failed or the process is shutting down", "application/json", report),
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"fruitsapi/pkg/kvs"
+)
+
+func TestRegistry_ReadinessHandler(t *testing.T) {
+	tests := []struct {
+		name           string
+		checkers       map[string]Checker
+		drain          bool
+		expectedStatus int
+		expectedReport string
+	}{
+		{
+			name:           "AllChecksPassing",
+			checkers:       map[string]Checker{"kvs": KVSChecker(kvs.NewClient())},
+			expectedStatus: http.StatusOK,
+			expectedReport: StatusReady,
+		},
+		{
+			name: "FailingCheck",
+			checkers: map[string]Checker{"broken": CheckerFunc(func(ctx context.Context) error {
+				return errors.New("connection refused")
+			})},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: StatusNotReady,
+		},
+		{
+			name: "SlowCheck",
+			checkers: map[string]Checker{"slow": CheckerFunc(func(ctx context.Context) error {
+				time.Sleep(time.Second)
+				return nil
+			})},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: StatusNotReady,
+		},
+		{
+			name:           "Draining",
+			checkers:       map[string]Checker{"kvs": KVSChecker(kvs.NewClient())},
+			drain:          true,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedReport: StatusNotReady,
+		},
+		{
+			name:           "DiskCheck",
+			checkers:       map[string]Checker{"disk": DiskChecker(t.TempDir(), 1)},
+			expectedStatus: http.StatusOK,
+			expectedReport: StatusReady,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := NewRegistry(50 * time.Millisecond)
+			for name, checker := range tt.checkers {
+				registry.Register(name, checker)
+			}
+			if tt.drain {
+				registry.Drain()
+			}
+
+			recorder := httptest.NewRecorder()
+			registry.ReadinessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, recorder.Code)
+			}
+
+			var report Report
+			if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			if report.Status != tt.expectedReport {
+				t.Errorf("Expected report status %s, got %s", tt.expectedReport, report.Status)
+			}
+			if len(report.Checks) != len(tt.checkers) {
+				t.Errorf("Expected %d checks, got %d", len(tt.checkers), len(report.Checks))
+			}
+		})
+	}
+}
+
+func TestRegistry_LivenessHandler(t *testing.T) {
+	registry := NewRegistry(time.Second)
+	registry.Register("broken", CheckerFunc(func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}))
+
+	recorder := httptest.NewRecorder()
+	registry.LivenessHandler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// Liveness does not depend on the readiness checks
+	if recorder.Code != http.StatusOK {
+		t.Errorf("Expected status code %d, got %d", http.StatusOK, recorder.Code)
+	}
+}
+
+func TestKVSChecker(t *testing.T) {
+	// Setup: a full store, with a watcher
+	client := kvs.NewClient(kvs.WithQuota(kvs.Quota{MaxKeys: 1}))
+	ctx := context.Background()
+	if err := client.Set(ctx, "fruit/a", "manzana"); err != nil {
+		t.Fatalf("Failed to fill the store: %v", err)
+	}
+	revision := client.Revision()
+
+	// Action
+	err := KVSChecker(client).Check(ctx)
+
+	// Assertions: the probe leaves no trace
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if keys := client.Stats().Keys; keys != 1 {
+		t.Errorf("Expected 1 key, got %d", keys)
+	}
+	if client.Revision() != revision {
+		t.Errorf("Expected revision %d, got %d", revision, client.Revision())
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"fruitsapi/internal/handler"
 	"fruitsapi/internal/middleware"
 	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
 	"fruitsapi/internal/service"
 	"fruitsapi/pkg/kvs"
 )
@@ -31,27 +32,13 @@ func TestFruitsAPIIntegration(t *testing.T) {
 	fruitService := service.NewFruitService(fruitRepo)
 	fruitHandler := handler.NewFruitHandler(fruitService)
 
-	// Router setup (simplified version of the router in main.go)
-	router := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		path := r.URL.Path
-		
-		if path == "/fruits" && r.Method == http.MethodPost {
-			fruitHandler.CreateFruit(w, r)
-			return
-		}
-		
-		if len(path) > 8 && path[:8] == "/fruits/" && r.Method == http.MethodGet {
-			fruitHandler.GetFruitByID(w, r)
-			return
-		}
-		
-		w.WriteHeader(http.StatusNotFound)
-		json.NewEncoder(w).Encode(handler.ErrorResponse{Error: "Not found"})
-	})
+	// Router setup
+	routes := router.New(router.TrailingSlashRedirect)
+	fruitHandler.RegisterRoutes(routes)
 
 	// Apply middleware
 	handlerWithMiddleware := applyMiddleware(
-		router,
+		routes,
 		middleware.LoggingMiddleware,
 		middleware.ContentTypeValidator,
 		middleware.OwnerValidator,
@@ -162,7 +149,7 @@ func TestFruitsAPIIntegration(t *testing.T) {
 
 	// Test case: Try to get a non-existent fruit
 	t.Run("GetNonExistentFruit", func(t *testing.T) {
-		req, err := http.NewRequest(http.MethodGet, server.URL+"/fruits/non-existent-id", nil)
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/fruits/00000000-0000-4000-8000-000000000000", nil)
 		if err != nil {
 			t.Fatalf("Failed to create request: %v", err)
 		}
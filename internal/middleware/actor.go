@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"fruitsapi/internal/service"
+)
+
+// APIKeyHeader carries the API key of the clients acting without an Owner
+const APIKeyHeader = "X-API-Key"
+
+// Actor records who makes the request, for the audit log: owner:<Owner>,
+// or unverified_api_key:<fingerprint> so that the key itself is never
+// stored. No key is checked against a list of known keys, hence the
+// prefix. It must run inside RequestID to record the request ID.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := service.Actor{RequestID: RequestIDFromContext(r.Context())}
+		if owner := r.Header.Get("Owner"); owner != "" {
+			actor.Name = "owner:" + owner
+		} else if key := r.Header.Get(APIKeyHeader); key != "" {
+			actor.Name = "unverified_api_key:" + fingerprint(key)
+		}
+		next.ServeHTTP(w, r.WithContext(service.WithActor(r.Context(), actor)))
+	})
+}
+
+// fingerprint returns the first 12 hex digits of the SHA-256 of key
+func fingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
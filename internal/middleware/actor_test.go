@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fruitsapi/internal/service"
+)
+
+func TestActor(t *testing.T) {
+	tests := []struct {
+		name     string
+		owner    string
+		apiKey   string
+		expected string
+	}{
+		{name: "Owner", owner: "test", apiKey: "s3cr3t", expected: "owner:test"},
+		{name: "APIKey", apiKey: "s3cr3t", expected: "unverified_api_key:4e738ca5563c"},
+		{name: "Anonymous", expected: service.AnonymousActor},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			var got service.Actor
+			h := RequestID(Actor(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = service.ActorFromContext(r.Context())
+			})))
+			req := httptest.NewRequest(http.MethodPatch, "/fruits/1", nil)
+			req.Header.Set(RequestIDHeader, "req-1")
+			req.Header.Set("Owner", tt.owner)
+			req.Header.Set(APIKeyHeader, tt.apiKey)
+
+			// Action
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			// Assertions: a fingerprint of the API key, never the key
+			if got.Name != tt.expected || got.RequestID != "req-1" {
+				t.Errorf("Expected %s of req-1, got %s of %s", tt.expected, got.Name, got.RequestID)
+			}
+		})
+	}
+}
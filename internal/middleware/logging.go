@@ -1,31 +1,47 @@
 package middleware
 
 import (
+	"bufio"
+	"expvar"
 	"log"
+	"net"
 	"net/http"
 	"time"
+
+	"fruitsapi/internal/router"
 )
 
+// requestsByRoute counts requests by method and route template
+var requestsByRoute = expvar.NewMap("http_requests_by_route")
+
 // LoggingMiddleware logs information about HTTP requests
 func LoggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		startTime := time.Now()
-		
+		r, route := router.Capture(r)
+
 		// Create a custom ResponseWriter to capture the status code
 		crw := &customResponseWriter{
 			ResponseWriter: w,
 			statusCode:     http.StatusOK,
 		}
-		
+
 		// Call the next handler
 		next.ServeHTTP(crw, r)
-		
-		// Log request information
+
+		// Log request information, naming the route by its template
 		duration := time.Since(startTime)
+		template := route.Pattern
+		if template == "" {
+			template = "unmatched"
+		}
+		requestsByRoute.Add(r.Method+" "+template, 1)
 		log.Printf(
-			"[%s] %s %s %d %s",
+			"[%s] %s %s (%s) %s %d %s",
+			RequestIDFromContext(r.Context()),
 			r.Method,
 			r.RequestURI,
+			template,
 			r.RemoteAddr,
 			crw.statusCode,
 			duration,
@@ -44,3 +60,15 @@ func (crw *customResponseWriter) WriteHeader(statusCode int) {
 	crw.statusCode = statusCode
 	crw.ResponseWriter.WriteHeader(statusCode)
 }
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (crw *customResponseWriter) Unwrap() http.ResponseWriter {
+	return crw.ResponseWriter
+}
+
+// Hijack records the protocol switch of WebSocket upgrades and delegates to
+// the underlying ResponseWriter
+func (crw *customResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	crw.statusCode = http.StatusSwitchingProtocols
+	return http.NewResponseController(crw.ResponseWriter).Hijack()
+}
@@ -0,0 +1,166 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"fruitsapi/internal/handler"
+	"fruitsapi/internal/openapi"
+	"fruitsapi/internal/router"
+)
+
+// OpenAPIValidator validates the headers, path and query parameters and the
+// JSON body of each request against the operation of the OpenAPI document
+// for the matched route, answering 400 with every violation at once.
+// It must run inside the Router (see Router.Use) to know the matched route.
+// Bodies larger than maxBodyBytes are rejected with 413.
+//
+// operationPath, when not nil, returns the path of the operation describing
+// a request, such as the path of the version negotiated for an unversioned
+// route; the operation of the matched route is used when it returns a path
+// that is not in the document.
+func OpenAPIValidator(doc *openapi.Document, maxBodyBytes int64, operationPath func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method := r.Method
+			if method == http.MethodHead {
+				method = http.MethodGet
+			}
+			op, ok := doc.Operation(method, router.Template(r))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if operationPath != nil {
+				if negotiated, ok := doc.Operation(method, operationPath(r)); ok {
+					op = negotiated
+				}
+			}
+
+			violations := validateParameters(doc, op, r)
+
+			if op.RequestBody != nil {
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+				r.Body.Close()
+				var maxBytesErr *http.MaxBytesError
+				if errors.As(err, &maxBytesErr) {
+					writeJSONError(w, "Request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				if err != nil {
+					writeJSONError(w, "Error reading request body: "+err.Error(), http.StatusBadRequest)
+					return
+				}
+				// Restore the body for the handler
+				r.Body = io.NopCloser(bytes.NewReader(body))
+
+				if len(bytes.TrimSpace(body)) > 0 && !acceptsMediaType(op.RequestBody, r.Header.Get("Content-Type")) {
+					writeJSONError(w, "Content-Type must be one of "+strings.Join(mediaTypes(op.RequestBody), ", "), http.StatusUnsupportedMediaType)
+					return
+				}
+
+				violations = append(violations, validateBody(doc, op.RequestBody, r.Header.Get("Content-Type"), body)...)
+			}
+
+			if len(violations) > 0 {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(handler.ErrorResponse{
+					Error:      "request validation failed",
+					Violations: violations,
+				})
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validateParameters checks the header, path and query parameters of the operation
+func validateParameters(doc *openapi.Document, op *openapi.Operation, r *http.Request) []openapi.Violation {
+	var violations []openapi.Violation
+	query := r.URL.Query()
+
+	for _, p := range op.Parameters {
+		var raw string
+		var present bool
+		switch p.In {
+		case "header":
+			raw = r.Header.Get(p.Name)
+			present = raw != ""
+		case "path":
+			raw = router.Param(r, p.Name)
+			present = raw != ""
+		case "query":
+			raw = query.Get(p.Name)
+			present = query.Has(p.Name)
+		}
+		violations = append(violations, doc.ValidateParameter(p, raw, present)...)
+	}
+	return violations
+}
+
+// validateBody checks a JSON body against the schema declared for its media type
+func validateBody(doc *openapi.Document, rb *openapi.RequestBody, contentType string, body []byte) []openapi.Violation {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if rb.Required {
+			return []openapi.Violation{{In: "body", Message: "is required"}}
+		}
+		return nil
+	}
+
+	content, ok := mediaTypeContent(rb, contentType)
+	if !ok {
+		return []openapi.Violation{{In: "header", Field: "Content-Type", Message: "must be one of the media types accepted by the operation"}}
+	}
+	schema := content.Schema
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var value interface{}
+	if err := dec.Decode(&value); err != nil {
+		return []openapi.Violation{{In: "body", Message: "must be valid JSON: " + err.Error()}}
+	}
+	return doc.Validate(schema, value, "body", "")
+}
+
+// mediaTypeContent returns the content declared for the media type of a
+// Content-Type header
+func mediaTypeContent(rb *openapi.RequestBody, contentType string) (*openapi.MediaType, bool) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if content, ok := rb.Content[mediaType]; ok {
+		return content, true
+	}
+	if len(rb.Content) == 1 {
+		// A single JSON schema also applies to vendor types such as
+		// application/vnd.fruits.v2+json, ContentTypeValidator vets the rest
+		for _, content := range rb.Content {
+			return content, true
+		}
+	}
+	return nil, false
+}
+
+// acceptsMediaType reports whether the operation declares a body for the
+// media type of a Content-Type header
+func acceptsMediaType(rb *openapi.RequestBody, contentType string) bool {
+	_, ok := mediaTypeContent(rb, contentType)
+	return ok
+}
+
+// mediaTypes lists the media types accepted by the operation, sorted
+func mediaTypes(rb *openapi.RequestBody) []string {
+	types := make([]string, 0, len(rb.Content))
+	for mediaType := range rb.Content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	return types
+}
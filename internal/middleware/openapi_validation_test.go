@@ -0,0 +1,252 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fruitsapi/internal/handler"
+	"fruitsapi/internal/openapi"
+	"fruitsapi/internal/repository"
+	"fruitsapi/internal/router"
+	"fruitsapi/internal/service"
+	"fruitsapi/pkg/kvs"
+)
+
+// missingID is a fruit ID that no test creates
+const missingID = "00000000-0000-4000-8000-000000000000"
+
+func newValidatedRouter() *router.Router {
+	fruitHandler := handler.NewFruitHandler(service.NewFruitService(repository.NewKVSFruitRepository(kvs.NewClient())))
+	doc := openapi.NewDocument(openapi.Info{Title: "test", Version: "1"})
+	routes := router.New(router.TrailingSlashRedirect)
+	fruitHandler.RegisterRoutes(routes)
+	fruitHandler.DescribeRoutes(doc)
+	routes.Use(OpenAPIValidator(doc, handler.DefaultMaxBodyBytes, fruitHandler.OperationPath))
+	return routes
+}
+
+func TestOpenAPIValidator(t *testing.T) {
+	tests := []struct {
+		name               string
+		path               string
+		accept             string
+		owner              string
+		body               string
+		expectedStatus     int
+		expectedViolations map[string]bool
+	}{
+		{
+			name:           "ValidRequest",
+			path:           "/fruits",
+			owner:          "test",
+			body:           `{"name":"manzana","quantity":12,"price":1000}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:           "EveryViolationReported",
+			path:           "/fruits",
+			body:           `{"name":"manzana1","quantity":"12","price":0,"nmae":"x"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedViolations: map[string]bool{
+				"header Owner":  true,
+				"body name":     true,
+				"body quantity": true,
+				"body price":    true,
+				"body nmae":     true,
+			},
+		},
+		{
+			name:               "MissingRequiredProperty",
+			path:               "/v2/fruits",
+			owner:              "test",
+			body:               `{"name":"manzana","quantity":12,"price":1000}`,
+			expectedStatus:     http.StatusBadRequest,
+			expectedViolations: map[string]bool{"body unit_price": true, "body price": true},
+		},
+		{
+			name:           "V2AcceptNegotiation",
+			path:           "/fruits",
+			accept:         "application/vnd.fruits.v2+json",
+			owner:          "test",
+			body:           `{"name":"manzana","quantity":12,"unit_price":1000}`,
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name:               "V1BodyWithV2Accept",
+			path:               "/fruits",
+			accept:             "application/vnd.fruits.v2+json",
+			owner:              "test",
+			body:               `{"name":"manzana","quantity":12,"price":1000}`,
+			expectedStatus:     http.StatusBadRequest,
+			expectedViolations: map[string]bool{"body unit_price": true, "body price": true},
+		},
+		{
+			name:               "InvalidJSON",
+			path:               "/fruits",
+			owner:              "test",
+			body:               `{"name":`,
+			expectedStatus:     http.StatusBadRequest,
+			expectedViolations: map[string]bool{"body ": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := newValidatedRouter()
+			req := httptest.NewRequest(http.MethodPost, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.owner != "" {
+				req.Header.Set("Owner", tt.owner)
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			recorder := httptest.NewRecorder()
+
+			routes.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.expectedViolations == nil {
+				return
+			}
+
+			var response handler.ErrorResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			got := make(map[string]bool)
+			for _, v := range response.Violations {
+				got[v.In+" "+v.Field] = true
+			}
+			for key := range tt.expectedViolations {
+				if !got[key] {
+					t.Errorf("Expected violation %q, got %+v", key, response.Violations)
+				}
+			}
+			if len(got) != len(tt.expectedViolations) {
+				t.Errorf("Expected %d violations, got %+v", len(tt.expectedViolations), response.Violations)
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidator_MediaTypes(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentType    string
+		body           string
+		expectedStatus int
+	}{
+		{
+			name:           "MergePatch",
+			contentType:    handler.MergePatchMediaType,
+			body:           `{"price":900}`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "JSONPatch",
+			contentType:    handler.JSONPatchMediaType,
+			body:           `[{"op":"replace","path":"/price","value":900}]`,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "InvalidJSONPatch",
+			contentType:    handler.JSONPatchMediaType,
+			body:           `[{"op":"merge","path":"/price"}]`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "UndeclaredMediaType",
+			contentType:    "application/json",
+			body:           `{"price":900}`,
+			expectedStatus: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := newValidatedRouter()
+			req := httptest.NewRequest(http.MethodPatch, "/fruits/"+missingID, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			req.Header.Set("Owner", "test")
+			recorder := httptest.NewRecorder()
+
+			routes.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d: %s", tt.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestOpenAPIValidator_FruitIDs(t *testing.T) {
+	tests := []struct {
+		name              string
+		method            string
+		path              string
+		body              string
+		expectedStatus    int
+		expectedViolation string
+	}{
+		{
+			name:           "UUID",
+			method:         http.MethodGet,
+			path:           "/fruits/" + missingID,
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:              "PathID",
+			method:            http.MethodGet,
+			path:              "/fruits/audit",
+			expectedStatus:    http.StatusBadRequest,
+			expectedViolation: "path id",
+		},
+		{
+			name:              "QueryIDs",
+			method:            http.MethodGet,
+			path:              "/fruits?ids=" + missingID + ",audit/head",
+			expectedStatus:    http.StatusBadRequest,
+			expectedViolation: "query ids[1]",
+		},
+		{
+			name:              "BatchID",
+			method:            http.MethodPost,
+			path:              "/fruits:batch",
+			body:              `[{"op":"delete","id":"audit/head"}]`,
+			expectedStatus:    http.StatusBadRequest,
+			expectedViolation: "body [0].id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := newValidatedRouter()
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Owner", "test")
+			recorder := httptest.NewRecorder()
+
+			routes.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.expectedStatus {
+				t.Fatalf("Expected status code %d, got %d: %s", tt.expectedStatus, recorder.Code, recorder.Body.String())
+			}
+			if tt.expectedViolation == "" {
+				return
+			}
+			var response handler.ErrorResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+				t.Fatalf("Error decoding response body: %v", err)
+			}
+			if len(response.Violations) != 1 || response.Violations[0].In+" "+response.Violations[0].Field != tt.expectedViolation {
+				t.Errorf("Expected violation %q, got %+v", tt.expectedViolation, response.Violations)
+			}
+		})
+	}
+}
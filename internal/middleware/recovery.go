@@ -0,0 +1,98 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"expvar"
+	"log"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"fruitsapi/internal/handler"
+)
+
+// panicsTotal counts the panics recovered by the Recovery middleware
+var panicsTotal = expvar.NewInt("http_panics_total")
+
+// Recovery catches panics raised by the next handlers, logs them with the
+// stack trace and request ID, and answers with a 500 problem response when
+// nothing has been written to the client yet
+func Recovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoveryResponseWriter{ResponseWriter: w}
+
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			// http.ErrAbortHandler is the documented way to abort a response
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			panicsTotal.Add(1)
+
+			// The request ID is set on the response by the inner RequestID middleware
+			requestID := w.Header().Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = r.Header.Get(RequestIDHeader)
+			}
+			log.Printf("panic recovered [request_id=%s] %s %s: %v\n%s", requestID, r.Method, r.RequestURI, rec, debug.Stack())
+
+			if rw.wroteHeader {
+				// Headers are already on the wire, the best we can do is abort the connection
+				panic(http.ErrAbortHandler)
+			}
+
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(handler.ProblemResponse{
+				Type:      "about:blank",
+				Title:     http.StatusText(http.StatusInternalServerError),
+				Status:    http.StatusInternalServerError,
+				Detail:    "an unexpected error occurred while processing the request",
+				Instance:  r.URL.Path,
+				RequestID: requestID,
+			})
+		}()
+
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// PanicCount returns the number of panics recovered since the process started
+func PanicCount() int64 {
+	return panicsTotal.Value()
+}
+
+// recoveryResponseWriter tracks whether the response headers have been sent
+type recoveryResponseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader records that the headers were sent and delegates to the underlying ResponseWriter
+func (rw *recoveryResponseWriter) WriteHeader(statusCode int) {
+	rw.wroteHeader = true
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write records that the headers were sent and delegates to the underlying ResponseWriter
+func (rw *recoveryResponseWriter) Write(b []byte) (int, error) {
+	rw.wroteHeader = true
+	return rw.ResponseWriter.Write(b)
+}
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController
+func (rw *recoveryResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Hijack records that the connection was taken over and delegates to the
+// underlying ResponseWriter
+func (rw *recoveryResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rw.wroteHeader = true
+	return http.NewResponseController(rw.ResponseWriter).Hijack()
+}
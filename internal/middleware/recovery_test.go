@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fruitsapi/internal/handler"
+)
+
+func TestRecovery(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	h := Recovery(RequestID(panicking))
+
+	before := PanicCount()
+
+	req := httptest.NewRequest(http.MethodGet, "/fruits/1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	recorder := httptest.NewRecorder()
+
+	h.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status code %d, got %d", http.StatusInternalServerError, recorder.Code)
+	}
+	if ct := recorder.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Expected content type %s, got %s", "application/problem+json", ct)
+	}
+
+	var problem handler.ProblemResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&problem); err != nil {
+		t.Fatalf("Error decoding response body: %v", err)
+	}
+	if problem.Status != http.StatusInternalServerError {
+		t.Errorf("Expected problem status %d, got %d", http.StatusInternalServerError, problem.Status)
+	}
+	if problem.RequestID != "req-1" {
+		t.Errorf("Expected request ID %s, got %s", "req-1", problem.RequestID)
+	}
+
+	if PanicCount() != before+1 {
+		t.Errorf("Expected panic count %d, got %d", before+1, PanicCount())
+	}
+}
+
+func TestRecovery_HeadersAlreadyWritten(t *testing.T) {
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	})
+	h := Recovery(panicking)
+
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Errorf("Expected panic %v, got %v", http.ErrAbortHandler, rec)
+		}
+	}()
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/fruits/1", nil))
+}
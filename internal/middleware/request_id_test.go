@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		reused   bool
+	}{
+		{name: "Valid", incoming: "req-1.a_B", reused: true},
+		{name: "Missing", incoming: ""},
+		{name: "Too long", incoming: strings.Repeat("a", 65)},
+		{name: "Invalid characters", incoming: "req 1\" injected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			var got string
+			h := RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = RequestIDFromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/fruits", nil)
+			req.Header.Set(RequestIDHeader, tt.incoming)
+			recorder := httptest.NewRecorder()
+
+			// Action
+			h.ServeHTTP(recorder, req)
+
+			// Assertions
+			if tt.reused && got != tt.incoming {
+				t.Errorf("Expected request ID %s, got %s", tt.incoming, got)
+			}
+			if !tt.reused && (got == tt.incoming || !requestIDPattern.MatchString(got)) {
+				t.Errorf("Expected a fresh request ID, got %q", got)
+			}
+			if header := recorder.Header().Get(RequestIDHeader); header != got {
+				t.Errorf("Expected header %s, got %s", got, header)
+			}
+		})
+	}
+}
@@ -2,8 +2,9 @@ package middleware
 
 import (
 	"encoding/json"
+	"mime"
 	"net/http"
-	"regexp"
+	"strings"
 
 	"fruitsapi/internal/handler"
 )
@@ -11,10 +12,12 @@ import (
 // ContentTypeValidator ensures that the request has the correct Content-Type header
 func ContentTypeValidator(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only check Content-Type for POST requests with a non-empty body
+		// Only check Content-Type for POST requests with a non-empty body,
+		// accepting application/json and vendor types such as
+		// application/vnd.fruits.v2+json
 		if r.Method == http.MethodPost && r.ContentLength > 0 {
-			contentType := r.Header.Get("Content-Type")
-			if contentType != "application/json" {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || (mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json")) {
 				writeJSONError(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
 				return
 			}
@@ -23,10 +26,11 @@ func ContentTypeValidator(next http.Handler) http.Handler {
 	})
 }
 
-// OwnerValidator validates that the Owner header is present for POST requests
+// OwnerValidator validates that the Owner header is present for POST
+// requests, except on the admin endpoints, which act on no fruit
 func OwnerValidator(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
+		if r.Method == http.MethodPost && !strings.HasPrefix(r.URL.Path, "/admin/") {
 			owner := r.Header.Get("Owner")
 			if owner == "" {
 				writeJSONError(w, "Owner header is required", http.StatusBadRequest)
@@ -37,47 +41,6 @@ func OwnerValidator(next http.Handler) http.Handler {
 	})
 }
 
-// FruitRequestValidator validates the fruit request body
-func FruitRequestValidator(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost && r.URL.Path == "/fruits" {
-			var req handler.CreateFruitRequest
-			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-				writeJSONError(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
-				return
-			}
-			
-			// Reset request body for the next handler to read
-			r.Body.Close()
-			r.Body = http.MaxBytesReader(w, r.Body, 1048576) // 1MB limit
-			
-			// Validate name - must be a string without numbers or special characters
-			if req.Name == "" {
-				writeJSONError(w, "name cannot be empty", http.StatusBadRequest)
-				return
-			}
-			namePattern := regexp.MustCompile(`^[a-zA-Z\s]+$`)
-			if !namePattern.MatchString(req.Name) {
-				writeJSONError(w, "name must contain only letters and spaces", http.StatusBadRequest)
-				return
-			}
-			
-			// Validate quantity - must be greater than 0
-			if req.Quantity <= 0 {
-				writeJSONError(w, "quantity must be greater than 0", http.StatusBadRequest)
-				return
-			}
-			
-			// Validate price - must be greater than 0
-			if req.Price <= 0 {
-				writeJSONError(w, "price must be greater than 0", http.StatusBadRequest)
-				return
-			}
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
 // Helper function to write JSON error responses
 func writeJSONError(w http.ResponseWriter, message string, status int) {
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,152 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Version is the OpenAPI version of the generated documents
+const Version = "3.1.0"
+
+// Document is the root of an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info holds the API metadata
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// Components holds the reusable schemas referenced from operations
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// PathItem holds the operations available on a path, keyed by lowercase method
+type PathItem map[string]*Operation
+
+// Operation describes a single API operation on a path
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter describes a path, query or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+	// Explode false sends the items of an array query parameter as one
+	// comma-separated value
+	Explode *bool `json:"explode,omitempty"`
+}
+
+// RequestBody describes the accepted request bodies by media type
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response describes a response by status code
+type Response struct {
+	Description string                `json:"description"`
+	Headers     map[string]*Header    `json:"headers,omitempty"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// Header describes a response header
+type Header struct {
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// MediaType holds the schema of a body for a media type
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// NewDocument creates an empty document
+func NewDocument(info Info) *Document {
+	return &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}
+}
+
+// AddOperation registers an operation for an HTTP method and a path template
+func (d *Document) AddOperation(method, path string, op *Operation) {
+	item, ok := d.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		d.Paths[path] = item
+	}
+	(*item)[strings.ToLower(method)] = op
+}
+
+// Operation returns the operation registered for a method and a path template
+func (d *Document) Operation(method, path string) (*Operation, bool) {
+	item, ok := d.Paths[path]
+	if !ok {
+		return nil, false
+	}
+	op, ok := (*item)[strings.ToLower(method)]
+	return op, ok
+}
+
+// Handler serves the document as JSON
+func Handler(d *Document) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := json.MarshalIndent(d, "", "  ")
+		if err != nil {
+			http.Error(w, "error encoding OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
+
+// JSONBody returns a request body accepting the schema as application/json
+func JSONBody(schema *Schema) *RequestBody {
+	return &RequestBody{
+		Required: true,
+		Content:  map[string]*MediaType{"application/json": {Schema: schema}},
+	}
+}
+
+// JSONResponse returns a response with a body in the given media type
+func JSONResponse(description, mediaType string, schema *Schema) *Response {
+	return &Response{
+		Description: description,
+		Content:     map[string]*MediaType{mediaType: {Schema: schema}},
+	}
+}
+
+// DescribeRoutes adds the operation serving the document itself
+func (d *Document) DescribeRoutes(path string) {
+	d.AddOperation(http.MethodGet, path, &Operation{
+		OperationID: "openapi",
+		Summary:     "Get this OpenAPI document",
+		Tags:        []string{"meta"},
+		Responses: map[string]*Response{
+			"200": JSONResponse("OpenAPI document", "application/json", &Schema{Type: "object"}),
+		},
+	})
+}
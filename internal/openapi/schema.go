@@ -0,0 +1,200 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is the subset of JSON Schema 2020-12 used by the API
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+}
+
+// Constrainer is implemented by the types that complete the schema
+// generated from them, with constraints defined elsewhere than in their
+// struct tags
+type Constrainer interface {
+	ConstrainSchema(s *Schema)
+}
+
+// refPrefix is the location of the component schemas
+const refPrefix = "#/components/schemas/"
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// Ref returns a schema referencing a component by name
+func Ref(name string) *Schema {
+	return &Schema{Ref: refPrefix + name}
+}
+
+// String returns a string schema
+func String() *Schema {
+	return &Schema{Type: "string"}
+}
+
+// Component registers the schema generated from the Go type of v under
+// the type name and returns a reference to it
+func (d *Document) Component(v interface{}) *Schema {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if _, ok := d.Components.Schemas[t.Name()]; !ok {
+		d.Components.Schemas[t.Name()] = SchemaOf(t)
+	}
+	return Ref(t.Name())
+}
+
+// Resolve follows a component reference, returning s itself otherwise
+func (d *Document) Resolve(s *Schema) *Schema {
+	if s == nil || s.Ref == "" {
+		return s
+	}
+	return d.Components.Schemas[strings.TrimPrefix(s.Ref, refPrefix)]
+}
+
+// SchemaOf generates a schema from a Go type using its json tags.
+// Constraints are read from the `schema` tag as semicolon-separated
+// key=value pairs, e.g. `schema:"minLength=1;pattern=^[a-z]+$"`, and
+// fields without omitempty are required. Structs implementing Constrainer
+// then complete their schema.
+func SchemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == rawMessageType:
+		// Any JSON value
+		return &Schema{}
+	case t.Kind() == reflect.String:
+		return &Schema{Type: "string"}
+	case t.Kind() == reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return &Schema{Type: "number"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return &Schema{Type: "array", Items: SchemaOf(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return &Schema{Type: "object"}
+	case t.Kind() == reflect.Struct:
+		s := structSchema(t)
+		if c, ok := reflect.New(t).Interface().(Constrainer); ok {
+			c.ConstrainSchema(s)
+		}
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+// structSchema generates an object schema from the exported fields of t
+func structSchema(t reflect.Type) *Schema {
+	closed := false
+	s := &Schema{
+		Type:                 "object",
+		Properties:           make(map[string]*Schema),
+		AdditionalProperties: &closed,
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := SchemaOf(f.Type)
+		applyConstraints(prop, f.Tag.Get("schema"))
+		if desc := f.Tag.Get("description"); desc != "" {
+			prop.Description = desc
+		}
+		s.Properties[name] = prop
+
+		if !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+	return s
+}
+
+// applyConstraints reads the `schema` struct tag into s
+func applyConstraints(s *Schema, tag string) {
+	if tag == "" {
+		return
+	}
+	for _, pair := range strings.Split(tag, ";") {
+		key, value, _ := strings.Cut(pair, "=")
+		switch key {
+		case "pattern":
+			s.Pattern = value
+		case "format":
+			s.Format = value
+		case "enum":
+			s.Enum = strings.Split(value, "|")
+		case "minLength":
+			s.MinLength = intPtr(value)
+		case "maxLength":
+			s.MaxLength = intPtr(value)
+		case "minItems":
+			s.MinItems = intPtr(value)
+		case "minimum":
+			s.Minimum = floatPtr(value)
+		case "exclusiveMinimum":
+			s.ExclusiveMinimum = floatPtr(value)
+		case "maximum":
+			s.Maximum = floatPtr(value)
+		default:
+			panic(fmt.Sprintf("openapi: unknown schema constraint %q", key))
+		}
+	}
+}
+
+func intPtr(s string) *int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		panic(fmt.Sprintf("openapi: invalid integer constraint %q", s))
+	}
+	return &n
+}
+
+func floatPtr(s string) *float64 {
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		panic(fmt.Sprintf("openapi: invalid number constraint %q", s))
+	}
+	return &n
+}
@@ -0,0 +1,76 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testFruit struct {
+	Name      string    `json:"name" schema:"minLength=1;pattern=^[a-z]+$"`
+	Quantity  int       `json:"quantity" schema:"exclusiveMinimum=0"`
+	Tags      []string  `json:"tags,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	internal  string
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := SchemaOf(reflect.TypeOf(testFruit{}))
+
+	if s.Type != "object" {
+		t.Errorf("Expected type %s, got %s", "object", s.Type)
+	}
+	if len(s.Properties) != 4 {
+		t.Errorf("Expected %d properties, got %d", 4, len(s.Properties))
+	}
+	if !reflect.DeepEqual(s.Required, []string{"name", "quantity", "created_at"}) {
+		t.Errorf("Expected required [name quantity created_at], got %v", s.Required)
+	}
+	if s.AdditionalProperties == nil || *s.AdditionalProperties {
+		t.Error("Expected additionalProperties to be false")
+	}
+
+	name := s.Properties["name"]
+	if name.Pattern != "^[a-z]+$" || name.MinLength == nil || *name.MinLength != 1 {
+		t.Errorf("Expected name constraints to be read from the tag, got %+v", name)
+	}
+	if q := s.Properties["quantity"]; q.Type != "integer" || q.ExclusiveMinimum == nil || *q.ExclusiveMinimum != 0 {
+		t.Errorf("Expected quantity to be an integer with exclusiveMinimum 0, got %+v", q)
+	}
+	if c := s.Properties["created_at"]; c.Type != "string" || c.Format != "date-time" {
+		t.Errorf("Expected created_at to be a date-time string, got %+v", c)
+	}
+	if tags := s.Properties["tags"]; tags.Type != "array" || tags.Items.Type != "string" {
+		t.Errorf("Expected tags to be an array of strings, got %+v", tags)
+	}
+}
+
+// testStatus constrains its status outside of its tags
+type testStatus struct {
+	Status string `json:"status"`
+}
+
+func (testStatus) ConstrainSchema(s *Schema) {
+	s.Properties["status"].Enum = []string{"fresh", "rotten"}
+}
+
+func TestSchemaOf_Constrainer(t *testing.T) {
+	s := SchemaOf(reflect.TypeOf(&testStatus{}))
+
+	if got := s.Properties["status"].Enum; !reflect.DeepEqual(got, []string{"fresh", "rotten"}) {
+		t.Errorf("Expected enum [fresh rotten], got %v", got)
+	}
+}
+
+func TestDocument_Component(t *testing.T) {
+	doc := NewDocument(Info{Title: "test", Version: "1"})
+
+	ref := doc.Component(&testFruit{})
+
+	if ref.Ref != "#/components/schemas/testFruit" {
+		t.Errorf("Expected ref %s, got %s", "#/components/schemas/testFruit", ref.Ref)
+	}
+	if resolved := doc.Resolve(ref); resolved == nil || resolved.Type != "object" {
+		t.Errorf("Expected the reference to resolve to the object schema, got %+v", resolved)
+	}
+}
@@ -0,0 +1,273 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Violation describes a value that does not satisfy its schema
+type Violation struct {
+	// In is where the value was found: body, header, path or query
+	In string `json:"in"`
+	// Field is the name of the parameter or the path of the body property
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// patterns caches the compiled schema patterns
+var patterns sync.Map
+
+// uuidPattern matches the strings of the uuid format
+const uuidPattern = `^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`
+
+// Validate checks a value decoded with json.Decoder.UseNumber against the
+// schema and returns every violation found
+func (d *Document) Validate(schema *Schema, value interface{}, in, field string) []Violation {
+	var violations []Violation
+	d.validate(schema, value, in, field, &violations)
+	return violations
+}
+
+// ValidateParameter coerces a raw parameter string to the type of its
+// schema and validates it
+func (d *Document) ValidateParameter(p *Parameter, raw string, present bool) []Violation {
+	if !present {
+		if p.Required {
+			return []Violation{{In: p.In, Field: p.Name, Message: "is required"}}
+		}
+		return nil
+	}
+
+	schema := d.Resolve(p.Schema)
+	value, err := coerce(schema, raw)
+	if err != nil {
+		return []Violation{{In: p.In, Field: p.Name, Message: err.Error()}}
+	}
+	return d.Validate(schema, value, p.In, p.Name)
+}
+
+func (d *Document) validate(schema *Schema, value interface{}, in, field string, violations *[]Violation) {
+	schema = d.Resolve(schema)
+	if schema == nil {
+		return
+	}
+	add := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{In: in, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if len(schema.OneOf) > 0 {
+		matches := 0
+		for _, candidate := range schema.OneOf {
+			if len(d.Validate(candidate, value, in, field)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			add("must match exactly one of the allowed shapes")
+		}
+		return
+	}
+
+	if schema.Type != "" && !hasType(schema.Type, value) {
+		add("must be of type %s, got %s", schema.Type, typeOf(value))
+		return
+	}
+
+	switch v := value.(type) {
+	case string:
+		validateString(schema, v, add)
+	case json.Number:
+		n, _ := v.Float64()
+		validateNumber(schema, n, add)
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			add("must contain at least %d items", *schema.MinItems)
+		}
+		for i, item := range v {
+			d.validate(schema.Items, item, in, fmt.Sprintf("%s[%d]", field, i), violations)
+		}
+	case map[string]interface{}:
+		d.validateObject(schema, v, in, field, violations, add)
+	}
+}
+
+// validateObject checks the required, declared and undeclared properties
+func (d *Document) validateObject(schema *Schema, obj map[string]interface{}, in, field string, violations *[]Violation, add func(string, ...interface{})) {
+	for _, name := range schema.Required {
+		if _, ok := obj[name]; !ok {
+			*violations = append(*violations, Violation{In: in, Field: join(field, name), Message: "is required"})
+		}
+	}
+
+	names := make([]string, 0, len(obj))
+	for name := range obj {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		prop, ok := schema.Properties[name]
+		if !ok {
+			if schema.AdditionalProperties != nil && !*schema.AdditionalProperties {
+				*violations = append(*violations, Violation{In: in, Field: join(field, name), Message: "is not an allowed property"})
+			}
+			continue
+		}
+		d.validate(prop, obj[name], in, join(field, name), violations)
+	}
+}
+
+func validateString(schema *Schema, s string, add func(string, ...interface{})) {
+	if len(schema.Enum) > 0 && !contains(schema.Enum, s) {
+		add("must be one of %s", strings.Join(schema.Enum, ", "))
+	}
+	length := utf8.RuneCountInString(s)
+	if schema.MinLength != nil && length < *schema.MinLength {
+		if *schema.MinLength == 1 {
+			add("cannot be empty")
+		} else {
+			add("must be at least %d characters long", *schema.MinLength)
+		}
+	}
+	if schema.MaxLength != nil && length > *schema.MaxLength {
+		add("must be at most %d characters long", *schema.MaxLength)
+	}
+	if schema.Pattern != "" && s != "" && !compile(schema.Pattern).MatchString(s) {
+		add("must match the pattern %s", schema.Pattern)
+	}
+	switch schema.Format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, s); err != nil {
+			add("must be an RFC 3339 date-time")
+		}
+	case "uuid":
+		if !compile(uuidPattern).MatchString(s) {
+			add("must be a UUID")
+		}
+	}
+}
+
+func validateNumber(schema *Schema, n float64, add func(string, ...interface{})) {
+	if schema.Minimum != nil && n < *schema.Minimum {
+		add("must be greater than or equal to %v", *schema.Minimum)
+	}
+	if schema.ExclusiveMinimum != nil && n <= *schema.ExclusiveMinimum {
+		add("must be greater than %v", *schema.ExclusiveMinimum)
+	}
+	if schema.Maximum != nil && n > *schema.Maximum {
+		add("must be less than or equal to %v", *schema.Maximum)
+	}
+}
+
+// hasType reports whether a decoded JSON value has the JSON Schema type
+func hasType(schemaType string, value interface{}) bool {
+	switch schemaType {
+	case "object":
+		_, ok := value.(map[string]interface{})
+		return ok
+	case "array":
+		_, ok := value.([]interface{})
+		return ok
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := strconv.ParseInt(n.String(), 10, 64)
+		return err == nil
+	case "null":
+		return value == nil
+	}
+	return true
+}
+
+// typeOf names the JSON type of a decoded value
+func typeOf(value interface{}) string {
+	switch value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case nil:
+		return "null"
+	}
+	return "unknown"
+}
+
+// coerce converts a raw parameter to the JSON type of its schema
+func coerce(schema *Schema, raw string) (interface{}, error) {
+	if schema == nil {
+		return raw, nil
+	}
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, fmt.Errorf("must be of type %s", schema.Type)
+		}
+		return json.Number(raw), nil
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, fmt.Errorf("must be of type boolean")
+		}
+		return b, nil
+	case "array":
+		var items []interface{}
+		for _, part := range strings.Split(raw, ",") {
+			item, err := coerce(schema.Items, part)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return raw, nil
+}
+
+func compile(pattern string) *regexp.Regexp {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re := regexp.MustCompile(pattern)
+	patterns.Store(pattern, re)
+	return re
+}
+
+func join(field, name string) string {
+	if field == "" {
+		return name
+	}
+	return field + "." + name
+}
+
+func contains(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
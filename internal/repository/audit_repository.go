@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"fruitsapi/internal/domain"
+)
+
+// AuditFilter selects audit entries, the zero value of a field matching
+// every entry
+type AuditFilter struct {
+	FruitID string
+	Actor   string
+	// From and To bound the time of the entries, both included
+	From time.Time
+	To   time.Time
+	// After skips the entries up to this sequence number
+	After uint64
+}
+
+// AuditRepository reads the audit log, which is only appended to by
+// FruitTx.AddToAudit
+type AuditRepository interface {
+	// List returns up to limit entries matching filter, oldest first
+	List(ctx context.Context, filter AuditFilter, limit int) ([]domain.AuditEntry, error)
+
+	// Verify checks the chain of hashes of every entry and returns the
+	// number of entries and the hash of the last one. It fails with
+	// domain.ErrAuditChainBroken at the first entry altered, removed or
+	// inserted.
+	Verify(ctx context.Context) (int, string, error)
+}
@@ -6,11 +6,47 @@ import (
 	"fruitsapi/internal/domain"
 )
 
-// FruitRepository defines the interface for fruit storage operations
+// FruitRepository defines the interface for fruit storage operations.
+// Fruits are only written in a Transaction, along with their audit entry
+// and their events.
 type FruitRepository interface {
-	// Save stores a fruit and returns the stored fruit with its ID
-	Save(ctx context.Context, fruit *domain.Fruit) (*domain.Fruit, error)
-	
 	// GetByID retrieves a fruit by its ID
 	GetByID(ctx context.Context, id string) (*domain.Fruit, error)
+
+	// GetByIDs retrieves several fruits in one call. The result is aligned
+	// with ids and holds nil for the fruits that do not exist.
+	GetByIDs(ctx context.Context, ids []string) ([]*domain.Fruit, error)
+
+	// Transaction runs fn with a FruitTx and commits its changes if fn
+	// returns nil, so that either all of them are stored or none
+	Transaction(ctx context.Context, fn func(tx FruitTx) error) error
+
+	// Watch returns the changes of every fruit in order, from now on or
+	// after revision when it is not 0, failing with domain.ErrChangesExpired
+	// when those changes are no longer kept. Updates are reported as
+	// domain.ChangeUpdated. The channel is closed when ctx is done, or after
+	// a domain.ChangeLagged change if the receiver falls behind.
+	Watch(ctx context.Context, revision uint64) (<-chan domain.FruitChange, error)
+}
+
+// FruitTx reads and writes fruits inside a transaction. Reads see the
+// writes made earlier in the same transaction.
+type FruitTx interface {
+	// GetByID retrieves a fruit by its ID
+	GetByID(id string) (*domain.Fruit, error)
+
+	// Save stores a fruit
+	Save(fruit *domain.Fruit) error
+
+	// Delete removes a fruit by its ID
+	Delete(id string) error
+
+	// AddToOutbox records events in the outbox, so that they are stored
+	// if and only if the other changes of the transaction are
+	AddToOutbox(events ...domain.Event) error
+
+	// AddToAudit appends an entry to the audit log, chained to the last
+	// one, so that it is stored if and only if the other changes of the
+	// transaction are
+	AddToAudit(entry domain.AuditEntry) error
 }
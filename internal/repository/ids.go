@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// lastSortableID is the last ID given by nextSortableID
+var lastSortableID atomic.Uint64
+
+// nextSortableID returns an ID greater than every ID given before, also
+// across restarts as it follows the clock. IDs are fixed-width hexadecimal
+// so that the key order is the order of the IDs.
+func nextSortableID() string {
+	for {
+		last := lastSortableID.Load()
+		next := max(last+1, uint64(time.Now().UnixNano()))
+		if lastSortableID.CompareAndSwap(last, next) {
+			return fmt.Sprintf("%016x", next)
+		}
+	}
+}
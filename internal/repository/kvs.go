@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// update runs fn in a transaction of client. Errors returned by fn are
+// passed through unchanged, a commit over the KVS quota fails with
+// domain.ErrStorageFull.
+func update(ctx context.Context, client *kvs.Client, fn func(tx *kvs.Tx) error) error {
+	err := client.Update(ctx, fn)
+	if errors.Is(err, kvs.ErrQuotaExceeded) {
+		return domain.ErrStorageFull
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// Keys of the audit log: the entries by sequence number, zero-padded so
+// that the key order is the order of the entries, and the head of the chain.
+// They are out of reach of the fruit methods, which only use fruitPrefix.
+const (
+	auditEntryPrefix = "audit/entry/"
+	auditHeadKey     = "audit/head"
+)
+
+// auditHead is the last entry of the audit log
+type auditHead struct {
+	Seq  uint64 `json:"seq"`
+	Hash string `json:"hash"`
+}
+
+// auditKey returns the key of the entry with sequence number seq
+func auditKey(seq uint64) string {
+	return fmt.Sprintf("%s%020d", auditEntryPrefix, seq)
+}
+
+// AddToAudit appends an entry to the audit log of the transaction. Every
+// entry reads and writes the single head of the chain, so that audited
+// transactions on different fruits, which otherwise only lock their own
+// shards, serialize on the shard of the head; the throughput of the
+// writes is bounded by it, see BenchmarkKVSAuditRepository_ParallelWrites.
+func (t kvsFruitTx) AddToAudit(entry domain.AuditEntry) error {
+	var head auditHead
+	if err := t.tx.Get(auditHeadKey, &head); err != nil && !errors.Is(err, kvs.ErrNotFound) {
+		return fmt.Errorf("error retrieving audit head from KVS: %w", err)
+	}
+
+	entry.Seq = head.Seq + 1
+	entry.PrevHash = head.Hash
+	hash, err := entry.ComputeHash()
+	if err != nil {
+		return err
+	}
+	entry.Hash = hash
+	if err := t.tx.Set(auditKey(entry.Seq), entry); err != nil {
+		return fmt.Errorf("error saving audit entry to KVS: %w", err)
+	}
+	if err := t.tx.Set(auditHeadKey, auditHead{Seq: entry.Seq, Hash: entry.Hash}); err != nil {
+		return fmt.Errorf("error saving audit head to KVS: %w", err)
+	}
+	return nil
+}
+
+// KVSAuditRepository implements AuditRepository using a KVS client
+type KVSAuditRepository struct {
+	client *kvs.Client
+}
+
+// NewKVSAuditRepository creates a new instance of KVSAuditRepository
+func NewKVSAuditRepository(client *kvs.Client) *KVSAuditRepository {
+	return &KVSAuditRepository{
+		client: client,
+	}
+}
+
+// List returns the matching entries from the KVS
+func (r *KVSAuditRepository) List(ctx context.Context, filter AuditFilter, limit int) ([]domain.AuditEntry, error) {
+	var entries []domain.AuditEntry
+	err := r.scan(ctx, func(entry domain.AuditEntry) error {
+		switch {
+		case entry.Seq <= filter.After,
+			filter.FruitID != "" && entry.FruitID != filter.FruitID,
+			filter.Actor != "" && entry.Actor != filter.Actor,
+			!filter.From.IsZero() && entry.At.Before(filter.From),
+			!filter.To.IsZero() && entry.At.After(filter.To):
+			return nil
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) == limit {
+			return errScanDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errScanDone) {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Verify checks the chain of the entries stored in the KVS
+func (r *KVSAuditRepository) Verify(ctx context.Context) (int, string, error) {
+	var last auditHead
+	err := r.scan(ctx, func(entry domain.AuditEntry) error {
+		if entry.Seq != last.Seq+1 {
+			return fmt.Errorf("%w: entry %d follows entry %d", domain.ErrAuditChainBroken, entry.Seq, last.Seq)
+		}
+		if entry.PrevHash != last.Hash {
+			return fmt.Errorf("%w: entry %d does not chain to entry %d", domain.ErrAuditChainBroken, entry.Seq, last.Seq)
+		}
+		hash, err := entry.ComputeHash()
+		if err != nil {
+			return err
+		}
+		if hash != entry.Hash {
+			return fmt.Errorf("%w: entry %d was altered", domain.ErrAuditChainBroken, entry.Seq)
+		}
+		last = auditHead{Seq: entry.Seq, Hash: entry.Hash}
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	// The head catches the removal of the last entries
+	var head auditHead
+	if err := r.client.Get(ctx, auditHeadKey, &head); err != nil && !errors.Is(err, kvs.ErrNotFound) {
+		return 0, "", fmt.Errorf("error retrieving audit head from KVS: %w", err)
+	}
+	if head != last {
+		return 0, "", fmt.Errorf("%w: the log ends at entry %d but its head is entry %d", domain.ErrAuditChainBroken, last.Seq, head.Seq)
+	}
+	return int(last.Seq), last.Hash, nil
+}
+
+// scan calls fn for every entry in order. Errors returned by fn are passed
+// through unchanged.
+func (r *KVSAuditRepository) scan(ctx context.Context, fn func(entry domain.AuditEntry) error) error {
+	var fnErr error
+	err := r.client.Scan(ctx, auditEntryPrefix, func(e kvs.ScanEntry) error {
+		var entry domain.AuditEntry
+		if err := e.Decode(&entry); err != nil {
+			return fmt.Errorf("error decoding audit entry %s: %w", e.Key, err)
+		}
+		fnErr = fn(entry)
+		return fnErr
+	})
+	if err != nil && fnErr == nil {
+		return fmt.Errorf("error listing audit entries from KVS: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,235 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// audit appends an entry per fruit ID by actor, one transaction each
+func audit(t *testing.T, repo *KVSFruitRepository, actor string, fruitIDs ...string) {
+	t.Helper()
+	for _, id := range fruitIDs {
+		err := repo.Transaction(context.Background(), func(tx FruitTx) error {
+			entry, err := domain.NewAuditEntry(actor, "req", domain.AuditCreate, nil, &domain.Fruit{ID: id})
+			if err != nil {
+				return err
+			}
+			return tx.AddToAudit(entry)
+		})
+		if err != nil {
+			t.Fatalf("Failed to audit: %v", err)
+		}
+	}
+}
+
+func TestKVSAuditRepository_List(t *testing.T) {
+	// Setup
+	client := kvs.NewClient(kvs.WithShards(4))
+	fruits := NewKVSFruitRepository(client)
+	repo := NewKVSAuditRepository(client)
+	audit(t, fruits, "owner:alice", "a", "b")
+	middle := time.Now().UTC()
+	audit(t, fruits, "owner:bob", "a", "c")
+
+	tests := []struct {
+		name     string
+		filter   AuditFilter
+		limit    int
+		expected []uint64
+	}{
+		{name: "All", expected: []uint64{1, 2, 3, 4}},
+		{name: "Limit", limit: 3, expected: []uint64{1, 2, 3}},
+		{name: "After", filter: AuditFilter{After: 2}, limit: 1, expected: []uint64{3}},
+		{name: "Fruit", filter: AuditFilter{FruitID: "a"}, expected: []uint64{1, 3}},
+		{name: "Actor", filter: AuditFilter{Actor: "owner:bob"}, expected: []uint64{3, 4}},
+		{name: "From", filter: AuditFilter{From: middle}, expected: []uint64{3, 4}},
+		{name: "To", filter: AuditFilter{To: middle}, expected: []uint64{1, 2}},
+		{name: "NoMatch", filter: AuditFilter{FruitID: "c", Actor: "owner:alice"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			entries, err := repo.List(context.Background(), tt.filter, tt.limit)
+
+			// Assertions: oldest first
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.expected), len(entries))
+			}
+			for i, entry := range entries {
+				if entry.Seq != tt.expected[i] {
+					t.Errorf("Expected entry %d, got %d", tt.expected[i], entry.Seq)
+				}
+			}
+		})
+	}
+}
+
+func TestKVSAuditRepository_Chain(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	fruits := NewKVSFruitRepository(client)
+	repo := NewKVSAuditRepository(client)
+	ctx := context.Background()
+	audit(t, fruits, "owner:alice", "a", "b", "c")
+
+	// Action
+	entries, _ := repo.List(ctx, AuditFilter{}, 0)
+	n, head, err := repo.Verify(ctx)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 3 || head != entries[2].Hash {
+		t.Errorf("Expected 3 entries ending with %s, got %d ending with %s", entries[2].Hash, n, head)
+	}
+	for i, entry := range entries {
+		previous := ""
+		if i > 0 {
+			previous = entries[i-1].Hash
+		}
+		if entry.PrevHash != previous {
+			t.Errorf("Expected entry %d to chain to %q, got %q", entry.Seq, previous, entry.PrevHash)
+		}
+	}
+}
+
+func TestKVSAuditRepository_Tampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(client *kvs.Client, entries []domain.AuditEntry) error
+	}{
+		{
+			name: "AlteredEntry",
+			tamper: func(client *kvs.Client, entries []domain.AuditEntry) error {
+				entries[1].Actor = "owner:mallory"
+				return client.Set(context.Background(), auditKey(2), entries[1])
+			},
+		},
+		{
+			name: "RehashedEntry",
+			tamper: func(client *kvs.Client, entries []domain.AuditEntry) error {
+				entries[1].Actor = "owner:mallory"
+				entries[1].Hash, _ = entries[1].ComputeHash()
+				return client.Set(context.Background(), auditKey(2), entries[1])
+			},
+		},
+		{
+			name: "RemovedEntry",
+			tamper: func(client *kvs.Client, entries []domain.AuditEntry) error {
+				return client.Delete(context.Background(), auditKey(2))
+			},
+		},
+		{
+			name: "RemovedLastEntry",
+			tamper: func(client *kvs.Client, entries []domain.AuditEntry) error {
+				return client.Delete(context.Background(), auditKey(3))
+			},
+		},
+		{
+			name: "InsertedEntry",
+			tamper: func(client *kvs.Client, entries []domain.AuditEntry) error {
+				inserted := entries[2]
+				inserted.Seq = 4
+				return client.Set(context.Background(), auditKey(4), inserted)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := kvs.NewClient()
+			fruits := NewKVSFruitRepository(client)
+			repo := NewKVSAuditRepository(client)
+			audit(t, fruits, "owner:alice", "a", "b", "c")
+			entries, _ := repo.List(context.Background(), AuditFilter{}, 0)
+			if err := tt.tamper(client, entries); err != nil {
+				t.Fatalf("Failed to tamper: %v", err)
+			}
+
+			// Action
+			_, _, err := repo.Verify(context.Background())
+
+			// Assertions
+			if !errors.Is(err, domain.ErrAuditChainBroken) {
+				t.Errorf("Expected %v, got %v", domain.ErrAuditChainBroken, err)
+			}
+		})
+	}
+}
+
+func TestKVSAuditRepository_RolledBack(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	fruits := NewKVSFruitRepository(client)
+	repo := NewKVSAuditRepository(client)
+	audit(t, fruits, "owner:alice", "a")
+
+	// Action: the entry is dropped with the transaction
+	err := fruits.Transaction(context.Background(), func(tx FruitTx) error {
+		entry, _ := domain.NewAuditEntry("owner:alice", "req", domain.AuditCreate, nil, &domain.Fruit{ID: "b"})
+		if err := tx.AddToAudit(entry); err != nil {
+			return err
+		}
+		return fmt.Errorf("boom")
+	})
+	audit(t, fruits, "owner:alice", "c")
+
+	// Assertions
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	n, _, err := repo.Verify(context.Background())
+	if err != nil || n != 2 {
+		t.Errorf("Expected a valid chain of 2 entries, got %d (%v)", n, err)
+	}
+}
+
+// BenchmarkKVSAuditRepository_ParallelWrites saves distinct fruits in
+// parallel, with and without an audit entry, to measure the cost of every
+// audited write updating the single head of the chain
+func BenchmarkKVSAuditRepository_ParallelWrites(b *testing.B) {
+	for _, audited := range []bool{false, true} {
+		b.Run(fmt.Sprintf("audited=%v", audited), func(b *testing.B) {
+			repo := NewKVSFruitRepository(kvs.NewClient())
+			ctx := context.Background()
+			var n atomic.Int64
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					fruit := domain.NewFruit(uuid.NewString(), "Apple", 1, 1, fmt.Sprintf("owner%d", n.Add(1)))
+					err := repo.Transaction(ctx, func(tx FruitTx) error {
+						if err := tx.Save(fruit); err != nil {
+							return err
+						}
+						if !audited {
+							return nil
+						}
+						entry, err := domain.NewAuditEntry("owner:"+fruit.Owner, "req", domain.AuditCreate, nil, fruit)
+						if err != nil {
+							return err
+						}
+						return tx.AddToAudit(entry)
+					})
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
@@ -2,12 +2,22 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"fruitsapi/internal/domain"
 	"fruitsapi/pkg/kvs"
 )
 
+// fruitPrefix namespaces the fruit keys apart from the other records kept
+// in the KVS, such as the outbox and the audit log
+const fruitPrefix = "fruit/"
+
+// fruitKey returns the KVS key of a fruit
+func fruitKey(id string) string {
+	return fruitPrefix + id
+}
+
 // KVSFruitRepository implements FruitRepository using a KVS client
 type KVSFruitRepository struct {
 	client *kvs.Client
@@ -20,19 +30,142 @@ func NewKVSFruitRepository(client *kvs.Client) *KVSFruitRepository {
 	}
 }
 
-// Save stores a fruit in the KVS
-func (r *KVSFruitRepository) Save(ctx context.Context, fruit *domain.Fruit) (*domain.Fruit, error) {
-	if err := r.client.Set(ctx, fruit.ID, fruit); err != nil {
-		return nil, fmt.Errorf("error saving fruit to KVS: %w", err)
+// GetByID retrieves a fruit from the KVS by its ID
+func (r *KVSFruitRepository) GetByID(ctx context.Context, id string) (*domain.Fruit, error) {
+	var fruit domain.Fruit
+	if err := r.client.Get(ctx, fruitKey(id), &fruit); err != nil {
+		if errors.Is(err, kvs.ErrNotFound) {
+			return nil, domain.ErrFruitNotFound
+		}
+		return nil, fmt.Errorf("error retrieving fruit from KVS: %w", err)
 	}
-	return fruit, nil
+	return &fruit, nil
 }
 
-// GetByID retrieves a fruit from the KVS by its ID
-func (r *KVSFruitRepository) GetByID(ctx context.Context, id string) (*domain.Fruit, error) {
+// GetByIDs retrieves several fruits from the KVS with a single MGet
+func (r *KVSFruitRepository) GetByIDs(ctx context.Context, ids []string) ([]*domain.Fruit, error) {
+	fruits := make([]domain.Fruit, len(ids))
+	keys := make([]string, len(ids))
+	targets := make([]interface{}, len(ids))
+	for i := range fruits {
+		keys[i] = fruitKey(ids[i])
+		targets[i] = &fruits[i]
+	}
+
+	errs, err := r.client.MGet(ctx, keys, targets)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving fruits from KVS: %w", err)
+	}
+
+	result := make([]*domain.Fruit, len(ids))
+	for i, err := range errs {
+		switch {
+		case errors.Is(err, kvs.ErrNotFound):
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("error retrieving fruit %s from KVS: %w", ids[i], err)
+		}
+		result[i] = &fruits[i]
+	}
+	return result, nil
+}
+
+// Transaction runs fn in a KVS transaction, see update
+func (r *KVSFruitRepository) Transaction(ctx context.Context, fn func(tx FruitTx) error) error {
+	return update(ctx, r.client, func(tx *kvs.Tx) error {
+		return fn(kvsFruitTx{tx: tx})
+	})
+}
+
+// kvsFruitTx implements FruitTx on a KVS transaction
+type kvsFruitTx struct {
+	tx *kvs.Tx
+}
+
+// GetByID retrieves a fruit from the transaction by its ID
+func (t kvsFruitTx) GetByID(id string) (*domain.Fruit, error) {
 	var fruit domain.Fruit
-	if err := r.client.Get(ctx, id, &fruit); err != nil {
+	if err := t.tx.Get(fruitKey(id), &fruit); err != nil {
+		if errors.Is(err, kvs.ErrNotFound) {
+			return nil, domain.ErrFruitNotFound
+		}
 		return nil, fmt.Errorf("error retrieving fruit from KVS: %w", err)
 	}
 	return &fruit, nil
 }
+
+// Save stores a fruit in the transaction
+func (t kvsFruitTx) Save(fruit *domain.Fruit) error {
+	if err := t.tx.Set(fruitKey(fruit.ID), fruit); err != nil {
+		return fmt.Errorf("error saving fruit to KVS: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a fruit in the transaction
+func (t kvsFruitTx) Delete(id string) error {
+	if !t.tx.Exists(fruitKey(id)) {
+		return domain.ErrFruitNotFound
+	}
+	t.tx.Delete(fruitKey(id))
+	return nil
+}
+
+// Watch returns the changes of every fruit from the KVS change feed. Only
+// the fruit keys are watched, so the writes of the outbox, the audit log
+// and the webhooks neither wake the watcher nor count against its buffer.
+func (r *KVSFruitRepository) Watch(ctx context.Context, revision uint64) (<-chan domain.FruitChange, error) {
+	events, err := r.client.Watch(ctx, fruitPrefix, kvs.FromRevision(revision))
+	if errors.Is(err, kvs.ErrCompacted) {
+		return nil, domain.ErrChangesExpired
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error watching KVS: %w", err)
+	}
+
+	changes := make(chan domain.FruitChange)
+	go func() {
+		defer close(changes)
+		for e := range events {
+			change, ok := fruitChange(e)
+			if !ok {
+				continue
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
+
+// fruitChange converts a KVS event of a fruit key into a fruit change,
+// skipping records that cannot be decoded
+func fruitChange(e kvs.Event) (domain.FruitChange, bool) {
+	change := domain.FruitChange{Revision: e.Revision}
+	switch {
+	case e.Op == kvs.OpResync:
+		change.Type = domain.ChangeLagged
+		return change, true
+	case e.Op == kvs.OpDelete:
+		change.Type = domain.ChangeDeleted
+		change.Fruit = &domain.Fruit{}
+		return change, e.DecodePrevious(change.Fruit) == nil
+	}
+
+	change.Type = domain.ChangeCreated
+	change.Fruit = &domain.Fruit{}
+	if err := e.Decode(change.Fruit); err != nil {
+		return change, false
+	}
+	if e.OldVersion != 0 {
+		change.Type = domain.ChangeUpdated
+		change.Previous = &domain.Fruit{}
+		if err := e.DecodePrevious(change.Previous); err != nil {
+			return change, false
+		}
+	}
+	return change, true
+}
@@ -2,13 +2,25 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"fruitsapi/internal/domain"
 	"fruitsapi/pkg/kvs"
 )
 
-func TestKVSFruitRepository_Save(t *testing.T) {
+// saveFruit stores fruit in a transaction of repo
+func saveFruit(t *testing.T, repo *KVSFruitRepository, fruit *domain.Fruit) {
+	t.Helper()
+	err := repo.Transaction(context.Background(), func(tx FruitTx) error {
+		return tx.Save(fruit)
+	})
+	if err != nil {
+		t.Fatalf("Failed to save fruit: %v", err)
+	}
+}
+
+func TestKVSFruitRepository_TransactionSave(t *testing.T) {
 	// Setup
 	client := kvs.NewClient()
 	repo := NewKVSFruitRepository(client)
@@ -18,15 +30,16 @@ func TestKVSFruitRepository_Save(t *testing.T) {
 	fruit := domain.NewFruit("test-id", "manzana", 12, 1000, "test")
 
 	// Action
-	savedFruit, err := repo.Save(ctx, fruit)
+	err := repo.Transaction(ctx, func(tx FruitTx) error {
+		return tx.Save(fruit)
+	})
 
 	// Assertions
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
-
-	if savedFruit.ID != fruit.ID {
-		t.Errorf("Expected ID %s, got %s", fruit.ID, savedFruit.ID)
+	if ok, _ := client.Exists(ctx, fruitKey(fruit.ID)); !ok {
+		t.Errorf("Expected fruit %s to be stored", fruit.ID)
 	}
 }
 
@@ -40,10 +53,7 @@ func TestKVSFruitRepository_GetByID(t *testing.T) {
 	fruit := domain.NewFruit("test-id", "manzana", 12, 1000, "test")
 
 	// Save first
-	_, err := repo.Save(ctx, fruit)
-	if err != nil {
-		t.Fatalf("Failed to save fruit: %v", err)
-	}
+	saveFruit(t, repo, fruit)
 
 	// Action
 	retrievedFruit, err := repo.GetByID(ctx, fruit.ID)
@@ -84,3 +94,57 @@ func TestKVSFruitRepository_GetByID_NotFound(t *testing.T) {
 		t.Fatal("Expected error, got nil")
 	}
 }
+
+func TestKVSFruitRepository_GetByIDs(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := NewKVSFruitRepository(client)
+	ctx := context.Background()
+
+	// Save first
+	fruit := domain.NewFruit("test-id", "manzana", 12, 1000, "test")
+	saveFruit(t, repo, fruit)
+
+	// Action
+	fruits, err := repo.GetByIDs(ctx, []string{"non-existent-id", fruit.ID})
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(fruits) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(fruits))
+	}
+	if fruits[0] != nil {
+		t.Errorf("Expected nil for a missing fruit, got %+v", fruits[0])
+	}
+	if fruits[1] == nil || fruits[1].ID != fruit.ID {
+		t.Errorf("Expected fruit %s, got %+v", fruit.ID, fruits[1])
+	}
+}
+
+func TestKVSFruitRepository_TransactionDelete(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := NewKVSFruitRepository(client)
+	ctx := context.Background()
+
+	// Save first
+	fruit := domain.NewFruit("test-id", "manzana", 12, 1000, "test")
+	saveFruit(t, repo, fruit)
+	deleteFruit := func(tx FruitTx) error { return tx.Delete(fruit.ID) }
+
+	// Action
+	err := repo.Transaction(ctx, deleteFruit)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, fruit.ID); !errors.Is(err, domain.ErrFruitNotFound) {
+		t.Errorf("Expected %v, got %v", domain.ErrFruitNotFound, err)
+	}
+	if err := repo.Transaction(ctx, deleteFruit); !errors.Is(err, domain.ErrFruitNotFound) {
+		t.Errorf("Expected %v deleting twice, got %v", domain.ErrFruitNotFound, err)
+	}
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// Key prefixes of the outbox entries. Records other than fruits are kept
+// under prefixes ending in a slash, which fruit IDs never contain.
+const (
+	outboxPendingPrefix = "outbox/pending/"
+	outboxDeadPrefix    = "outbox/dead/"
+	// outboxBlockedPrefix keys the IDs of the dead letters of each
+	// aggregate, in order
+	outboxBlockedPrefix = "outbox/blocked/"
+)
+
+// AddToOutbox records events in the outbox of the transaction
+func (t kvsFruitTx) AddToOutbox(events ...domain.Event) error {
+	for _, e := range events {
+		entry, err := domain.NewOutboxEntry(nextSortableID(), e)
+		if err != nil {
+			return err
+		}
+		if err := t.tx.Set(outboxPendingPrefix+entry.ID, entry); err != nil {
+			return fmt.Errorf("error saving outbox entry to KVS: %w", err)
+		}
+	}
+	return nil
+}
+
+// KVSOutboxRepository implements OutboxRepository using a KVS client
+type KVSOutboxRepository struct {
+	client *kvs.Client
+}
+
+// NewKVSOutboxRepository creates a new instance of KVSOutboxRepository
+func NewKVSOutboxRepository(client *kvs.Client) *KVSOutboxRepository {
+	return &KVSOutboxRepository{
+		client: client,
+	}
+}
+
+// errScanDone stops a scan once enough entries are found
+var errScanDone = errors.New("scan done")
+
+// Pending lists the pending entries from the KVS
+func (r *KVSOutboxRepository) Pending(ctx context.Context, limit int) ([]domain.OutboxEntry, error) {
+	return r.list(ctx, outboxPendingPrefix, "", limit)
+}
+
+// PendingAfter lists the pending entries from the KVS whose ID comes after
+// the given one
+func (r *KVSOutboxRepository) PendingAfter(ctx context.Context, after string, limit int) ([]domain.OutboxEntry, error) {
+	return r.list(ctx, outboxPendingPrefix, after, limit)
+}
+
+// DeadLetters lists the dead letters from the KVS
+func (r *KVSOutboxRepository) DeadLetters(ctx context.Context, limit int) ([]domain.OutboxEntry, error) {
+	return r.list(ctx, outboxDeadPrefix, "", limit)
+}
+
+// list returns up to limit entries stored under prefix, in key order,
+// starting after the ID after unless it is ""
+func (r *KVSOutboxRepository) list(ctx context.Context, prefix, after string, limit int) ([]domain.OutboxEntry, error) {
+	var entries []domain.OutboxEntry
+	err := r.client.Scan(ctx, prefix, func(e kvs.ScanEntry) error {
+		if after != "" && e.Key <= prefix+after {
+			return nil
+		}
+		var entry domain.OutboxEntry
+		if err := e.Decode(&entry); err != nil {
+			return fmt.Errorf("error decoding outbox entry %s: %w", e.Key, err)
+		}
+		entries = append(entries, entry)
+		if limit > 0 && len(entries) == limit {
+			return errScanDone
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errScanDone) {
+		return nil, fmt.Errorf("error listing outbox entries from KVS: %w", err)
+	}
+	return entries, nil
+}
+
+// Delivered removes a pending entry from the KVS
+func (r *KVSOutboxRepository) Delivered(ctx context.Context, id string) error {
+	if err := r.client.Delete(ctx, outboxPendingPrefix+id); err != nil {
+		if errors.Is(err, kvs.ErrNotFound) {
+			return domain.ErrOutboxEntryNotFound
+		}
+		return fmt.Errorf("error deleting outbox entry from KVS: %w", err)
+	}
+	return nil
+}
+
+// Reschedule stores a pending entry in the KVS, unless it is gone
+func (r *KVSOutboxRepository) Reschedule(ctx context.Context, entry domain.OutboxEntry) error {
+	return update(ctx, r.client, func(tx *kvs.Tx) error {
+		if !tx.Exists(outboxPendingPrefix + entry.ID) {
+			return domain.ErrOutboxEntryNotFound
+		}
+		return tx.Set(outboxPendingPrefix+entry.ID, entry)
+	})
+}
+
+// DeadLetter moves a pending entry to the dead letters of its aggregate in
+// one transaction
+func (r *KVSOutboxRepository) DeadLetter(ctx context.Context, entry domain.OutboxEntry) error {
+	return update(ctx, r.client, func(tx *kvs.Tx) error {
+		if !tx.Exists(outboxPendingPrefix + entry.ID) {
+			return domain.ErrOutboxEntryNotFound
+		}
+		ids, err := deadLetterIDs(tx, entry.AggregateID)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, entry.ID)
+		slices.Sort(ids)
+		if err := tx.Set(outboxBlockedPrefix+entry.AggregateID, ids); err != nil {
+			return fmt.Errorf("error saving outbox dead letters to KVS: %w", err)
+		}
+		tx.Delete(outboxPendingPrefix + entry.ID)
+		return tx.Set(outboxDeadPrefix+entry.ID, entry)
+	})
+}
+
+// OldestDeadLetter returns the ID of the oldest dead letter of an aggregate
+// from the KVS, or "" when it has none
+func (r *KVSOutboxRepository) OldestDeadLetter(ctx context.Context, aggregateID string) (string, error) {
+	var ids []string
+	if err := r.client.Get(ctx, outboxBlockedPrefix+aggregateID, &ids); err != nil {
+		if errors.Is(err, kvs.ErrNotFound) {
+			return "", nil
+		}
+		return "", fmt.Errorf("error retrieving outbox dead letters from KVS: %w", err)
+	}
+	if len(ids) == 0 {
+		return "", nil
+	}
+	return ids[0], nil
+}
+
+// deadLetterIDs returns the IDs of the dead letters of an aggregate in a
+// transaction, oldest first
+func deadLetterIDs(tx *kvs.Tx, aggregateID string) ([]string, error) {
+	var ids []string
+	if err := tx.Get(outboxBlockedPrefix+aggregateID, &ids); err != nil && !errors.Is(err, kvs.ErrNotFound) {
+		return nil, fmt.Errorf("error retrieving outbox dead letters from KVS: %w", err)
+	}
+	return ids, nil
+}
+
+// Replay moves a dead letter back to the pending entries in one
+// transaction. The entry keeps its ID, so it is delivered before the
+// entries recorded after it.
+func (r *KVSOutboxRepository) Replay(ctx context.Context, id string) (*domain.OutboxEntry, error) {
+	var entry domain.OutboxEntry
+	err := update(ctx, r.client, func(tx *kvs.Tx) error {
+		if err := tx.Get(outboxDeadPrefix+id, &entry); err != nil {
+			if errors.Is(err, kvs.ErrNotFound) {
+				return domain.ErrOutboxEntryNotFound
+			}
+			return fmt.Errorf("error retrieving outbox entry from KVS: %w", err)
+		}
+		ids, err := deadLetterIDs(tx, entry.AggregateID)
+		if err != nil {
+			return err
+		}
+		if ids = slices.DeleteFunc(ids, func(dead string) bool { return dead == id }); len(ids) > 0 {
+			if err := tx.Set(outboxBlockedPrefix+entry.AggregateID, ids); err != nil {
+				return fmt.Errorf("error saving outbox dead letters to KVS: %w", err)
+			}
+		} else {
+			tx.Delete(outboxBlockedPrefix + entry.AggregateID)
+		}
+
+		entry.Attempts = 0
+		entry.NextAttempt = time.Now()
+		entry.LastError = ""
+		tx.Delete(outboxDeadPrefix + id)
+		return tx.Set(outboxPendingPrefix+id, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// addEvents records stock adjustments of fruit in the outbox, one per
+// quantity
+func addEvents(t *testing.T, repo *KVSFruitRepository, fruit string, quantities ...int) {
+	t.Helper()
+	err := repo.Transaction(context.Background(), func(tx FruitTx) error {
+		for _, q := range quantities {
+			if err := tx.AddToOutbox(domain.StockAdjusted{FruitID: fruit, Quantity: q}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to add events: %v", err)
+	}
+}
+
+func TestKVSOutboxRepository_Pending(t *testing.T) {
+	// Setup
+	client := kvs.NewClient(kvs.WithShards(4))
+	fruits := NewKVSFruitRepository(client)
+	outbox := NewKVSOutboxRepository(client)
+	ctx := context.Background()
+	addEvents(t, fruits, "a", 1, 2, 3)
+	addEvents(t, fruits, "b", 4)
+
+	tests := []struct {
+		name       string
+		limit      int
+		quantities []int
+	}{
+		{name: "All", limit: 0, quantities: []int{1, 2, 3, 4}},
+		{name: "Limit", limit: 2, quantities: []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			entries, err := outbox.Pending(ctx, tt.limit)
+
+			// Assertions: oldest first
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(entries) != len(tt.quantities) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.quantities), len(entries))
+			}
+			for i, entry := range entries {
+				e, err := entry.DecodeEvent()
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if got := e.(domain.StockAdjusted).Quantity; got != tt.quantities[i] {
+					t.Errorf("Expected quantity %d, got %d", tt.quantities[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestKVSOutboxRepository_PendingAfter(t *testing.T) {
+	// Setup
+	client := kvs.NewClient(kvs.WithShards(4))
+	fruits := NewKVSFruitRepository(client)
+	outbox := NewKVSOutboxRepository(client)
+	ctx := context.Background()
+	addEvents(t, fruits, "a", 1, 2, 3, 4)
+	first, _ := outbox.Pending(ctx, 2)
+
+	// Action
+	next, err := outbox.PendingAfter(ctx, first[1].ID, 0)
+
+	// Assertions: the entries after the first page
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(next) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(next))
+	}
+	for i, entry := range next {
+		e, err := entry.DecodeEvent()
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if got := e.(domain.StockAdjusted).Quantity; got != i+3 {
+			t.Errorf("Expected quantity %d, got %d", i+3, got)
+		}
+	}
+}
+
+func TestKVSOutboxRepository_RolledBack(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	fruits := NewKVSFruitRepository(client)
+	outbox := NewKVSOutboxRepository(client)
+	ctx := context.Background()
+
+	// Action
+	err := fruits.Transaction(ctx, func(tx FruitTx) error {
+		if err := tx.AddToOutbox(domain.StockAdjusted{FruitID: "a", Quantity: 1}); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	})
+
+	// Assertions
+	if err == nil {
+		t.Fatalf("Expected an error, got nil")
+	}
+	if entries, _ := outbox.Pending(ctx, 0); len(entries) != 0 {
+		t.Errorf("Expected no entry, got %d", len(entries))
+	}
+}
+
+func TestKVSOutboxRepository_DeadLetterAndReplay(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	fruits := NewKVSFruitRepository(client)
+	outbox := NewKVSOutboxRepository(client)
+	ctx := context.Background()
+	addEvents(t, fruits, "a", 1)
+	entries, _ := outbox.Pending(ctx, 0)
+	entry := entries[0]
+
+	// Action
+	entry.Attempts = 3
+	entry.LastError = "boom"
+	if err := outbox.Reschedule(ctx, entry); err != nil {
+		t.Fatalf("Failed to reschedule: %v", err)
+	}
+	if err := outbox.DeadLetter(ctx, entry); err != nil {
+		t.Fatalf("Failed to dead-letter: %v", err)
+	}
+	pending, _ := outbox.Pending(ctx, 0)
+	dead, _ := outbox.DeadLetters(ctx, 0)
+
+	// Assertions
+	if len(pending) != 0 || len(dead) != 1 {
+		t.Fatalf("Expected 0 pending entries and 1 dead letter, got %d and %d", len(pending), len(dead))
+	}
+	if dead[0].Attempts != 3 || dead[0].LastError != "boom" {
+		t.Errorf("Expected 3 attempts and the last error, got %+v", dead[0])
+	}
+	if oldest, err := outbox.OldestDeadLetter(ctx, "a"); err != nil || oldest != entry.ID {
+		t.Errorf("Expected dead letter %s of a, got %q (%v)", entry.ID, oldest, err)
+	}
+
+	// Action
+	replayed, err := outbox.Replay(ctx, entry.ID)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if replayed.ID != entry.ID || replayed.Attempts != 0 || replayed.LastError != "" || replayed.NextAttempt.After(time.Now()) {
+		t.Errorf("Expected the entry due now with no attempts, got %+v", replayed)
+	}
+	if pending, _ := outbox.Pending(ctx, 0); len(pending) != 1 {
+		t.Errorf("Expected 1 pending entry, got %d", len(pending))
+	}
+	if oldest, err := outbox.OldestDeadLetter(ctx, "a"); err != nil || oldest != "" {
+		t.Errorf("Expected no dead letter of a, got %q (%v)", oldest, err)
+	}
+	if _, err := outbox.Replay(ctx, entry.ID); !errors.Is(err, domain.ErrOutboxEntryNotFound) {
+		t.Errorf("Expected error %v, got %v", domain.ErrOutboxEntryNotFound, err)
+	}
+}
+
+func TestKVSOutboxRepository_NotFound(t *testing.T) {
+	// Setup
+	outbox := NewKVSOutboxRepository(kvs.NewClient())
+	ctx := context.Background()
+	missing := domain.OutboxEntry{ID: "missing"}
+
+	tests := []struct {
+		name   string
+		action func() error
+	}{
+		{name: "Delivered", action: func() error { return outbox.Delivered(ctx, missing.ID) }},
+		{name: "Reschedule", action: func() error { return outbox.Reschedule(ctx, missing) }},
+		{name: "DeadLetter", action: func() error { return outbox.DeadLetter(ctx, missing) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			err := tt.action()
+
+			// Assertions
+			if !errors.Is(err, domain.ErrOutboxEntryNotFound) {
+				t.Errorf("Expected error %v, got %v", domain.ErrOutboxEntryNotFound, err)
+			}
+		})
+	}
+}
+
+func TestKVSFruitRepository_WatchSkipsOutbox(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := NewKVSFruitRepository(client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := repo.Watch(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	// Action
+	fruit := domain.NewFruit("test-id", "manzana", 12, 1000, "test")
+	err = repo.Transaction(ctx, func(tx FruitTx) error {
+		if err := tx.Save(fruit); err != nil {
+			return err
+		}
+		return tx.AddToOutbox(domain.FruitCreated{Fruit: *fruit})
+	})
+	if err != nil {
+		t.Fatalf("Failed to save fruit: %v", err)
+	}
+	err = repo.Transaction(ctx, func(tx FruitTx) error {
+		return tx.Delete(fruit.ID)
+	})
+	if err != nil {
+		t.Fatalf("Failed to delete fruit: %v", err)
+	}
+
+	// Assertions: the outbox entry is not reported as a fruit
+	for _, want := range []domain.ChangeType{domain.ChangeCreated, domain.ChangeDeleted} {
+		select {
+		case change := <-changes:
+			if change.Type != want || change.Fruit.ID != fruit.ID {
+				t.Errorf("Expected %s of %s, got %s of %s", want, fruit.ID, change.Type, change.Fruit.ID)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected %s change", want)
+		}
+	}
+}
+
+func TestKVSFruitRepository_WatchOnlyFruits(t *testing.T) {
+	// Setup: a watcher that does not receive while other keys are written
+	client := kvs.NewClient()
+	repo := NewKVSFruitRepository(client)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := repo.Watch(ctx, 0)
+	if err != nil {
+		t.Fatalf("Failed to watch: %v", err)
+	}
+
+	// Action: more writes than the watch buffer, then a fruit
+	for i := 0; i < kvs.DefaultWatchBuffer+10; i++ {
+		if err := client.Set(ctx, fmt.Sprintf("audit/entry/%020d", i), i); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+	fruit := domain.NewFruit("test-id", "manzana", 12, 1000, "test")
+	err = repo.Transaction(ctx, func(tx FruitTx) error {
+		return tx.Save(fruit)
+	})
+	if err != nil {
+		t.Fatalf("Failed to save fruit: %v", err)
+	}
+
+	// Assertions: the watcher did not fall behind
+	select {
+	case change := <-changes:
+		if change.Type != domain.ChangeCreated {
+			t.Errorf("Expected %s, got %s", domain.ChangeCreated, change.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected %s change", domain.ChangeCreated)
+	}
+}
@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// Key prefixes of the webhooks and of their queued and logged deliveries,
+// the log being grouped by webhook
+const (
+	webhookPrefix      = "webhook/sub/"
+	webhookQueuePrefix = "webhook/queue/"
+	webhookLogPrefix   = "webhook/log/"
+)
+
+// KVSWebhookRepository implements WebhookRepository using a KVS client
+type KVSWebhookRepository struct {
+	client *kvs.Client
+}
+
+// NewKVSWebhookRepository creates a new instance of KVSWebhookRepository
+func NewKVSWebhookRepository(client *kvs.Client) *KVSWebhookRepository {
+	return &KVSWebhookRepository{
+		client: client,
+	}
+}
+
+// Save stores a webhook in the KVS
+func (r *KVSWebhookRepository) Save(ctx context.Context, webhook *domain.Webhook) error {
+	if err := r.client.Set(ctx, webhookPrefix+webhook.ID, webhook); err != nil {
+		if errors.Is(err, kvs.ErrQuotaExceeded) {
+			return domain.ErrStorageFull
+		}
+		return fmt.Errorf("error saving webhook to KVS: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a webhook from the KVS by its ID
+func (r *KVSWebhookRepository) GetByID(ctx context.Context, id string) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	if err := r.client.Get(ctx, webhookPrefix+id, &webhook); err != nil {
+		if errors.Is(err, kvs.ErrNotFound) {
+			return nil, domain.ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("error retrieving webhook from KVS: %w", err)
+	}
+	return &webhook, nil
+}
+
+// List returns the webhooks of owner from the KVS
+func (r *KVSWebhookRepository) List(ctx context.Context, owner string) ([]domain.Webhook, error) {
+	var webhooks []domain.Webhook
+	err := r.client.Scan(ctx, webhookPrefix, func(e kvs.ScanEntry) error {
+		var webhook domain.Webhook
+		if err := e.Decode(&webhook); err != nil {
+			return fmt.Errorf("error decoding webhook %s: %w", e.Key, err)
+		}
+		if owner == "" || webhook.Owner == owner {
+			webhooks = append(webhooks, webhook)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhooks from KVS: %w", err)
+	}
+	slices.SortStableFunc(webhooks, func(a, b domain.Webhook) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return webhooks, nil
+}
+
+// Update atomically modifies a webhook in the KVS. Errors returned by fn
+// are passed through unchanged.
+func (r *KVSWebhookRepository) Update(ctx context.Context, id string, fn func(webhook *domain.Webhook) error) (*domain.Webhook, error) {
+	var webhook domain.Webhook
+	err := update(ctx, r.client, func(tx *kvs.Tx) error {
+		if err := tx.Get(webhookPrefix+id, &webhook); err != nil {
+			if errors.Is(err, kvs.ErrNotFound) {
+				return domain.ErrWebhookNotFound
+			}
+			return fmt.Errorf("error retrieving webhook from KVS: %w", err)
+		}
+		if err := fn(&webhook); err != nil {
+			return err
+		}
+		return tx.Set(webhookPrefix+id, &webhook)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// Delete removes a webhook from the KVS, then its deliveries
+func (r *KVSWebhookRepository) Delete(ctx context.Context, id string) error {
+	if err := r.client.Delete(ctx, webhookPrefix+id); err != nil {
+		if errors.Is(err, kvs.ErrNotFound) {
+			return domain.ErrWebhookNotFound
+		}
+		return fmt.Errorf("error deleting webhook from KVS: %w", err)
+	}
+
+	// Deliveries left behind by a failure are dropped by the dispatcher,
+	// which no longer finds their webhook
+	var keys []string
+	queued, err := r.queued(ctx, func(d domain.WebhookDelivery) bool { return d.WebhookID == id })
+	if err != nil {
+		return err
+	}
+	for _, d := range queued {
+		keys = append(keys, webhookQueuePrefix+d.ID)
+	}
+	err = r.client.Scan(ctx, webhookLogPrefix+id+"/", func(e kvs.ScanEntry) error {
+		keys = append(keys, e.Key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error listing webhook deliveries from KVS: %w", err)
+	}
+	return r.deleteKeys(ctx, keys)
+}
+
+// Enqueue stores deliveries in the queue in one transaction, with new IDs
+func (r *KVSWebhookRepository) Enqueue(ctx context.Context, deliveries ...domain.WebhookDelivery) error {
+	return update(ctx, r.client, func(tx *kvs.Tx) error {
+		for _, d := range deliveries {
+			d.ID = nextSortableID()
+			if err := tx.Set(webhookQueuePrefix+d.ID, d); err != nil {
+				return fmt.Errorf("error saving webhook delivery to KVS: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Due returns the queued deliveries that are due from the KVS
+func (r *KVSWebhookRepository) Due(ctx context.Context, now time.Time) ([]domain.WebhookDelivery, error) {
+	return r.queued(ctx, func(d domain.WebhookDelivery) bool { return !d.NextAttempt.After(now) })
+}
+
+// queued returns the queued deliveries matching keep, oldest first
+func (r *KVSWebhookRepository) queued(ctx context.Context, keep func(d domain.WebhookDelivery) bool) ([]domain.WebhookDelivery, error) {
+	var deliveries []domain.WebhookDelivery
+	err := r.client.Scan(ctx, webhookQueuePrefix, func(e kvs.ScanEntry) error {
+		var d domain.WebhookDelivery
+		if err := e.Decode(&d); err != nil {
+			return fmt.Errorf("error decoding webhook delivery %s: %w", e.Key, err)
+		}
+		if keep(d) {
+			deliveries = append(deliveries, d)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhook deliveries from KVS: %w", err)
+	}
+	return deliveries, nil
+}
+
+// Reschedule stores a queued delivery in the KVS, unless it is gone with
+// its webhook
+func (r *KVSWebhookRepository) Reschedule(ctx context.Context, delivery domain.WebhookDelivery) error {
+	return update(ctx, r.client, func(tx *kvs.Tx) error {
+		if !tx.Exists(webhookQueuePrefix + delivery.ID) {
+			return nil
+		}
+		return tx.Set(webhookQueuePrefix+delivery.ID, delivery)
+	})
+}
+
+// Complete moves a queued delivery to the log in one transaction, unless it
+// is gone with its webhook, then trims the log
+func (r *KVSWebhookRepository) Complete(ctx context.Context, delivery domain.WebhookDelivery) error {
+	logPrefix := webhookLogPrefix + delivery.WebhookID + "/"
+	err := update(ctx, r.client, func(tx *kvs.Tx) error {
+		if !tx.Exists(webhookQueuePrefix + delivery.ID) {
+			return nil
+		}
+		tx.Delete(webhookQueuePrefix + delivery.ID)
+		return tx.Set(logPrefix+delivery.ID, delivery)
+	})
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	err = r.client.Scan(ctx, logPrefix, func(e kvs.ScanEntry) error {
+		keys = append(keys, e.Key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error listing webhook deliveries from KVS: %w", err)
+	}
+	if len(keys) <= MaxWebhookLog {
+		return nil
+	}
+	return r.deleteKeys(ctx, keys[:len(keys)-MaxWebhookLog])
+}
+
+// Discard removes a queued delivery from the KVS
+func (r *KVSWebhookRepository) Discard(ctx context.Context, id string) error {
+	if err := r.client.Delete(ctx, webhookQueuePrefix+id); err != nil && !errors.Is(err, kvs.ErrNotFound) {
+		return fmt.Errorf("error deleting webhook delivery from KVS: %w", err)
+	}
+	return nil
+}
+
+// Deliveries returns the most recent deliveries of a webhook from the KVS
+func (r *KVSWebhookRepository) Deliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error) {
+	queued, err := r.queued(ctx, func(d domain.WebhookDelivery) bool { return d.WebhookID == webhookID })
+	if err != nil {
+		return nil, err
+	}
+	logged := queued[:0:0]
+	err = r.client.Scan(ctx, webhookLogPrefix+webhookID+"/", func(e kvs.ScanEntry) error {
+		var d domain.WebhookDelivery
+		if err := e.Decode(&d); err != nil {
+			return fmt.Errorf("error decoding webhook delivery %s: %w", e.Key, err)
+		}
+		logged = append(logged, d)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing webhook deliveries from KVS: %w", err)
+	}
+
+	slices.Reverse(queued)
+	slices.Reverse(logged)
+	deliveries := append(queued, logged...)
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// deleteKeys removes keys in one transaction
+func (r *KVSWebhookRepository) deleteKeys(ctx context.Context, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	err := update(ctx, r.client, func(tx *kvs.Tx) error {
+		for _, key := range keys {
+			tx.Delete(key)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error deleting webhook deliveries from KVS: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/pkg/kvs"
+)
+
+// saveWebhook stores a webhook of owner subscribed to the stock
+// adjustments
+func saveWebhook(t *testing.T, repo *KVSWebhookRepository, id, owner string) *domain.Webhook {
+	t.Helper()
+	webhook := domain.NewWebhook(id, owner, "https://example.com/"+id, []string{domain.EventStockAdjusted}, "0123456789abcdef")
+	if err := repo.Save(context.Background(), webhook); err != nil {
+		t.Fatalf("Failed to save webhook: %v", err)
+	}
+	return webhook
+}
+
+// enqueue queues a stock adjustment of fruit a to each quantity for a
+// webhook
+func enqueue(t *testing.T, repo *KVSWebhookRepository, webhookID string, quantities ...int) {
+	t.Helper()
+	var deliveries []domain.WebhookDelivery
+	for _, q := range quantities {
+		d, err := domain.NewWebhookDelivery(webhookID, domain.StockAdjusted{FruitID: "a", Quantity: q})
+		if err != nil {
+			t.Fatalf("Failed to create delivery: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := repo.Enqueue(context.Background(), deliveries...); err != nil {
+		t.Fatalf("Failed to enqueue deliveries: %v", err)
+	}
+}
+
+func TestKVSWebhookRepository_List(t *testing.T) {
+	// Setup
+	repo := NewKVSWebhookRepository(kvs.NewClient(kvs.WithShards(4)))
+	saveWebhook(t, repo, "1", "alice")
+	saveWebhook(t, repo, "2", "bob")
+	saveWebhook(t, repo, "3", "alice")
+
+	tests := []struct {
+		name  string
+		owner string
+		ids   []string
+	}{
+		{name: "Owner", owner: "alice", ids: []string{"1", "3"}},
+		{name: "Everyone", owner: "", ids: []string{"1", "2", "3"}},
+		{name: "NoWebhook", owner: "carol"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			webhooks, err := repo.List(context.Background(), tt.owner)
+
+			// Assertions: oldest first
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(webhooks) != len(tt.ids) {
+				t.Fatalf("Expected %d webhooks, got %d", len(tt.ids), len(webhooks))
+			}
+			for i, webhook := range webhooks {
+				if webhook.ID != tt.ids[i] {
+					t.Errorf("Expected webhook %s, got %s", tt.ids[i], webhook.ID)
+				}
+			}
+		})
+	}
+}
+
+func TestKVSWebhookRepository_Queue(t *testing.T) {
+	// Setup
+	repo := NewKVSWebhookRepository(kvs.NewClient())
+	ctx := context.Background()
+	saveWebhook(t, repo, "1", "alice")
+	enqueue(t, repo, "1", 1, 2, 3)
+	due, err := repo.Due(ctx, time.Now())
+	if err != nil || len(due) != 3 {
+		t.Fatalf("Expected 3 due deliveries, got %d (%v)", len(due), err)
+	}
+
+	// Action: the first succeeds, the second is retried later
+	due[0].Status = domain.DeliverySucceeded
+	if err := repo.Complete(ctx, due[0]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	due[1].Attempts = 1
+	due[1].NextAttempt = time.Now().Add(time.Hour)
+	if err := repo.Reschedule(ctx, due[1]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assertions
+	stillDue, err := repo.Due(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(stillDue) != 1 || stillDue[0].ID != due[2].ID {
+		t.Errorf("Expected only delivery %s due, got %+v", due[2].ID, stillDue)
+	}
+	deliveries, err := repo.Deliveries(ctx, "1", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	want := []string{due[2].ID, due[1].ID, due[0].ID}
+	if len(deliveries) != len(want) {
+		t.Fatalf("Expected %d deliveries, got %d", len(want), len(deliveries))
+	}
+	for i, d := range deliveries {
+		if d.ID != want[i] {
+			t.Errorf("Expected delivery %s at %d, got %s", want[i], i, d.ID)
+		}
+	}
+	if deliveries[2].Status != domain.DeliverySucceeded {
+		t.Errorf("Expected status %s, got %s", domain.DeliverySucceeded, deliveries[2].Status)
+	}
+}
+
+func TestKVSWebhookRepository_LogIsTrimmed(t *testing.T) {
+	// Setup
+	repo := NewKVSWebhookRepository(kvs.NewClient())
+	ctx := context.Background()
+	saveWebhook(t, repo, "1", "alice")
+	quantities := make([]int, MaxWebhookLog+5)
+	for i := range quantities {
+		quantities[i] = i
+	}
+	enqueue(t, repo, "1", quantities...)
+	due, err := repo.Due(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Action
+	for _, d := range due {
+		d.Status = domain.DeliverySucceeded
+		if err := repo.Complete(ctx, d); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// Assertions: the most recent deliveries are kept
+	deliveries, err := repo.Deliveries(ctx, "1", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(deliveries) != MaxWebhookLog {
+		t.Fatalf("Expected %d deliveries, got %d", MaxWebhookLog, len(deliveries))
+	}
+	if deliveries[0].ID != due[len(due)-1].ID {
+		t.Errorf("Expected the last delivery first, got %s", deliveries[0].ID)
+	}
+}
+
+func TestKVSWebhookRepository_Delete(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := NewKVSWebhookRepository(client)
+	ctx := context.Background()
+	saveWebhook(t, repo, "1", "alice")
+	saveWebhook(t, repo, "2", "alice")
+	enqueue(t, repo, "1", 1, 2)
+	enqueue(t, repo, "2", 3)
+	due, _ := repo.Due(ctx, time.Now())
+	due[0].Status = domain.DeliveryFailed
+	if err := repo.Complete(ctx, due[0]); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Action
+	err := repo.Delete(ctx, "1")
+
+	// Assertions: only the deliveries of the other webhook are left
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if _, err := repo.GetByID(ctx, "1"); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Errorf("Expected %v, got %v", domain.ErrWebhookNotFound, err)
+	}
+	left, _ := repo.Due(ctx, time.Now())
+	if len(left) != 1 || left[0].WebhookID != "2" {
+		t.Errorf("Expected the delivery of webhook 2 left, got %+v", left)
+	}
+	if deliveries, _ := repo.Deliveries(ctx, "1", 0); len(deliveries) != 0 {
+		t.Errorf("Expected no delivery logged, got %d", len(deliveries))
+	}
+	if err := repo.Delete(ctx, "1"); !errors.Is(err, domain.ErrWebhookNotFound) {
+		t.Errorf("Expected %v, got %v", domain.ErrWebhookNotFound, err)
+	}
+}
+
+func TestKVSWebhookRepository_Update(t *testing.T) {
+	// Setup
+	repo := NewKVSWebhookRepository(kvs.NewClient())
+	ctx := context.Background()
+	saveWebhook(t, repo, "1", "alice")
+	boom := errors.New("boom")
+
+	// Action
+	_, failed := repo.Update(ctx, "1", func(w *domain.Webhook) error {
+		w.Active = false
+		return boom
+	})
+	updated, err := repo.Update(ctx, "1", func(w *domain.Webhook) error {
+		w.ConsecutiveFailures = 3
+		return nil
+	})
+	_, missing := repo.Update(ctx, "2", func(w *domain.Webhook) error { return nil })
+
+	// Assertions
+	if !errors.Is(failed, boom) {
+		t.Errorf("Expected %v, got %v", boom, failed)
+	}
+	if err != nil || !updated.Active || updated.ConsecutiveFailures != 3 {
+		t.Errorf("Expected an active webhook with 3 failures, got %+v (%v)", updated, err)
+	}
+	if !errors.Is(missing, domain.ErrWebhookNotFound) {
+		t.Errorf("Expected %v, got %v", domain.ErrWebhookNotFound, missing)
+	}
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+
+	"fruitsapi/internal/domain"
+)
+
+// OutboxRepository gives access to the outbox entries recorded with
+// FruitTx.AddToOutbox, pending delivery or given up as dead letters
+type OutboxRepository interface {
+	// Pending lists up to limit entries waiting for delivery, or every one
+	// when limit is 0, oldest first
+	Pending(ctx context.Context, limit int) ([]domain.OutboxEntry, error)
+
+	// PendingAfter lists like Pending the entries waiting for delivery
+	// whose ID comes after the given one, to read them by pages
+	PendingAfter(ctx context.Context, after string, limit int) ([]domain.OutboxEntry, error)
+
+	// DeadLetters lists up to limit entries given up after too many failed
+	// deliveries, or every one when limit is 0, oldest first
+	DeadLetters(ctx context.Context, limit int) ([]domain.OutboxEntry, error)
+
+	// Delivered removes a pending entry once delivered
+	Delivered(ctx context.Context, id string) error
+
+	// Reschedule stores the attempts, error and next attempt of a pending
+	// entry whose delivery failed
+	Reschedule(ctx context.Context, entry domain.OutboxEntry) error
+
+	// DeadLetter moves a pending entry to the dead letters
+	DeadLetter(ctx context.Context, entry domain.OutboxEntry) error
+
+	// OldestDeadLetter returns the ID of the oldest dead letter of an
+	// aggregate, or "" when it has none
+	OldestDeadLetter(ctx context.Context, aggregateID string) (string, error)
+
+	// Replay moves a dead letter back to the pending entries, due now and
+	// with its attempts reset, failing with domain.ErrOutboxEntryNotFound
+	// when no dead letter has the ID
+	Replay(ctx context.Context, id string) (*domain.OutboxEntry, error)
+}
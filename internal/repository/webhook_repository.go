@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"fruitsapi/internal/domain"
+)
+
+// MaxWebhookLog is the number of completed deliveries kept per webhook
+const MaxWebhookLog = 100
+
+// WebhookRepository stores the webhooks, the queue of their pending
+// deliveries and the log of their completed ones
+type WebhookRepository interface {
+	// Save stores a new webhook
+	Save(ctx context.Context, webhook *domain.Webhook) error
+
+	// GetByID retrieves a webhook by its ID
+	GetByID(ctx context.Context, id string) (*domain.Webhook, error)
+
+	// List returns the webhooks of owner, or every webhook when owner is
+	// empty, oldest first
+	List(ctx context.Context, owner string) ([]domain.Webhook, error)
+
+	// Update atomically loads a webhook, lets fn change it and stores the
+	// result, leaving the stored webhook untouched if fn returns an error
+	Update(ctx context.Context, id string, fn func(webhook *domain.Webhook) error) (*domain.Webhook, error)
+
+	// Delete removes a webhook with its queued and logged deliveries
+	Delete(ctx context.Context, id string) error
+
+	// Enqueue queues deliveries, all of them or none, giving them new IDs
+	Enqueue(ctx context.Context, deliveries ...domain.WebhookDelivery) error
+
+	// Due returns the queued deliveries whose next attempt is not after
+	// now, oldest first
+	Due(ctx context.Context, now time.Time) ([]domain.WebhookDelivery, error)
+
+	// Reschedule stores a queued delivery after a failed attempt
+	Reschedule(ctx context.Context, delivery domain.WebhookDelivery) error
+
+	// Complete moves a queued delivery to the log of its webhook, which
+	// keeps the MaxWebhookLog most recent deliveries
+	Complete(ctx context.Context, delivery domain.WebhookDelivery) error
+
+	// Discard removes a queued delivery whose webhook is gone
+	Discard(ctx context.Context, id string) error
+
+	// Deliveries returns up to limit deliveries of a webhook, the queued
+	// ones then the logged ones, most recent first
+	Deliveries(ctx context.Context, webhookID string, limit int) ([]domain.WebhookDelivery, error)
+}
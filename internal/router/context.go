@@ -0,0 +1,32 @@
+package router
+
+import (
+	"context"
+	"net/http"
+)
+
+type matchKey struct{}
+
+// Param returns the value of a path template parameter for the request
+func Param(r *http.Request, name string) string {
+	if m, ok := r.Context().Value(matchKey{}).(*Match); ok {
+		return m.Params[name]
+	}
+	return ""
+}
+
+// Template returns the route template matched for the request, e.g.
+// "/fruits/{id}", or an empty string when no route matched
+func Template(r *http.Request) string {
+	if m, ok := r.Context().Value(matchKey{}).(*Match); ok {
+		return m.Pattern
+	}
+	return ""
+}
+
+// Capture lets middleware running outside the Router learn which route
+// served the request: the returned Match is filled in once routing happens
+func Capture(r *http.Request) (*http.Request, *Match) {
+	m := &Match{}
+	return r.WithContext(context.WithValue(r.Context(), matchKey{}, m)), m
+}
@@ -0,0 +1,266 @@
+package router
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// TrailingSlashPolicy decides how paths with a trailing slash are handled
+type TrailingSlashPolicy int
+
+const (
+	// TrailingSlashRedirect answers with a 308 redirect to the path without
+	// the trailing slash when that path is routable
+	TrailingSlashRedirect TrailingSlashPolicy = iota
+	// TrailingSlashStrict treats "/fruits/" and "/fruits" as different paths
+	TrailingSlashStrict
+)
+
+// Route describes a registered method and path template
+type Route struct {
+	Method  string
+	Pattern string
+}
+
+// Match holds the route matched for a request
+type Match struct {
+	// Pattern is the route template, e.g. "/fruits/{id}"
+	Pattern string
+	// Params holds the values of the template parameters
+	Params map[string]string
+}
+
+// segment is a piece of a path template between slashes
+type segment struct {
+	value   string
+	isParam bool
+}
+
+// entry groups the handlers registered for a path template
+type entry struct {
+	pattern  string
+	segments []segment
+	handlers map[string]http.Handler
+}
+
+// Router dispatches requests to handlers using a method-aware route table
+type Router struct {
+	entries       []*entry
+	trailingSlash TrailingSlashPolicy
+	fallback      http.Handler
+	middlewares   []func(http.Handler) http.Handler
+}
+
+// New creates a new instance of Router
+func New(trailingSlash TrailingSlashPolicy) *Router {
+	return &Router{
+		trailingSlash: trailingSlash,
+	}
+}
+
+// Handle registers a handler for a method and a path template such as
+// "/fruits/{id}". Literal segments take precedence over parameters.
+func (rt *Router) Handle(method, pattern string, h http.Handler) {
+	for _, e := range rt.entries {
+		if e.pattern == pattern {
+			if _, exists := e.handlers[method]; exists {
+				panic("router: duplicate route " + method + " " + pattern)
+			}
+			e.handlers[method] = h
+			return
+		}
+	}
+
+	rt.entries = append(rt.entries, &entry{
+		pattern:  pattern,
+		segments: parsePattern(pattern),
+		handlers: map[string]http.Handler{method: h},
+	})
+}
+
+// Use adds middleware that runs after a route has matched, so it can read
+// the route template and parameters. The first middleware added is the outermost.
+func (rt *Router) Use(mw func(http.Handler) http.Handler) {
+	rt.middlewares = append(rt.middlewares, mw)
+}
+
+// Fallback sets the handler serving the requests that match no route,
+// instead of answering 404
+func (rt *Router) Fallback(h http.Handler) {
+	rt.fallback = h
+}
+
+// HandleFunc registers a handler function for a method and a path template
+func (rt *Router) HandleFunc(method, pattern string, h http.HandlerFunc) {
+	rt.Handle(method, pattern, h)
+}
+
+// Routes returns every registered route sorted by pattern and method
+func (rt *Router) Routes() []Route {
+	var routes []Route
+	for _, e := range rt.entries {
+		for method := range e.handlers {
+			routes = append(routes, Route{Method: method, Pattern: e.pattern})
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Pattern != routes[j].Pattern {
+			return routes[i].Pattern < routes[j].Pattern
+		}
+		return routes[i].Method < routes[j].Method
+	})
+	return routes
+}
+
+// ServeHTTP implements the http.Handler interface
+func (rt *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	path := req.URL.Path
+
+	e, params := rt.lookup(path)
+	if e == nil {
+		// A route of this router with a trailing slash is redirected
+		// rather than handed to the fallback
+		if rt.trailingSlash == TrailingSlashRedirect && len(path) > 1 && strings.HasSuffix(path, "/") {
+			if e, _ := rt.lookup(strings.TrimRight(path, "/")); e != nil {
+				target := *req.URL
+				target.Path = strings.TrimRight(path, "/")
+				target.RawPath = ""
+				http.Redirect(w, req, target.String(), http.StatusPermanentRedirect)
+				return
+			}
+		}
+		if rt.fallback != nil {
+			rt.fallback.ServeHTTP(w, req)
+			return
+		}
+		writeError(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	h, ok := e.handlers[req.Method]
+	if !ok && req.Method == http.MethodHead {
+		// net/http discards the body written for HEAD requests
+		h, ok = e.handlers[http.MethodGet]
+	}
+	if !ok {
+		w.Header().Set("Allow", strings.Join(e.allowed(), ", "))
+		if req.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeError(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	match := &Match{Pattern: e.pattern, Params: params}
+	if captured, ok := req.Context().Value(matchKey{}).(*Match); ok {
+		*captured = *match
+	}
+	for i := len(rt.middlewares) - 1; i >= 0; i-- {
+		h = rt.middlewares[i](h)
+	}
+	ctx := context.WithValue(req.Context(), matchKey{}, match)
+	h.ServeHTTP(w, req.WithContext(ctx))
+}
+
+// lookup finds the most specific template matching path
+func (rt *Router) lookup(path string) (*entry, map[string]string) {
+	parts := strings.Split(strings.TrimPrefix(path, "/"), "/")
+
+	var best *entry
+	var bestParams map[string]string
+	for _, e := range rt.entries {
+		params, ok := e.match(parts)
+		if !ok {
+			continue
+		}
+		if best == nil || e.moreSpecificThan(best) {
+			best, bestParams = e, params
+		}
+	}
+	return best, bestParams
+}
+
+// match checks the path parts against the template and extracts the parameters
+func (e *entry) match(parts []string) (map[string]string, bool) {
+	if len(parts) != len(e.segments) {
+		return nil, false
+	}
+
+	var params map[string]string
+	for i, seg := range e.segments {
+		if !seg.isParam {
+			if parts[i] != seg.value {
+				return nil, false
+			}
+			continue
+		}
+		if parts[i] == "" {
+			return nil, false
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[seg.value] = parts[i]
+	}
+	return params, true
+}
+
+// moreSpecificThan reports whether e has a literal segment where other
+// has a parameter, comparing from left to right
+func (e *entry) moreSpecificThan(other *entry) bool {
+	for i := range e.segments {
+		if e.segments[i].isParam != other.segments[i].isParam {
+			return !e.segments[i].isParam
+		}
+	}
+	return false
+}
+
+// allowed returns the methods accepted by the template, including the
+// automatic HEAD and OPTIONS
+func (e *entry) allowed() []string {
+	methods := map[string]bool{http.MethodOptions: true}
+	for method := range e.handlers {
+		methods[method] = true
+	}
+	if methods[http.MethodGet] {
+		methods[http.MethodHead] = true
+	}
+
+	allowed := make([]string, 0, len(methods))
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+	sort.Strings(allowed)
+	return allowed
+}
+
+// parsePattern splits a template such as "/fruits/{id}" into segments
+func parsePattern(pattern string) []segment {
+	parts := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	segments := make([]segment, len(parts))
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			segments[i] = segment{value: part[1 : len(part)-1], isParam: true}
+		} else {
+			segments[i] = segment{value: part}
+		}
+	}
+	return segments
+}
+
+// errorResponse mirrors the error body used by the handlers
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes a JSON error response
+func writeError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: message})
+}
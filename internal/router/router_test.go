@@ -0,0 +1,179 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// echo writes the matched template and the id parameter
+func echo(w http.ResponseWriter, r *http.Request) {
+	io.WriteString(w, Template(r)+" "+Param(r, "id"))
+}
+
+func newTestRouter(policy TrailingSlashPolicy) *Router {
+	rt := New(policy)
+	rt.HandleFunc(http.MethodPost, "/fruits", echo)
+	rt.HandleFunc(http.MethodGet, "/fruits/{id}", echo)
+	rt.HandleFunc(http.MethodDelete, "/fruits/{id}", echo)
+	rt.HandleFunc(http.MethodGet, "/fruits/events", echo)
+	return rt
+}
+
+func TestRouter_ServeHTTP(t *testing.T) {
+	tests := []struct {
+		name           string
+		policy         TrailingSlashPolicy
+		method         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+		expectedAllow  string
+		expectedTarget string
+	}{
+		{
+			name:           "ParamExtracted",
+			method:         http.MethodGet,
+			path:           "/fruits/abc",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/fruits/{id} abc",
+		},
+		{
+			name:           "LiteralBeatsParam",
+			method:         http.MethodGet,
+			path:           "/fruits/events",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "/fruits/events ",
+		},
+		{
+			name:           "NestedPathNotFound",
+			method:         http.MethodGet,
+			path:           "/fruits/a/b",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "EmptyParamRedirectsToCollection",
+			method:         http.MethodGet,
+			path:           "/fruits/",
+			expectedStatus: http.StatusPermanentRedirect,
+			expectedTarget: "/fruits",
+		},
+		{
+			name:           "EmptyParamStrict",
+			policy:         TrailingSlashStrict,
+			method:         http.MethodGet,
+			path:           "/fruits/",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "MethodNotAllowed",
+			method:         http.MethodGet,
+			path:           "/fruits",
+			expectedStatus: http.StatusMethodNotAllowed,
+			expectedAllow:  "OPTIONS, POST",
+		},
+		{
+			name:           "AutomaticHead",
+			method:         http.MethodHead,
+			path:           "/fruits/abc",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "AutomaticOptions",
+			method:         http.MethodOptions,
+			path:           "/fruits/abc",
+			expectedStatus: http.StatusNoContent,
+			expectedAllow:  "DELETE, GET, HEAD, OPTIONS",
+		},
+		{
+			name:           "UnknownPath",
+			method:         http.MethodGet,
+			path:           "/vegetables",
+			expectedStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := newTestRouter(tt.policy)
+			recorder := httptest.NewRecorder()
+
+			rt.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, recorder.Code)
+			}
+			if tt.expectedBody != "" && recorder.Body.String() != tt.expectedBody {
+				t.Errorf("Expected body %q, got %q", tt.expectedBody, recorder.Body.String())
+			}
+			if allow := recorder.Header().Get("Allow"); allow != tt.expectedAllow {
+				t.Errorf("Expected Allow %q, got %q", tt.expectedAllow, allow)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.expectedTarget {
+				t.Errorf("Expected Location %q, got %q", tt.expectedTarget, location)
+			}
+		})
+	}
+}
+
+func TestRouter_Fallback(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedTarget string
+	}{
+		{name: "Route", path: "/healthz", expectedStatus: http.StatusOK},
+		{name: "TrailingSlashRedirected", path: "/healthz/", expectedStatus: http.StatusPermanentRedirect, expectedTarget: "/healthz"},
+		{name: "NoRoute", path: "/fruits", expectedStatus: http.StatusTeapot},
+		{name: "NoRouteTrailingSlash", path: "/fruits/", expectedStatus: http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rt := New(TrailingSlashRedirect)
+			rt.HandleFunc(http.MethodGet, "/healthz", echo)
+			rt.Fallback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTeapot)
+			}))
+			recorder := httptest.NewRecorder()
+
+			rt.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if recorder.Code != tt.expectedStatus {
+				t.Errorf("Expected status code %d, got %d", tt.expectedStatus, recorder.Code)
+			}
+			if location := recorder.Header().Get("Location"); location != tt.expectedTarget {
+				t.Errorf("Expected Location %q, got %q", tt.expectedTarget, location)
+			}
+		})
+	}
+}
+
+func TestRouter_Capture(t *testing.T) {
+	rt := newTestRouter(TrailingSlashRedirect)
+	req, match := Capture(httptest.NewRequest(http.MethodGet, "/fruits/abc", nil))
+
+	rt.ServeHTTP(httptest.NewRecorder(), req)
+
+	if match.Pattern != "/fruits/{id}" {
+		t.Errorf("Expected pattern %s, got %s", "/fruits/{id}", match.Pattern)
+	}
+	if match.Params["id"] != "abc" {
+		t.Errorf("Expected id %s, got %s", "abc", match.Params["id"])
+	}
+}
+
+func TestRouter_Routes(t *testing.T) {
+	rt := newTestRouter(TrailingSlashRedirect)
+
+	routes := rt.Routes()
+
+	if len(routes) != 4 {
+		t.Fatalf("Expected %d routes, got %d", 4, len(routes))
+	}
+	if routes[0] != (Route{Method: http.MethodPost, Pattern: "/fruits"}) {
+		t.Errorf("Expected first route POST /fruits, got %s %s", routes[0].Method, routes[0].Pattern)
+	}
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// unixPrefix marks an address as a Unix domain socket path
+const unixPrefix = "unix:"
+
+// Config holds the HTTP server settings
+type Config struct {
+	// Addr is a TCP address (":8080") or a Unix socket ("unix:/run/fruits.sock")
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	// DrainDelay keeps serving after shutdown starts so load balancers can
+	// observe the not-ready state before the listener closes
+	DrainDelay time.Duration
+	// ShutdownTimeout bounds the time given to in-flight requests to drain
+	ShutdownTimeout time.Duration
+}
+
+// hook is a named step of the shutdown sequence
+type hook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+// Server wraps an http.Server with graceful shutdown and ordered shutdown hooks
+type Server struct {
+	config     Config
+	httpServer *http.Server
+
+	mu         sync.Mutex
+	drainHooks []func()
+	hooks      []hook
+}
+
+// New creates a new instance of Server
+func New(config Config, handler http.Handler) *Server {
+	return &Server{
+		config: config,
+		httpServer: &http.Server{
+			Handler:           handler,
+			ReadTimeout:       config.ReadTimeout,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+			WriteTimeout:      config.WriteTimeout,
+			IdleTimeout:       config.IdleTimeout,
+		},
+	}
+}
+
+// OnDrain registers a function called as soon as shutdown starts, before
+// in-flight requests are drained, e.g. to report the process as not ready
+func (s *Server) OnDrain(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drainHooks = append(s.drainHooks, fn)
+}
+
+// OnShutdown registers a step to run once the HTTP server has drained.
+// Steps run in registration order, so background workers should be
+// registered before the storage they write to.
+func (s *Server) OnShutdown(name string, fn func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, hook{name: name, fn: fn})
+}
+
+// Run serves HTTP until ctx is cancelled, then drains in-flight requests
+// and runs the shutdown hooks within the configured deadline
+func (s *Server) Run(ctx context.Context) error {
+	listener, err := Listen(s.config.Addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Starting server on %s...", s.config.Addr)
+		serveErr <- s.httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			return fmt.Errorf("error serving HTTP: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Printf("Shutting down server, draining requests for up to %s...", s.config.ShutdownTimeout)
+	s.mu.Lock()
+	drainHooks := s.drainHooks
+	s.mu.Unlock()
+	for _, fn := range drainHooks {
+		fn()
+	}
+	time.Sleep(s.config.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.httpServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("error draining HTTP server: %w", err))
+	}
+
+	s.mu.Lock()
+	hooks := s.hooks
+	s.mu.Unlock()
+	for _, h := range hooks {
+		log.Printf("Shutting down %s...", h.name)
+		if err := h.fn(shutdownCtx); err != nil {
+			errs = append(errs, fmt.Errorf("error shutting down %s: %w", h.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Listen opens a listener for addr, which is either a TCP address or a
+// Unix socket path prefixed with "unix:"
+func Listen(addr string) (net.Listener, error) {
+	if path, ok := strings.CutPrefix(addr, unixPrefix); ok {
+		// Remove a stale socket left behind by a previous process, but
+		// never another kind of file
+		info, err := os.Lstat(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("error checking unix socket %s: %w", path, err)
+		case info.Mode()&os.ModeSocket == 0:
+			return nil, fmt.Errorf("error listening on unix socket %s: not a socket", path)
+		default:
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("error removing stale socket %s: %w", path, err)
+			}
+		}
+		listener, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, fmt.Errorf("error listening on unix socket %s: %w", path, err)
+		}
+		return listener, nil
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("error listening on %s: %w", addr, err)
+	}
+	return listener, nil
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestServer_RunUnixSocketAndShutdown(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "fruits.sock")
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	srv := New(Config{Addr: unixPrefix + socket, ShutdownTimeout: time.Second}, handler)
+
+	var order []string
+	srv.OnShutdown("workers", func(ctx context.Context) error {
+		order = append(order, "workers")
+		return nil
+	})
+	srv.OnShutdown("storage", func(ctx context.Context) error {
+		order = append(order, "storage")
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- srv.Run(ctx) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}}
+
+	var resp *http.Response
+	var err error
+	for i := 0; i < 50; i++ {
+		resp, err = client.Get("http://unix/")
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("Failed to send request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "ok" {
+		t.Errorf("Expected body %s, got %s", "ok", body)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(order) != 2 || order[0] != "workers" || order[1] != "storage" {
+		t.Errorf("Expected shutdown order [workers storage], got %v", order)
+	}
+}
+
+func TestListen_UnixSocket(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(t *testing.T, path string)
+		wantErr bool
+	}{
+		{name: "No file", setup: func(t *testing.T, path string) {}},
+		{name: "Stale socket", setup: func(t *testing.T, path string) {
+			l, err := net.Listen("unix", path)
+			if err != nil {
+				t.Fatalf("Failed to listen: %v", err)
+			}
+			l.(*net.UnixListener).SetUnlinkOnClose(false)
+			l.Close()
+		}},
+		{name: "Regular file", setup: func(t *testing.T, path string) {
+			if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			path := filepath.Join(t.TempDir(), "fruits.sock")
+			tt.setup(t, path)
+
+			// Action
+			l, err := Listen(unixPrefix + path)
+
+			// Assertions
+			if tt.wantErr {
+				if err == nil {
+					l.Close()
+					t.Fatalf("Expected an error, got nil")
+				}
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("Expected the file to be kept, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			l.Close()
+		})
+	}
+}
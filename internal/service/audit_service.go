@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+// AnonymousActor is the actor of the changes made without one in their
+// context
+const AnonymousActor = "anonymous"
+
+// Actor identifies who makes the changes of a request, for the audit log
+type Actor struct {
+	// Name is the owner or API key fingerprint making the request
+	Name      string
+	RequestID string
+}
+
+type actorKey struct{}
+
+// WithActor returns a copy of ctx carrying actor
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor stored in the context, anonymous when
+// there is none
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorKey{}).(Actor)
+	if actor.Name == "" {
+		actor.Name = AnonymousActor
+	}
+	return actor
+}
+
+// audit records a change of a fruit from before to after, made by the
+// actor of ctx, in the audit log of the transaction
+func audit(ctx context.Context, tx repository.FruitTx, operation string, before, after *domain.Fruit) error {
+	actor := ActorFromContext(ctx)
+	entry, err := domain.NewAuditEntry(actor.Name, actor.RequestID, operation, before, after)
+	if err != nil {
+		return err
+	}
+	return tx.AddToAudit(entry)
+}
+
+// AuditService reads the audit log of the changes of the fruits
+type AuditService struct {
+	repo repository.AuditRepository
+}
+
+// NewAuditService creates a new instance of AuditService
+func NewAuditService(repo repository.AuditRepository) *AuditService {
+	return &AuditService{
+		repo: repo,
+	}
+}
+
+// List returns up to limit entries matching filter, oldest first
+func (s *AuditService) List(ctx context.Context, filter repository.AuditFilter, limit int) ([]domain.AuditEntry, error) {
+	return s.repo.List(ctx, filter, limit)
+}
+
+// Verify checks that no entry of the audit log was altered, removed or
+// inserted, and returns the number of entries and the hash of the last one
+func (s *AuditService) Verify(ctx context.Context) (int, string, error) {
+	return s.repo.Verify(ctx)
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/pkg/kvs"
+)
+
+func TestFruitService_AuditLog(t *testing.T) {
+	ctx := WithActor(context.Background(), Actor{Name: "owner:test", RequestID: "req-1"})
+
+	tests := []struct {
+		name   string
+		action func(service *FruitService, existing string)
+		// expected lists operation:fields of the entries after the creation
+		expected []string
+	}{
+		{
+			name: "Create",
+			action: func(service *FruitService, existing string) {
+				service.CreateFruit(ctx, "pera", 1, 10, "test")
+			},
+			expected: []string{"create:date_created,date_last_updated,id,name,owner,price,quantity,status"},
+		},
+		{
+			name: "UpdatePrice",
+			action: func(service *FruitService, existing string) {
+				service.UpdateFruit(ctx, "test", existing, func(f *domain.Fruit) error { f.Price = 900; return nil })
+			},
+			expected: []string{"update:date_last_updated,price"},
+		},
+		{
+			name: "FailedUpdate",
+			action: func(service *FruitService, existing string) {
+				service.UpdateFruit(ctx, "test", existing, func(f *domain.Fruit) error { return errors.New("boom") })
+			},
+		},
+		{
+			name: "Delete",
+			action: func(service *FruitService, existing string) {
+				service.DeleteFruit(ctx, "test", existing)
+			},
+			expected: []string{"delete:date_created,date_last_updated,id,name,owner,price,quantity,status"},
+		},
+		{
+			name: "AtomicBatch",
+			action: func(service *FruitService, existing string) {
+				service.Batch(ctx, "test", []BatchOp{
+					{Kind: BatchUpdate, ID: existing, Apply: func(f *domain.Fruit) error { f.Quantity = 3; return nil }},
+					{Kind: BatchDelete, ID: existing},
+				}, true)
+			},
+			expected: []string{"update:date_last_updated,quantity", "delete:date_created,date_last_updated,id,name,owner,price,quantity,status"},
+		},
+		{
+			name: "AbortedBatch",
+			action: func(service *FruitService, existing string) {
+				service.Batch(ctx, "test", []BatchOp{
+					{Kind: BatchCreate, Name: "pera", Quantity: 1, Price: 10},
+					{Kind: BatchDelete, ID: missingID},
+				}, true)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := kvs.NewClient()
+			service := NewFruitService(repository.NewKVSFruitRepository(client))
+			audit := NewAuditService(repository.NewKVSAuditRepository(client))
+			fruit, err := service.CreateFruit(ctx, "manzana", 12, 1000, "test")
+			if err != nil {
+				t.Fatalf("Failed to create fruit: %v", err)
+			}
+
+			// Action
+			tt.action(service, fruit.ID)
+
+			// Assertions
+			entries, err := audit.List(context.Background(), repository.AuditFilter{After: 1}, 0)
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("Expected %d entries, got %d", len(tt.expected), len(entries))
+			}
+			for i, entry := range entries {
+				fields := make([]string, len(entry.Changes))
+				for j, c := range entry.Changes {
+					fields[j] = c.Field
+				}
+				if got := entry.Operation + ":" + strings.Join(fields, ","); got != tt.expected[i] {
+					t.Errorf("Expected %s, got %s", tt.expected[i], got)
+				}
+				if entry.Actor != "owner:test" || entry.RequestID != "req-1" {
+					t.Errorf("Expected actor owner:test of req-1, got %s of %s", entry.Actor, entry.RequestID)
+				}
+			}
+			if n, _, err := audit.Verify(context.Background()); err != nil || n != len(tt.expected)+1 {
+				t.Errorf("Expected a valid chain of %d entries, got %d (%v)", len(tt.expected)+1, n, err)
+			}
+		})
+	}
+}
+
+func TestFruitService_AuditImmutable(t *testing.T) {
+	entryID := "audit/entry/00000000000000000001"
+
+	tests := []struct {
+		name   string
+		action func(service *FruitService) error
+	}{
+		{
+			name:   "DeleteEntry",
+			action: func(service *FruitService) error { return service.DeleteFruit(context.Background(), "test", entryID) },
+		},
+		{
+			name: "DeleteHead",
+			action: func(service *FruitService) error {
+				return service.DeleteFruit(context.Background(), "test", "audit/head")
+			},
+		},
+		{
+			name: "OverwriteEntry",
+			action: func(service *FruitService) error {
+				_, err := service.UpdateFruit(context.Background(), "test", entryID, func(f *domain.Fruit) error { f.Price = 1; return nil })
+				return err
+			},
+		},
+		{
+			name: "BatchDeleteEntry",
+			action: func(service *FruitService) error {
+				return service.Batch(context.Background(), "test", []BatchOp{{Kind: BatchDelete, ID: entryID}}, false)[0].Err
+			},
+		},
+		{
+			name: "AtomicBatchOverwriteHead",
+			action: func(service *FruitService) error {
+				return service.Batch(context.Background(), "test", []BatchOp{
+					{Kind: BatchUpdate, ID: "audit/head", Apply: func(f *domain.Fruit) error { f.Price = 1; return nil }},
+				}, true)[0].Err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := kvs.NewClient()
+			service := NewFruitService(repository.NewKVSFruitRepository(client))
+			audit := NewAuditService(repository.NewKVSAuditRepository(client))
+			for _, name := range []string{"manzana", "pera"} {
+				if _, err := service.CreateFruit(context.Background(), name, 12, 1000, "test"); err != nil {
+					t.Fatalf("Failed to create fruit: %v", err)
+				}
+			}
+
+			// Action
+			err := tt.action(service)
+
+			// Assertions
+			if !errors.Is(err, domain.ErrInvalidFruitID) {
+				t.Errorf("Expected error %v, got %v", domain.ErrInvalidFruitID, err)
+			}
+			if n, _, err := audit.Verify(context.Background()); err != nil || n != 2 {
+				t.Errorf("Expected a valid chain of 2 entries, got %d (%v)", n, err)
+			}
+		})
+	}
+}
+
+func TestActorFromContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		expected Actor
+	}{
+		{name: "Actor", ctx: WithActor(context.Background(), Actor{Name: "owner:test", RequestID: "req-1"}), expected: Actor{Name: "owner:test", RequestID: "req-1"}},
+		{name: "NoActor", ctx: context.Background(), expected: Actor{Name: AnonymousActor}},
+		{name: "NoName", ctx: WithActor(context.Background(), Actor{RequestID: "req-1"}), expected: Actor{Name: AnonymousActor, RequestID: "req-1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ActorFromContext(tt.ctx); got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+// BatchOpKind is the kind of change made by a batch operation
+type BatchOpKind string
+
+const (
+	BatchCreate BatchOpKind = "create"
+	BatchUpdate BatchOpKind = "update"
+	BatchDelete BatchOpKind = "delete"
+)
+
+// ErrBatchAborted is the result of the operations of an atomic batch that
+// were not applied because another operation failed
+var ErrBatchAborted = errors.New("not applied because another operation of the atomic batch failed")
+
+// BatchOp is one operation of a batch
+type BatchOp struct {
+	Kind BatchOpKind
+	// ID is the fruit to update or delete
+	ID string
+	// Name, Quantity and Price describe the fruit to create
+	Name     string
+	Quantity int
+	Price    float64
+	// Apply changes the fruit to update, as in UpdateFruit
+	Apply func(fruit *domain.Fruit) error
+}
+
+// BatchResult is the outcome of one batch operation
+type BatchResult struct {
+	// Fruit is the created or updated fruit
+	Fruit *domain.Fruit
+	Err   error
+}
+
+// Batch runs a list of operations on behalf of owner, the fruits of other
+// owners being reported as not found, and returns one result per operation.
+// Operations run in order and each one succeeds or fails on its own, unless
+// atomic is set: then either every operation is applied or none is, and the
+// operations that did not fail report ErrBatchAborted.
+func (s *FruitService) Batch(ctx context.Context, owner string, ops []BatchOp, atomic bool) []BatchResult {
+	if atomic {
+		return s.atomicBatch(ctx, owner, ops)
+	}
+
+	results := make([]BatchResult, len(ops))
+	for i, op := range ops {
+		var fruit *domain.Fruit
+		err := s.repo.Transaction(ctx, func(tx repository.FruitTx) error {
+			var err error
+			fruit, err = applyOp(ctx, tx, owner, op)
+			return err
+		})
+		results[i] = BatchResult{Fruit: fruit, Err: err}
+	}
+	return results
+}
+
+// applyOp runs a batch operation of owner in tx, as CreateFruit,
+// UpdateFruit and DeleteFruit do, returning the created or updated fruit
+func applyOp(ctx context.Context, tx repository.FruitTx, owner string, op BatchOp) (*domain.Fruit, error) {
+	switch op.Kind {
+	case BatchCreate:
+		return createFruit(ctx, tx, owner, op.Name, op.Quantity, op.Price)
+	case BatchUpdate:
+		return updateFruit(ctx, tx, owner, op.ID, op.Apply)
+	case BatchDelete:
+		return nil, deleteFruit(ctx, tx, owner, op.ID)
+	}
+	return nil, fmt.Errorf("unknown batch operation %q", op.Kind)
+}
+
+// errBatchFailed rolls back the transaction of an atomic batch
+var errBatchFailed = errors.New("batch failed")
+
+// atomicBatch runs every operation in a single transaction, later
+// operations seeing the changes of earlier ones, and commits it if none
+// failed
+func (s *FruitService) atomicBatch(ctx context.Context, owner string, ops []BatchOp) []BatchResult {
+	results := make([]BatchResult, len(ops))
+
+	err := s.repo.Transaction(ctx, func(tx repository.FruitTx) error {
+		failed := false
+		for i, op := range ops {
+			fruit, err := applyOp(ctx, tx, owner, op)
+			results[i] = BatchResult{Fruit: fruit, Err: err}
+			failed = failed || err != nil
+		}
+		if failed {
+			return errBatchFailed
+		}
+		return nil
+	})
+
+	switch {
+	case errors.Is(err, errBatchFailed):
+		for i := range results {
+			if results[i].Err == nil {
+				results[i] = BatchResult{Err: ErrBatchAborted}
+			}
+		}
+	case err != nil:
+		for i := range results {
+			results[i] = BatchResult{Err: err}
+		}
+	}
+	return results
+}
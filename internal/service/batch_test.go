@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/pkg/kvs"
+)
+
+func TestFruitService_Batch(t *testing.T) {
+	setPrice := func(price float64) func(f *domain.Fruit) error {
+		return func(f *domain.Fruit) error { f.Price = price; return nil }
+	}
+
+	tests := []struct {
+		name          string
+		owner         string
+		atomic        bool
+		ops           func(existing string) []BatchOp
+		expectedErrs  []error
+		expectedPrice float64
+		expectDeleted bool
+	}{
+		{
+			name:  "IndependentOperations",
+			owner: "test",
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchCreate, Name: "pera", Quantity: 1, Price: 10},
+					{Kind: BatchUpdate, ID: existing, Apply: setPrice(900)},
+					{Kind: BatchCreate, Name: "pera1", Quantity: 1, Price: 10},
+				}
+			},
+			expectedErrs:  []error{nil, nil, errors.New("invalid")},
+			expectedPrice: 900,
+		},
+		{
+			name:   "AtomicSuccess",
+			owner:  "test",
+			atomic: true,
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchUpdate, ID: existing, Apply: setPrice(900)},
+					{Kind: BatchUpdate, ID: existing, Apply: func(f *domain.Fruit) error {
+						// Sees the price staged by the previous operation
+						f.Price += 1
+						return nil
+					}},
+					{Kind: BatchCreate, Name: "pera", Quantity: 1, Price: 10},
+				}
+			},
+			expectedErrs:  []error{nil, nil, nil},
+			expectedPrice: 901,
+		},
+		{
+			name:   "AtomicFailure",
+			owner:  "test",
+			atomic: true,
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchUpdate, ID: existing, Apply: setPrice(900)},
+					{Kind: BatchDelete, ID: missingID},
+				}
+			},
+			expectedErrs:  []error{ErrBatchAborted, domain.ErrFruitNotFound},
+			expectedPrice: 1000,
+		},
+		{
+			name:   "AtomicDeleteThenUpdate",
+			owner:  "test",
+			atomic: true,
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchDelete, ID: existing},
+					{Kind: BatchUpdate, ID: existing, Apply: setPrice(900)},
+				}
+			},
+			expectedErrs:  []error{ErrBatchAborted, domain.ErrFruitNotFound},
+			expectedPrice: 1000,
+		},
+		{
+			name:   "AtomicDelete",
+			owner:  "test",
+			atomic: true,
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchUpdate, ID: existing, Apply: setPrice(900)},
+					{Kind: BatchDelete, ID: existing},
+				}
+			},
+			expectedErrs:  []error{nil, nil},
+			expectDeleted: true,
+		},
+		{
+			name:  "OtherOwner",
+			owner: "other",
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchUpdate, ID: existing, Apply: setPrice(900)},
+					{Kind: BatchDelete, ID: existing},
+				}
+			},
+			expectedErrs:  []error{domain.ErrFruitNotFound, domain.ErrFruitNotFound},
+			expectedPrice: 1000,
+		},
+		{
+			name:   "AtomicOtherOwner",
+			owner:  "other",
+			atomic: true,
+			ops: func(existing string) []BatchOp {
+				return []BatchOp{
+					{Kind: BatchCreate, Name: "pera", Quantity: 1, Price: 10},
+					{Kind: BatchDelete, ID: existing},
+				}
+			},
+			expectedErrs:  []error{ErrBatchAborted, domain.ErrFruitNotFound},
+			expectedPrice: 1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := kvs.NewClient()
+			repo := repository.NewKVSFruitRepository(client)
+			service := NewFruitService(repo)
+			ctx := context.Background()
+			fruit, err := service.CreateFruit(ctx, "manzana", 12, 1000, "test")
+			if err != nil {
+				t.Fatalf("Failed to create fruit: %v", err)
+			}
+
+			// Action
+			results := service.Batch(ctx, tt.owner, tt.ops(fruit.ID), tt.atomic)
+
+			// Assertions
+			if len(results) != len(tt.expectedErrs) {
+				t.Fatalf("Expected %d results, got %d", len(tt.expectedErrs), len(results))
+			}
+			for i, expected := range tt.expectedErrs {
+				switch {
+				case expected == nil && results[i].Err != nil:
+					t.Errorf("Result %d: expected no error, got %v", i, results[i].Err)
+				case expected != nil && results[i].Err == nil:
+					t.Errorf("Result %d: expected error %v, got nil", i, expected)
+				case expected == ErrBatchAborted || expected == domain.ErrFruitNotFound:
+					if !errors.Is(results[i].Err, expected) {
+						t.Errorf("Result %d: expected error %v, got %v", i, expected, results[i].Err)
+					}
+				}
+			}
+
+			stored, err := service.GetFruitByID(ctx, fruit.ID)
+			if tt.expectDeleted {
+				if !errors.Is(err, domain.ErrFruitNotFound) {
+					t.Errorf("Expected fruit to be deleted, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to get fruit: %v", err)
+			}
+			if stored.Price != tt.expectedPrice {
+				t.Errorf("Expected Price %f, got %f", tt.expectedPrice, stored.Price)
+			}
+		})
+	}
+}
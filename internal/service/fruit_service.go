@@ -11,7 +11,9 @@ import (
 	"fruitsapi/internal/repository"
 )
 
-// FruitService handles business logic for fruit operations
+// FruitService handles business logic for fruit operations. Each change is
+// stored together with the domain events it raises, in the outbox of the
+// repository, and with its entry in the audit log.
 type FruitService struct {
 	repo repository.FruitRepository
 }
@@ -25,6 +27,41 @@ func NewFruitService(repo repository.FruitRepository) *FruitService {
 
 // CreateFruit validates and creates a new fruit
 func (s *FruitService) CreateFruit(ctx context.Context, name string, quantity int, price float64, owner string) (*domain.Fruit, error) {
+	var fruit *domain.Fruit
+	
+	// Save the fruit
+	err := s.repo.Transaction(ctx, func(tx repository.FruitTx) error {
+		var err error
+		fruit, err = createFruit(ctx, tx, owner, name, quantity, price)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fruit, nil
+}
+
+// createFruit validates a new fruit of owner and stores it in tx, with its
+// audit entry and its event
+func createFruit(ctx context.Context, tx repository.FruitTx, owner, name string, quantity int, price float64) (*domain.Fruit, error) {
+	fruit, err := newFruit(name, quantity, price, owner)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Save(fruit); err != nil {
+		return nil, err
+	}
+	if err := audit(ctx, tx, domain.AuditCreate, nil, fruit); err != nil {
+		return nil, err
+	}
+	if err := tx.AddToOutbox(domain.FruitCreated{Fruit: *fruit, At: fruit.DateCreated}); err != nil {
+		return nil, err
+	}
+	return fruit, nil
+}
+
+// newFruit builds and validates a fruit with a new ID
+func newFruit(name string, quantity int, price float64, owner string) (*domain.Fruit, error) {
 	// Generate a new UUID
 	id := uuid.New().String()
 	
@@ -33,14 +70,179 @@ func (s *FruitService) CreateFruit(ctx context.Context, name string, quantity in
 	
 	// Validate the fruit
 	if err := fruit.Validate(); err != nil {
-		return nil, fmt.Errorf("invalid fruit: %w", err)
+		return nil, fmt.Errorf("%w: %w", domain.ErrInvalidFruit, err)
 	}
-	
-	// Save the fruit
-	return s.repo.Save(ctx, fruit)
+	return fruit, nil
 }
 
 // GetFruitByID retrieves a fruit by its ID
 func (s *FruitService) GetFruitByID(ctx context.Context, id string) (*domain.Fruit, error) {
+	if err := domain.ValidateFruitID(id); err != nil {
+		return nil, err
+	}
 	return s.repo.GetByID(ctx, id)
 }
+
+// GetFruitsByIDs retrieves several fruits by their IDs, in the order
+// given, and lists the IDs of the fruits that do not exist
+func (s *FruitService) GetFruitsByIDs(ctx context.Context, ids []string) ([]*domain.Fruit, []string, error) {
+	for _, id := range ids {
+		if err := domain.ValidateFruitID(id); err != nil {
+			return nil, nil, fmt.Errorf("%w: %q", err, id)
+		}
+	}
+
+	found, err := s.repo.GetByIDs(ctx, ids)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fruits := make([]*domain.Fruit, 0, len(found))
+	var notFound []string
+	for i, fruit := range found {
+		if fruit == nil {
+			notFound = append(notFound, ids[i])
+			continue
+		}
+		fruits = append(fruits, fruit)
+	}
+	return fruits, notFound, nil
+}
+
+// UpdateFruit atomically applies a change to a fruit of owner, the fruits
+// of other owners being reported as not found. The ID, creation date and
+// owner cannot be changed, and the result must still be a valid fruit.
+func (s *FruitService) UpdateFruit(ctx context.Context, owner, id string, apply func(fruit *domain.Fruit) error) (*domain.Fruit, error) {
+	var fruit *domain.Fruit
+	err := s.repo.Transaction(ctx, func(tx repository.FruitTx) error {
+		var err error
+		fruit, err = updateFruit(ctx, tx, owner, id, apply)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fruit, nil
+}
+
+// updateFruit applies a change to a fruit of owner and stores it in tx,
+// with its audit entry and its events
+func updateFruit(ctx context.Context, tx repository.FruitTx, owner, id string, apply func(fruit *domain.Fruit) error) (*domain.Fruit, error) {
+	fruit, err := getOwnedFruit(tx, owner, id)
+	if err != nil {
+		return nil, err
+	}
+	previous := *fruit
+	if err := applyChange(fruit, apply); err != nil {
+		return nil, err
+	}
+	if err := tx.Save(fruit); err != nil {
+		return nil, err
+	}
+	if err := audit(ctx, tx, domain.AuditUpdate, &previous, fruit); err != nil {
+		return nil, err
+	}
+	if err := tx.AddToOutbox(domain.UpdateEvents(previous, *fruit)...); err != nil {
+		return nil, err
+	}
+	return fruit, nil
+}
+
+// getFruit retrieves a fruit in a transaction, rejecting IDs that are not
+// fruit IDs before they reach the repository
+func getFruit(tx repository.FruitTx, id string) (*domain.Fruit, error) {
+	if err := domain.ValidateFruitID(id); err != nil {
+		return nil, err
+	}
+	return tx.GetByID(id)
+}
+
+// getOwnedFruit retrieves a fruit of owner in a transaction, the fruits of
+// other owners being reported as not found
+func getOwnedFruit(tx repository.FruitTx, owner, id string) (*domain.Fruit, error) {
+	fruit, err := getFruit(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if fruit.Owner != owner {
+		return nil, domain.ErrFruitNotFound
+	}
+	return fruit, nil
+}
+
+// applyChange applies a change to fruit and checks the result
+func applyChange(fruit *domain.Fruit, apply func(fruit *domain.Fruit) error) error {
+	original := *fruit
+	if err := apply(fruit); err != nil {
+		return err
+	}
+
+	switch {
+	case fruit.ID != original.ID:
+		return fmt.Errorf("%w: id", domain.ErrImmutableField)
+	case !fruit.DateCreated.Equal(original.DateCreated):
+		return fmt.Errorf("%w: date_created", domain.ErrImmutableField)
+	case fruit.Owner != original.Owner:
+		return fmt.Errorf("%w: owner", domain.ErrImmutableField)
+	}
+
+	fruit.DateLastUpdated = time.Now()
+	if err := fruit.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", domain.ErrInvalidFruit, err)
+	}
+	return nil
+}
+
+// DeleteFruit removes a fruit of owner by its ID, the fruits of other
+// owners being reported as not found
+func (s *FruitService) DeleteFruit(ctx context.Context, owner, id string) error {
+	return s.repo.Transaction(ctx, func(tx repository.FruitTx) error {
+		return deleteFruit(ctx, tx, owner, id)
+	})
+}
+
+// deleteFruit removes a fruit of owner in tx, with its audit entry and its
+// event
+func deleteFruit(ctx context.Context, tx repository.FruitTx, owner, id string) error {
+	fruit, err := getOwnedFruit(tx, owner, id)
+	if err != nil {
+		return err
+	}
+	if err := tx.Delete(id); err != nil {
+		return err
+	}
+	if err := audit(ctx, tx, domain.AuditDelete, fruit, nil); err != nil {
+		return err
+	}
+	return tx.AddToOutbox(domain.FruitDeleted{Fruit: *fruit, At: time.Now()})
+}
+
+// WatchFruits returns the changes of the fruits of owner, or of every fruit
+// when owner is empty, in order, from now on or after revision when it is
+// not 0. Updates that change the quantity are reported as
+// domain.ChangeStockChanged.
+func (s *FruitService) WatchFruits(ctx context.Context, owner string, revision uint64) (<-chan domain.FruitChange, error) {
+	all, err := s.repo.Watch(ctx, revision)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(chan domain.FruitChange)
+	go func() {
+		defer close(changes)
+		for change := range all {
+			if change.Type != domain.ChangeLagged && owner != "" && change.Fruit.Owner != owner {
+				continue
+			}
+			if change.Type == domain.ChangeUpdated && change.Fruit.Quantity != change.Previous.Quantity {
+				change.Type = domain.ChangeStockChanged
+			}
+			select {
+			case changes <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return changes, nil
+}
@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
 	"fruitsapi/internal/domain"
@@ -10,6 +11,9 @@ import (
 	"fruitsapi/pkg/kvs"
 )
 
+// missingID is a fruit ID that no test creates
+const missingID = "00000000-0000-4000-8000-000000000000"
+
 func TestFruitService_CreateFruit(t *testing.T) {
 	// Setup
 	client := kvs.NewClient()
@@ -137,7 +141,7 @@ func TestFruitService_GetFruitByID(t *testing.T) {
 		},
 		{
 			name:        "NonExistentFruit",
-			id:          "non-existent-id",
+			id:          missingID,
 			expectError: true,
 		},
 	}
@@ -172,3 +176,226 @@ func TestFruitService_GetFruitByID(t *testing.T) {
 		})
 	}
 }
+
+func TestFruitService_UpdateFruit(t *testing.T) {
+	// Setup
+	client := kvs.NewClient()
+	repo := repository.NewKVSFruitRepository(client)
+	service := NewFruitService(repo)
+	ctx := context.Background()
+
+	// Create a fruit first
+	fruit, err := service.CreateFruit(ctx, "manzana", 12, 1000, "test")
+	if err != nil {
+		t.Fatalf("Failed to create fruit: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name          string
+		owner         string
+		id            string
+		apply         func(f *domain.Fruit) error
+		expectedErr   error
+		expectError   bool
+		expectedPrice float64
+	}{
+		{
+			name:          "ChangePrice",
+			owner:         "test",
+			id:            fruit.ID,
+			apply:         func(f *domain.Fruit) error { f.Price = 900; return nil },
+			expectedPrice: 900,
+		},
+		{
+			name:          "ChangeOwner",
+			owner:         "test",
+			id:            fruit.ID,
+			apply:         func(f *domain.Fruit) error { f.Owner = "other"; return nil },
+			expectedErr:   domain.ErrImmutableField,
+			expectedPrice: 900,
+		},
+		{
+			name:          "InvalidResult",
+			owner:         "test",
+			id:            fruit.ID,
+			apply:         func(f *domain.Fruit) error { f.Quantity = 0; return nil },
+			expectError:   true,
+			expectedPrice: 900,
+		},
+		{
+			name:          "ApplyFails",
+			owner:         "test",
+			id:            fruit.ID,
+			apply:         func(f *domain.Fruit) error { f.Price = 1; return errors.New("boom") },
+			expectError:   true,
+			expectedPrice: 900,
+		},
+		{
+			name:          "InvalidStatus",
+			owner:         "test",
+			id:            fruit.ID,
+			apply:         func(f *domain.Fruit) error { f.Status = "madura"; return nil },
+			expectedErr:   domain.ErrInvalidFruit,
+			expectedPrice: 900,
+		},
+		{
+			name:          "OtherOwner",
+			owner:         "other",
+			id:            fruit.ID,
+			apply:         func(f *domain.Fruit) error { f.Price = 1; return nil },
+			expectedErr:   domain.ErrFruitNotFound,
+			expectedPrice: 900,
+		},
+		{
+			name:          "NonExistentFruit",
+			owner:         "test",
+			id:            missingID,
+			apply:         func(f *domain.Fruit) error { return nil },
+			expectedErr:   domain.ErrFruitNotFound,
+			expectedPrice: 900,
+		},
+		{
+			name:          "OtherRecordKey",
+			owner:         "test",
+			id:            "audit/head",
+			apply:         func(f *domain.Fruit) error { return nil },
+			expectedErr:   domain.ErrInvalidFruitID,
+			expectedPrice: 900,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Action
+			_, err := service.UpdateFruit(ctx, tt.owner, tt.id, tt.apply)
+
+			// Assertions
+			if tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectError && err == nil {
+				t.Errorf("Expected error, got nil")
+			}
+			if !tt.expectError && tt.expectedErr == nil && err != nil {
+				t.Errorf("Expected no error, got %v", err)
+			}
+
+			// Failed updates must leave the stored fruit untouched
+			stored, err := service.GetFruitByID(ctx, fruit.ID)
+			if err != nil {
+				t.Fatalf("Failed to get fruit: %v", err)
+			}
+			if stored.Price != tt.expectedPrice {
+				t.Errorf("Expected Price %f, got %f", tt.expectedPrice, stored.Price)
+			}
+			if stored.Owner != "test" {
+				t.Errorf("Expected Owner %s, got %s", "test", stored.Owner)
+			}
+		})
+	}
+}
+
+func TestFruitService_OutboxEvents(t *testing.T) {
+	tests := []struct {
+		name     string
+		action   func(service *FruitService, existing string)
+		expected []string
+	}{
+		{
+			name: "Create",
+			action: func(service *FruitService, existing string) {
+				service.CreateFruit(context.Background(), "pera", 1, 10, "test")
+			},
+			expected: []string{domain.EventFruitCreated},
+		},
+		{
+			name: "UpdatePrice",
+			action: func(service *FruitService, existing string) {
+				service.UpdateFruit(context.Background(), "test", existing, func(f *domain.Fruit) error { f.Price = 900; return nil })
+			},
+			expected: []string{domain.EventFruitUpdated},
+		},
+		{
+			name: "UpdateStockAndStatus",
+			action: func(service *FruitService, existing string) {
+				service.UpdateFruit(context.Background(), "test", existing, func(f *domain.Fruit) error {
+					f.Quantity = 3
+					f.Status = "podrida"
+					return nil
+				})
+			},
+			expected: []string{domain.EventFruitUpdated, domain.EventStockAdjusted, domain.EventStatusChanged},
+		},
+		{
+			name: "FailedUpdate",
+			action: func(service *FruitService, existing string) {
+				service.UpdateFruit(context.Background(), "test", existing, func(f *domain.Fruit) error { return errors.New("boom") })
+			},
+		},
+		{
+			name: "Delete",
+			action: func(service *FruitService, existing string) {
+				service.DeleteFruit(context.Background(), "test", existing)
+			},
+			expected: []string{domain.EventFruitDeleted},
+		},
+		{
+			name: "AtomicBatch",
+			action: func(service *FruitService, existing string) {
+				service.Batch(context.Background(), "test", []BatchOp{
+					{Kind: BatchCreate, Name: "pera", Quantity: 1, Price: 10},
+					{Kind: BatchDelete, ID: existing},
+				}, true)
+			},
+			expected: []string{domain.EventFruitCreated, domain.EventFruitDeleted},
+		},
+		{
+			name: "AbortedBatch",
+			action: func(service *FruitService, existing string) {
+				service.Batch(context.Background(), "test", []BatchOp{
+					{Kind: BatchCreate, Name: "pera", Quantity: 1, Price: 10},
+					{Kind: BatchDelete, ID: missingID},
+				}, true)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := kvs.NewClient()
+			service := NewFruitService(repository.NewKVSFruitRepository(client))
+			outbox := repository.NewKVSOutboxRepository(client)
+			fruit, err := service.CreateFruit(context.Background(), "manzana", 12, 1000, "test")
+			if err != nil {
+				t.Fatalf("Failed to create fruit: %v", err)
+			}
+			if err := outbox.Delivered(context.Background(), mustPending(t, outbox)[0].ID); err != nil {
+				t.Fatalf("Failed to clear the outbox: %v", err)
+			}
+
+			// Action
+			tt.action(service, fruit.ID)
+
+			// Assertions: the events are stored with the change, in order
+			var names []string
+			for _, entry := range mustPending(t, outbox) {
+				names = append(names, entry.Event)
+			}
+			if got, want := strings.Join(names, ","), strings.Join(tt.expected, ","); got != want {
+				t.Errorf("Expected events %q, got %q", want, got)
+			}
+		})
+	}
+}
+
+// mustPending returns the pending entries of the outbox
+func mustPending(t *testing.T, outbox repository.OutboxRepository) []domain.OutboxEntry {
+	t.Helper()
+	entries, err := outbox.Pending(context.Background(), 0)
+	if err != nil {
+		t.Fatalf("Failed to list the outbox: %v", err)
+	}
+	return entries
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"context"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+// OutboxService lets operators inspect the outbox and replay its dead
+// letters
+type OutboxService struct {
+	repo repository.OutboxRepository
+}
+
+// NewOutboxService creates a new instance of OutboxService
+func NewOutboxService(repo repository.OutboxRepository) *OutboxService {
+	return &OutboxService{
+		repo: repo,
+	}
+}
+
+// Pending lists up to limit entries waiting for delivery, oldest first
+func (s *OutboxService) Pending(ctx context.Context, limit int) ([]domain.OutboxEntry, error) {
+	return s.repo.Pending(ctx, limit)
+}
+
+// DeadLetters lists up to limit entries given up by the relay, oldest first
+func (s *OutboxService) DeadLetters(ctx context.Context, limit int) ([]domain.OutboxEntry, error) {
+	return s.repo.DeadLetters(ctx, limit)
+}
+
+// Replay queues a dead letter for delivery again
+func (s *OutboxService) Replay(ctx context.Context, id string) (*domain.OutboxEntry, error) {
+	return s.repo.Replay(ctx, id)
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+// WebhookService manages the webhooks of the owners. A webhook of another
+// owner is reported as not found.
+type WebhookService struct {
+	repo repository.WebhookRepository
+}
+
+// NewWebhookService creates a new instance of WebhookService
+func NewWebhookService(repo repository.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		repo: repo,
+	}
+}
+
+// CreateWebhook validates and creates a new active webhook
+func (s *WebhookService) CreateWebhook(ctx context.Context, owner, url string, events []string, secret string) (*domain.Webhook, error) {
+	// Drop duplicate events, keeping their order
+	var names []string
+	for _, name := range events {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+
+	webhook := domain.NewWebhook(uuid.New().String(), owner, url, names, secret)
+	if err := webhook.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid webhook: %w", err)
+	}
+	if err := s.repo.Save(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns the webhooks of owner, oldest first
+func (s *WebhookService) ListWebhooks(ctx context.Context, owner string) ([]domain.Webhook, error) {
+	return s.repo.List(ctx, owner)
+}
+
+// GetWebhook retrieves a webhook of owner by its ID
+func (s *WebhookService) GetWebhook(ctx context.Context, owner, id string) (*domain.Webhook, error) {
+	webhook, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.Owner != owner {
+		return nil, domain.ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes a webhook of owner with its deliveries
+func (s *WebhookService) DeleteWebhook(ctx context.Context, owner, id string) error {
+	if _, err := s.GetWebhook(ctx, owner, id); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
+
+// EnableWebhook activates a webhook of owner again, after it was disabled
+// for failing, and resets its failures. Its queued deliveries are then
+// attempted again.
+func (s *WebhookService) EnableWebhook(ctx context.Context, owner, id string) (*domain.Webhook, error) {
+	return s.repo.Update(ctx, id, func(webhook *domain.Webhook) error {
+		if webhook.Owner != owner {
+			return domain.ErrWebhookNotFound
+		}
+		webhook.Active = true
+		webhook.ConsecutiveFailures = 0
+		webhook.DisabledAt = time.Time{}
+		return nil
+	})
+}
+
+// Deliveries returns up to limit deliveries of a webhook of owner, the
+// queued ones then the completed ones, most recent first
+func (s *WebhookService) Deliveries(ctx context.Context, owner, id string, limit int) ([]domain.WebhookDelivery, error) {
+	if _, err := s.GetWebhook(ctx, owner, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Deliveries(ctx, id, limit)
+}
@@ -0,0 +1,375 @@
+// Package webhook posts the domain events to the URLs subscribed by the
+// owners of the fruits, signed with the secret of each webhook
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"fruitsapi/internal/backoff"
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+)
+
+// Headers of the webhook requests
+const (
+	SignatureHeader = "X-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+)
+
+// SignatureTolerance is the largest difference between the signed
+// timestamp of a request and the clock of a receiver that receivers should
+// accept, rejecting older requests as replays
+const SignatureTolerance = 5 * time.Minute
+
+// Defaults of Config
+const (
+	DefaultWorkers      = 4
+	DefaultPollInterval = 500 * time.Millisecond
+	DefaultTimeout      = 10 * time.Second
+	DefaultMaxAttempts  = 8
+	DefaultMinBackoff   = time.Second
+	DefaultMaxBackoff   = 10 * time.Minute
+	DefaultDisableAfter = 20
+)
+
+// maxResponseBytes bounds the part of a response body read before closing it
+const maxResponseBytes = 64 << 10
+
+// deliveriesTotal counts the attempts that succeeded and failed, the
+// deliveries given up and the webhooks disabled
+var deliveriesTotal = expvar.NewMap("webhook_deliveries_total")
+
+// Config holds the settings of a Dispatcher, zero fields taking the
+// defaults
+type Config struct {
+	// Workers is the number of deliveries attempted concurrently
+	Workers int
+	// PollInterval is the interval between two reads of the queue
+	PollInterval time.Duration
+	// Timeout bounds each request, response included
+	Timeout time.Duration
+	// MaxAttempts is the number of failed attempts after which a delivery
+	// is given up
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry, doubling with every
+	// attempt up to MaxBackoff
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// DisableAfter is the number of consecutive failed attempts, across
+	// deliveries, after which a webhook is disabled
+	DisableAfter int
+}
+
+// Payload is the body posted to a webhook
+type Payload struct {
+	// ID identifies the delivery, which may be posted more than once
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher queues the deliveries of the events to the webhooks wanting
+// them, and posts them from a pool of workers
+type Dispatcher struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	cfg    Config
+	now    func() time.Time
+	// checkAddress vets the address of every connection to a webhook
+	checkAddress func(ip netip.Addr) error
+}
+
+// NewDispatcher creates a new instance of Dispatcher
+func NewDispatcher(repo repository.WebhookRepository, cfg Config) *Dispatcher {
+	if cfg.Workers <= 0 {
+		cfg.Workers = DefaultWorkers
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = DefaultPollInterval
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultTimeout
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.MinBackoff <= 0 {
+		cfg.MinBackoff = DefaultMinBackoff
+	}
+	if cfg.MaxBackoff < cfg.MinBackoff {
+		cfg.MaxBackoff = max(DefaultMaxBackoff, cfg.MinBackoff)
+	}
+	if cfg.DisableAfter <= 0 {
+		cfg.DisableAfter = DefaultDisableAfter
+	}
+	d := &Dispatcher{
+		repo:         repo,
+		cfg:          cfg,
+		now:          time.Now,
+		checkAddress: domain.CheckWebhookAddress,
+	}
+
+	// Connect directly, never through a proxy, so that the address checked
+	// is the one of the webhook
+	dialer := &net.Dialer{Timeout: cfg.Timeout, Control: d.control}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	d.client = &http.Client{
+		Transport: transport,
+		Timeout:   cfg.Timeout,
+		// A redirect is a failure, the URL must be fixed
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	return d
+}
+
+// control refuses the connections to the addresses rejected by
+// checkAddress. It runs once the host name of the webhook is resolved, so
+// that a name resolving to an internal address is refused even though the
+// URL passed validation.
+func (d *Dispatcher) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if err := d.checkAddress(ip); err != nil {
+		return fmt.Errorf("refusing to connect to webhook: %w", err)
+	}
+	return nil
+}
+
+// Handle queues a delivery of e to every active webhook of the owner of the
+// fruit subscribed to it. It is an events.Handler, to subscribe
+// synchronously so that a failure makes the relay deliver e again.
+func (d *Dispatcher) Handle(ctx context.Context, e domain.Event) error {
+	webhooks, err := d.repo.List(ctx, e.AggregateOwner())
+	if err != nil {
+		return err
+	}
+
+	var deliveries []domain.WebhookDelivery
+	for i := range webhooks {
+		if !webhooks[i].Wants(e) {
+			continue
+		}
+		delivery, err := domain.NewWebhookDelivery(webhooks[i].ID, e)
+		if err != nil {
+			return err
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return d.repo.Enqueue(ctx, deliveries...)
+}
+
+// Run dispatches the due deliveries every poll interval until ctx is done
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := d.DispatchDue(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error dispatching webhook deliveries: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DispatchDue attempts the due deliveries with the pool of workers, waits
+// for them and returns the number that succeeded. A failed attempt is
+// retried after an exponential backoff, and the delivery given up after
+// MaxAttempts. Deliveries of disabled webhooks wait until they are enabled.
+func (d *Dispatcher) DispatchDue(ctx context.Context) (int, error) {
+	due, err := d.repo.Due(ctx, d.now())
+	if err != nil {
+		return 0, err
+	}
+
+	jobs := make(chan domain.WebhookDelivery)
+	var mu sync.Mutex
+	succeeded := 0
+	var wg sync.WaitGroup
+	for i := 0; i < min(d.cfg.Workers, len(due)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for delivery := range jobs {
+				if d.attempt(ctx, delivery) {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- delivery
+	}
+	close(jobs)
+	wg.Wait()
+	return succeeded, ctx.Err()
+}
+
+// attempt posts a delivery and records the outcome, reporting whether the
+// webhook accepted it
+func (d *Dispatcher) attempt(ctx context.Context, delivery domain.WebhookDelivery) bool {
+	webhook, err := d.repo.GetByID(ctx, delivery.WebhookID)
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		// Left behind when the webhook was deleted
+		d.record(ctx, d.repo.Discard(ctx, delivery.ID))
+		return false
+	}
+	if err != nil {
+		log.Printf("Error retrieving webhook %s: %v", delivery.WebhookID, err)
+		return false
+	}
+	if !webhook.Active {
+		return false
+	}
+
+	statusCode, postErr := d.post(ctx, webhook, delivery)
+	if ctx.Err() != nil {
+		// Stopping, the attempt does not count
+		return false
+	}
+	delivery.Attempts++
+	delivery.StatusCode = statusCode
+	if postErr == nil {
+		deliveriesTotal.Add("succeeded", 1)
+		delivery.Status = domain.DeliverySucceeded
+		delivery.LastError = ""
+		delivery.CompletedAt = d.now()
+		d.record(ctx, d.repo.Complete(ctx, delivery))
+		if webhook.ConsecutiveFailures > 0 {
+			d.record(ctx, d.updateFailures(ctx, webhook.ID, false))
+		}
+		return true
+	}
+
+	deliveriesTotal.Add("failed", 1)
+	delivery.LastError = postErr.Error()
+	if delivery.Attempts >= d.cfg.MaxAttempts {
+		log.Printf("Giving up delivery %s of %s to webhook %s after %d attempts: %v", delivery.ID, delivery.Event, webhook.ID, delivery.Attempts, postErr)
+		deliveriesTotal.Add("given_up", 1)
+		delivery.Status = domain.DeliveryFailed
+		delivery.CompletedAt = d.now()
+		d.record(ctx, d.repo.Complete(ctx, delivery))
+	} else {
+		delivery.NextAttempt = d.now().Add(backoff.Delay(d.cfg.MinBackoff, d.cfg.MaxBackoff, delivery.Attempts))
+		d.record(ctx, d.repo.Reschedule(ctx, delivery))
+	}
+	d.record(ctx, d.updateFailures(ctx, webhook.ID, true))
+	return false
+}
+
+// post sends a delivery to its webhook and returns the status code of the
+// response, failing unless it is 2xx
+func (d *Dispatcher) post(ctx context.Context, webhook *domain.Webhook, delivery domain.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(Payload{ID: delivery.ID, Event: delivery.Event, CreatedAt: delivery.CreatedAt, Data: delivery.Payload})
+	if err != nil {
+		return 0, fmt.Errorf("error encoding payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	timestamp := d.now().Unix()
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(webhook.Secret, timestamp, body))
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxResponseBytes))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// updateFailures counts a failed attempt of a webhook, disabling it after
+// DisableAfter in a row, or resets the count after a success
+func (d *Dispatcher) updateFailures(ctx context.Context, id string, failed bool) error {
+	_, err := d.repo.Update(ctx, id, func(webhook *domain.Webhook) error {
+		if !failed {
+			webhook.ConsecutiveFailures = 0
+			return nil
+		}
+		webhook.ConsecutiveFailures++
+		if webhook.Active && webhook.ConsecutiveFailures >= d.cfg.DisableAfter {
+			log.Printf("Disabling webhook %s after %d consecutive failed attempts", webhook.ID, webhook.ConsecutiveFailures)
+			deliveriesTotal.Add("disabled", 1)
+			webhook.Active = false
+			webhook.DisabledAt = d.now()
+		}
+		return nil
+	})
+	if errors.Is(err, domain.ErrWebhookNotFound) {
+		return nil
+	}
+	return err
+}
+
+// record logs the failure to store the outcome of an attempt, which is
+// then attempted again
+func (d *Dispatcher) record(ctx context.Context, err error) {
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Error recording webhook delivery: %v", err)
+	}
+}
+
+// Sign returns the X-Signature of body sent at timestamp, in Unix seconds:
+// sha256= followed by the hex HMAC-SHA256 of the timestamp, a dot and body,
+// keyed with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is the X-Signature of body sent at
+// timestamp, comparing in constant time. Receivers should also check that
+// the timestamp is within SignatureTolerance of their clock.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(signature), []byte(Sign(secret, timestamp, body)))
+}
@@ -0,0 +1,349 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fruitsapi/internal/domain"
+	"fruitsapi/internal/repository"
+	"fruitsapi/pkg/kvs"
+)
+
+const secret = "0123456789abcdef"
+
+// receiver is a webhook endpoint answering the status codes of statuses in
+// turn, the last one repeated, and recording the requests it verified
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []Payload
+	invalid  int
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	body, _ := io.ReadAll(r.Body)
+	var payload Payload
+	timestamp, err := strconv.ParseInt(r.Header.Get(TimestampHeader), 10, 64)
+	if err != nil || !Verify(secret, timestamp, body, r.Header.Get(SignatureHeader)) || json.Unmarshal(body, &payload) != nil ||
+		r.Header.Get(EventHeader) != payload.Event || r.Header.Get(DeliveryHeader) != payload.ID {
+		rc.invalid++
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	rc.requests = append(rc.requests, payload)
+	status := rc.statuses[min(len(rc.requests), len(rc.statuses))-1]
+	w.WriteHeader(status)
+}
+
+func (rc *receiver) count() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return len(rc.requests)
+}
+
+// newDispatcher returns a dispatcher with a webhook of alice posting the
+// stock adjustments to rc
+func newDispatcher(t *testing.T, rc *receiver, cfg Config) (*Dispatcher, repository.WebhookRepository, *domain.Webhook) {
+	t.Helper()
+	server := httptest.NewServer(rc)
+	t.Cleanup(server.Close)
+	repo := repository.NewKVSWebhookRepository(kvs.NewClient())
+	webhook := domain.NewWebhook("hook", "alice", server.URL, []string{domain.EventStockAdjusted}, secret)
+	if err := repo.Save(context.Background(), webhook); err != nil {
+		t.Fatalf("Failed to save webhook: %v", err)
+	}
+	d := NewDispatcher(repo, cfg)
+	// The receiver listens on the loopback interface
+	d.checkAddress = func(netip.Addr) error { return nil }
+	return d, repo, webhook
+}
+
+// publish hands stock adjustments of fruit a of alice to the dispatcher
+func publish(t *testing.T, d *Dispatcher, quantities ...int) {
+	t.Helper()
+	for _, q := range quantities {
+		if err := d.Handle(context.Background(), domain.StockAdjusted{FruitID: "a", Owner: "alice", Quantity: q}); err != nil {
+			t.Fatalf("Failed to handle event: %v", err)
+		}
+	}
+}
+
+// advance stops the clock of d, moved forward by the given duration
+func advance(d *Dispatcher, by time.Duration) {
+	now := d.now().Add(by)
+	d.now = func() time.Time { return now }
+}
+
+func TestDispatcher_Handle(t *testing.T) {
+	// Setup
+	rc := &receiver{statuses: []int{http.StatusOK}}
+	d, repo, _ := newDispatcher(t, rc, Config{})
+	ctx := context.Background()
+
+	// Action: only the stock adjustments of alice are wanted
+	publish(t, d, 1)
+	for _, e := range []domain.Event{
+		domain.StockAdjusted{FruitID: "b", Owner: "bob", Quantity: 2},
+		domain.FruitCreated{Fruit: domain.Fruit{ID: "a", Owner: "alice"}},
+	} {
+		if err := d.Handle(ctx, e); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+	}
+
+	// Assertions
+	due, err := repo.Due(ctx, time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(due) != 1 || due[0].WebhookID != "hook" || due[0].Event != domain.EventStockAdjusted {
+		t.Errorf("Expected one delivery of %s, got %+v", domain.EventStockAdjusted, due)
+	}
+}
+
+func TestDispatcher_DispatchDue(t *testing.T) {
+	// Setup
+	rc := &receiver{statuses: []int{http.StatusNoContent}}
+	d, repo, _ := newDispatcher(t, rc, Config{Workers: 2})
+	ctx := context.Background()
+	publish(t, d, 1, 2, 3)
+
+	// Action
+	n, err := d.DispatchDue(ctx)
+
+	// Assertions: signed requests, logged as succeeded
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 3 || rc.count() != 3 || rc.invalid != 0 {
+		t.Errorf("Expected 3 valid requests, got %d succeeded, %d valid and %d invalid", n, rc.count(), rc.invalid)
+	}
+	deliveries, err := repo.Deliveries(ctx, "hook", 0)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	for _, delivery := range deliveries {
+		if delivery.Status != domain.DeliverySucceeded || delivery.Attempts != 1 || delivery.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected a delivery succeeded at the first attempt, got %+v", delivery)
+		}
+	}
+	var data domain.StockAdjusted
+	if err := json.Unmarshal(rc.requests[0].Data, &data); err != nil || data.FruitID != "a" {
+		t.Errorf("Expected the event as data, got %s (%v)", rc.requests[0].Data, err)
+	}
+}
+
+func TestDispatcher_Retry(t *testing.T) {
+	// Setup
+	rc := &receiver{statuses: []int{http.StatusServiceUnavailable, http.StatusInternalServerError, http.StatusOK}}
+	d, repo, _ := newDispatcher(t, rc, Config{MinBackoff: time.Second, MaxBackoff: time.Minute})
+	ctx := context.Background()
+	publish(t, d, 1)
+
+	// Action: each retry waits twice as long
+	var attempts []int
+	for _, wait := range []time.Duration{0, time.Second - 1, 1, 2*time.Second - 1, 1} {
+		advance(d, wait)
+		if _, err := d.DispatchDue(ctx); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		attempts = append(attempts, rc.count())
+	}
+
+	// Assertions
+	want := []int{1, 1, 2, 2, 3}
+	for i := range want {
+		if attempts[i] != want[i] {
+			t.Fatalf("Expected attempts %v, got %v", want, attempts)
+		}
+	}
+	deliveries, _ := repo.Deliveries(ctx, "hook", 0)
+	if len(deliveries) != 1 || deliveries[0].Status != domain.DeliverySucceeded || deliveries[0].Attempts != 3 {
+		t.Errorf("Expected a delivery succeeded after 3 attempts, got %+v", deliveries)
+	}
+	webhook, _ := repo.GetByID(ctx, "hook")
+	if webhook.ConsecutiveFailures != 0 {
+		t.Errorf("Expected the failures reset, got %d", webhook.ConsecutiveFailures)
+	}
+}
+
+func TestDispatcher_GiveUp(t *testing.T) {
+	// Setup
+	rc := &receiver{statuses: []int{http.StatusInternalServerError}}
+	d, repo, _ := newDispatcher(t, rc, Config{MaxAttempts: 2, MinBackoff: time.Second})
+	ctx := context.Background()
+	publish(t, d, 1)
+
+	// Action
+	for i := 0; i < 3; i++ {
+		if _, err := d.DispatchDue(ctx); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		advance(d, time.Minute)
+	}
+
+	// Assertions
+	if rc.count() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", rc.count())
+	}
+	deliveries, _ := repo.Deliveries(ctx, "hook", 0)
+	if len(deliveries) != 1 || deliveries[0].Status != domain.DeliveryFailed || deliveries[0].StatusCode != http.StatusInternalServerError || deliveries[0].LastError == "" {
+		t.Errorf("Expected a failed delivery, got %+v", deliveries)
+	}
+}
+
+func TestDispatcher_Disable(t *testing.T) {
+	// Setup
+	rc := &receiver{statuses: []int{http.StatusGone}}
+	d, repo, _ := newDispatcher(t, rc, Config{DisableAfter: 2, MinBackoff: time.Second})
+	ctx := context.Background()
+	publish(t, d, 1, 2)
+
+	// Action: the second failed delivery disables the webhook
+	if _, err := d.DispatchDue(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	advance(d, time.Minute)
+	if _, err := d.DispatchDue(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	publish(t, d, 3)
+
+	// Assertions: nothing is attempted or queued once disabled
+	if rc.count() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", rc.count())
+	}
+	webhook, _ := repo.GetByID(ctx, "hook")
+	if webhook.Active || webhook.DisabledAt.IsZero() || webhook.ConsecutiveFailures != 2 {
+		t.Errorf("Expected a disabled webhook, got %+v", webhook)
+	}
+	deliveries, _ := repo.Deliveries(ctx, "hook", 0)
+	if len(deliveries) != 2 {
+		t.Errorf("Expected 2 pending deliveries, got %d", len(deliveries))
+	}
+}
+
+func TestDispatcher_Redirect(t *testing.T) {
+	// Setup
+	rc := &receiver{statuses: []int{http.StatusOK}}
+	redirect := httptest.NewServer(http.RedirectHandler("/", http.StatusFound))
+	defer redirect.Close()
+	d, repo, webhook := newDispatcher(t, rc, Config{})
+	ctx := context.Background()
+	if _, err := repo.Update(ctx, webhook.ID, func(w *domain.Webhook) error {
+		w.URL = redirect.URL
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to update webhook: %v", err)
+	}
+	publish(t, d, 1)
+
+	// Action
+	n, err := d.DispatchDue(ctx)
+
+	// Assertions: a redirect is a failure
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	deliveries, _ := repo.Deliveries(ctx, "hook", 0)
+	if n != 0 || len(deliveries) != 1 || deliveries[0].StatusCode != http.StatusFound {
+		t.Errorf("Expected a failed attempt answered %d, got %+v", http.StatusFound, deliveries)
+	}
+}
+
+func TestDispatcher_InternalAddress(t *testing.T) {
+	// Setup: a webhook whose host name resolves to the loopback interface
+	rc := &receiver{statuses: []int{http.StatusOK}}
+	d, repo, webhook := newDispatcher(t, rc, Config{})
+	d.checkAddress = domain.CheckWebhookAddress
+	ctx := context.Background()
+	if _, err := repo.Update(ctx, webhook.ID, func(w *domain.Webhook) error {
+		w.URL = strings.Replace(w.URL, "127.0.0.1", "localhost", 1)
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to update webhook: %v", err)
+	}
+	publish(t, d, 1)
+
+	// Action
+	n, err := d.DispatchDue(ctx)
+
+	// Assertions: the connection is refused at dial time
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	deliveries, _ := repo.Deliveries(ctx, "hook", 0)
+	if n != 0 || rc.count() != 0 || len(deliveries) != 1 || !strings.Contains(deliveries[0].LastError, "loopback") {
+		t.Errorf("Expected a refused attempt, got %d requests and %+v", rc.count(), deliveries)
+	}
+}
+
+func TestDispatcher_DeletedWebhook(t *testing.T) {
+	// Setup: a delivery left behind by a webhook
+	rc := &receiver{statuses: []int{http.StatusOK}}
+	d, repo, _ := newDispatcher(t, rc, Config{})
+	ctx := context.Background()
+	publish(t, d, 1)
+	due, _ := repo.Due(ctx, d.now())
+	other := domain.NewWebhook("other", "alice", "https://example.com", []string{domain.EventStockAdjusted}, secret)
+	if err := repo.Save(ctx, other); err != nil {
+		t.Fatalf("Failed to save webhook: %v", err)
+	}
+	due[0].WebhookID = "gone"
+	if err := repo.Reschedule(ctx, due[0]); err != nil {
+		t.Fatalf("Failed to reschedule: %v", err)
+	}
+
+	// Action
+	if _, err := d.DispatchDue(ctx); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assertions
+	if left, _ := repo.Due(ctx, d.now()); len(left) != 0 {
+		t.Errorf("Expected the delivery dropped, got %+v", left)
+	}
+	if rc.count() != 0 {
+		t.Errorf("Expected no request, got %d", rc.count())
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	const timestamp = 1792400000
+	signature := Sign(secret, timestamp, body)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "Valid", secret: secret, timestamp: timestamp, body: body, signature: signature, want: true},
+		{name: "OtherSecret", secret: "fedcba9876543210", timestamp: timestamp, body: body, signature: signature},
+		{name: "OtherTimestamp", secret: secret, timestamp: timestamp + 1, body: body, signature: signature},
+		{name: "OtherBody", secret: secret, timestamp: timestamp, body: []byte(`{"id":"2"}`), signature: signature},
+		{name: "Empty", secret: secret, timestamp: timestamp, body: body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Verify(tt.secret, tt.timestamp, tt.body, tt.signature); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// equalJSON reports whether two JSON documents hold the same value
+func equalJSON(t *testing.T, a, b []byte) bool {
+	t.Helper()
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", a, err)
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		t.Fatalf("Invalid JSON %s: %v", b, err)
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		doc         string
+		patch       string
+		expected    string
+		expectedErr error
+	}{
+		{
+			name:     "AddMember",
+			doc:      `{"foo":"bar"}`,
+			patch:    `[{"op":"add","path":"/baz","value":"qux"}]`,
+			expected: `{"baz":"qux","foo":"bar"}`,
+		},
+		{
+			name:     "AddArrayElement",
+			doc:      `{"foo":["bar","baz"]}`,
+			patch:    `[{"op":"add","path":"/foo/1","value":"qux"}]`,
+			expected: `{"foo":["bar","qux","baz"]}`,
+		},
+		{
+			name:     "AppendArrayElement",
+			doc:      `{"foo":["bar"]}`,
+			patch:    `[{"op":"add","path":"/foo/-","value":"qux"}]`,
+			expected: `{"foo":["bar","qux"]}`,
+		},
+		{
+			name:     "RemoveArrayElement",
+			doc:      `{"foo":["bar","qux","baz"]}`,
+			patch:    `[{"op":"remove","path":"/foo/1"}]`,
+			expected: `{"foo":["bar","baz"]}`,
+		},
+		{
+			name:     "ReplaceValue",
+			doc:      `{"baz":"qux","foo":"bar"}`,
+			patch:    `[{"op":"replace","path":"/baz","value":"boo"}]`,
+			expected: `{"baz":"boo","foo":"bar"}`,
+		},
+		{
+			name:     "MoveValue",
+			doc:      `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`,
+			patch:    `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`,
+		},
+		{
+			name:     "CopyValue",
+			doc:      `{"a":{"b":1}}`,
+			patch:    `[{"op":"copy","from":"/a","path":"/c"}]`,
+			expected: `{"a":{"b":1},"c":{"b":1}}`,
+		},
+		{
+			name:     "TestThenReplace",
+			doc:      `{"price":1000}`,
+			patch:    `[{"op":"test","path":"/price","value":1000},{"op":"replace","path":"/price","value":900}]`,
+			expected: `{"price":900}`,
+		},
+		{
+			name:     "EscapedPointer",
+			doc:      `{"a/b":1,"m~n":2}`,
+			patch:    `[{"op":"replace","path":"/a~1b","value":3},{"op":"remove","path":"/m~0n"}]`,
+			expected: `{"a/b":3}`,
+		},
+		{
+			name:        "TestFails",
+			doc:         `{"price":1000}`,
+			patch:       `[{"op":"test","path":"/price","value":900}]`,
+			expectedErr: ErrTestFailed,
+		},
+		{
+			name:        "ReplaceMissingMember",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"replace","path":"/baz","value":1}]`,
+			expectedErr: ErrPathNotFound,
+		},
+		{
+			name:        "AddToMissingParent",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"add","path":"/baz/bat","value":"qux"}]`,
+			expectedErr: ErrPathNotFound,
+		},
+		{
+			name:        "MissingValue",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"add","path":"/baz"}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+		{
+			name:        "UnknownOperation",
+			doc:         `{"foo":"bar"}`,
+			patch:       `[{"op":"merge","path":"/foo","value":1}]`,
+			expectedErr: ErrInvalidPatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var ops []Operation
+			if err := json.Unmarshal([]byte(tt.patch), &ops); err != nil {
+				t.Fatalf("Invalid patch: %v", err)
+			}
+
+			result, err := Apply([]byte(tt.doc), ops)
+
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !equalJSON(t, result, []byte(tt.expected)) {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		{
+			name:     "ReplaceMember",
+			doc:      `{"a":"b"}`,
+			patch:    `{"a":"c"}`,
+			expected: `{"a":"c"}`,
+		},
+		{
+			name:     "RemoveMember",
+			doc:      `{"a":"b","b":"c"}`,
+			patch:    `{"a":null}`,
+			expected: `{"b":"c"}`,
+		},
+		{
+			name:     "NestedObject",
+			doc:      `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"}}`,
+			patch:    `{"title":"Hello!","author":{"familyName":null}}`,
+			expected: `{"title":"Hello!","author":{"givenName":"John"}}`,
+		},
+		{
+			name:     "ReplaceArray",
+			doc:      `{"a":["b"]}`,
+			patch:    `{"a":["c","d"]}`,
+			expected: `{"a":["c","d"]}`,
+		},
+		{
+			name:     "NonObjectPatch",
+			doc:      `{"a":"b"}`,
+			patch:    `["c"]`,
+			expected: `["c"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := MergePatch([]byte(tt.doc), []byte(tt.patch))
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if !equalJSON(t, result, []byte(tt.expected)) {
+				t.Errorf("Expected %s, got %s", tt.expected, result)
+			}
+		})
+	}
+}
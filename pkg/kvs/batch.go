@@ -0,0 +1,78 @@
+package kvs
+
+import (
+	"context"
+	"fmt"
+)
+
+// WriteBatch collects writes to apply together with Client.Write
+type WriteBatch struct {
+	writes []write
+}
+
+// write is a buffered Set, or a Delete
+type write struct {
+	key     string
+	value   interface{}
+	deleted bool
+}
+
+// Set buffers storing value under key. The value is encoded by Write with
+// the codec of the client.
+func (b *WriteBatch) Set(key string, value interface{}) {
+	b.writes = append(b.writes, write{key: key, value: value})
+}
+
+// Delete buffers removing key. Deleting a missing key is not an error.
+func (b *WriteBatch) Delete(key string) {
+	b.writes = append(b.writes, write{key: key, deleted: true})
+}
+
+// Len returns the number of buffered writes
+func (b *WriteBatch) Len() int {
+	return len(b.writes)
+}
+
+// Write applies every write of the batch in order with the shards of its
+// keys locked, so readers see either none or all of them. If a value
+// cannot be encoded nothing is written.
+func (c *Client) Write(ctx context.Context, b *WriteBatch) error {
+	records := make([][]byte, len(b.writes))
+	for i, w := range b.writes {
+		if w.deleted {
+			continue
+		}
+		data, err := c.encode(w.key, w.value)
+		if err != nil {
+			return fmt.Errorf("key %q: %w", w.key, err)
+		}
+		records[i] = data
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The last write of each key is the one that counts against the quota
+	writes := make(map[string][]byte, len(b.writes))
+	keys := make([]string, len(b.writes))
+	for i, w := range b.writes {
+		writes[w.key] = records[i]
+		keys[i] = w.key
+	}
+
+	unlock := c.lockKeys(keys)
+	victims, err := c.reserve(writes)
+	if err == nil {
+		for i, w := range b.writes {
+			if w.deleted {
+				c.remove(c.shardOf(w.key), w.key)
+				continue
+			}
+			c.put(c.shardOf(w.key), w.key, records[i])
+		}
+	}
+	unlock()
+
+	c.evict(victims)
+	return err
+}
@@ -0,0 +1,55 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClient_Write(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	// Action
+	var batch WriteBatch
+	batch.Set("b", 2)
+	batch.Delete("a")
+	batch.Delete("missing")
+	if err := client.Write(ctx, &batch); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Assertions
+	var value int
+	if err := client.Get(ctx, "a", &value); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v for deleted key, got %v", ErrNotFound, err)
+	}
+	if err := client.Get(ctx, "b", &value); err != nil || value != 2 {
+		t.Errorf("Expected 2, got %d (%v)", value, err)
+	}
+}
+
+func TestClient_WriteCanceled(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Action
+	var batch WriteBatch
+	batch.Set("a", 1)
+	err := client.Write(ctx, &batch)
+
+	// Assertions
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	var value int
+	if err := client.Get(context.Background(), "a", &value); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected nothing written, got %v", err)
+	}
+}
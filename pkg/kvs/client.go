@@ -2,48 +2,202 @@ package kvs
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"sync"
 )
 
+// ErrNotFound is returned when a key does not exist
+var ErrNotFound = errors.New("key not found")
+
 // Client is a simple in-memory key-value store implementation
 // In a real project, this would be replaced with an actual KVS client
 type Client struct {
-	store map[string][]byte
-	mu    sync.RWMutex
+	// shards hold the keys, spread by hash
+	shards     []*shard
+	shardCount int
+
+	// feed counts the writes of every shard, a key's version being the
+	// revision of its last write, and delivers them to watchers
+	feed feed
+
+	// codec writes new records, codecs reads them by tag
+	codec  Codec
+	codecs map[byte]Codec
+
+	// compressor compresses new records of at least compressionThreshold
+	// bytes, compressors reads them by tag
+	compressor           Compressor
+	compressionThreshold int
+	compressors          map[byte]Compressor
+
+	// keyring encrypts new records and decrypts them by key ID
+	keyring *Keyring
+
+	// quota limits the size of the store, nil when unlimited
+	quota *quotaState
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithCodec sets the codec used to write values, JSON by default. Records
+// written with any built-in codec, or with this one, remain readable.
+func WithCodec(codec Codec) Option {
+	return func(c *Client) {
+		c.codec = codec
+		c.codecs[codec.Tag()] = codec
+	}
 }
 
 // NewClient creates a new instance of the KVS client
-func NewClient() *Client {
-	return &Client{
-		store: make(map[string][]byte),
+func NewClient(opts ...Option) *Client {
+	c := &Client{
+		shardCount:  DefaultShards,
+		codec:       JSON,
+		codecs:      make(map[byte]Codec),
+		compressors: make(map[byte]Compressor),
+	}
+	c.feed.history = make([]Event, DefaultHistory)
+	for _, codec := range Codecs() {
+		c.codecs[codec.Tag()] = codec
 	}
+	for _, compressor := range Compressors() {
+		c.compressors[compressor.Tag()] = compressor
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.shards = make([]*shard, c.shardCount)
+	for i := range c.shards {
+		c.shards[i] = &shard{
+			store:    make(map[string][]byte),
+			versions: make(map[string]uint64),
+		}
+	}
+	return c
 }
 
 // Set stores a value with the given key
 func (c *Client) Set(ctx context.Context, key string, value interface{}) error {
-	data, err := json.Marshal(value)
+	data, err := c.encode(key, value)
 	if err != nil {
-		return fmt.Errorf("error marshaling value: %w", err)
+		return err
 	}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
-	c.store[key] = data
-	return nil
+	s := c.shardOf(key)
+	s.mu.Lock()
+	victims, err := c.reserve(map[string][]byte{key: data})
+	if err == nil {
+		c.put(s, key, data)
+	}
+	s.mu.Unlock()
+
+	c.evict(victims)
+	return err
 }
 
 // Get retrieves a value by its key
 func (c *Client) Get(ctx context.Context, key string, target interface{}) error {
-	c.mu.RLock()
-	data, ok := c.store[key]
-	c.mu.RUnlock()
+	s := c.shardOf(key)
+	s.mu.RLock()
+	data, ok := s.store[key]
+	if ok && c.quota != nil {
+		c.quota.touch(key)
+	}
+	s.mu.RUnlock()
 
 	if !ok {
-		return errors.New("key not found")
+		return ErrNotFound
 	}
 
-	return json.Unmarshal(data, target)
+	return c.decode(key, data, target)
+}
+
+// Delete removes a key, returning ErrNotFound if it does not exist
+func (c *Client) Delete(ctx context.Context, key string) error {
+	s := c.shardOf(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.store[key]; !ok {
+		return ErrNotFound
+	}
+	// Deletes never exceed the quota
+	_, _ = c.reserve(map[string][]byte{key: nil})
+	c.remove(s, key)
+	return nil
+}
+
+// Exists reports whether a key exists without unmarshaling its value
+func (c *Client) Exists(ctx context.Context, key string) (bool, error) {
+	s := c.shardOf(key)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.store[key]
+	return ok, nil
+}
+
+// MGet retrieves several keys in one call, unmarshaling the value of
+// keys[i] into targets[i]. The returned slice holds the error of each key,
+// ErrNotFound for missing ones, so that a missing key does not fail the
+// others; the error is for failures of the whole call.
+func (c *Client) MGet(ctx context.Context, keys []string, targets []interface{}) ([]error, error) {
+	if len(keys) != len(targets) {
+		return nil, fmt.Errorf("got %d keys and %d targets", len(keys), len(targets))
+	}
+
+	values := make([][]byte, len(keys))
+	unlock := c.rlockKeys(keys)
+	for i, key := range keys {
+		values[i] = c.shardOf(key).store[key]
+		if values[i] != nil && c.quota != nil {
+			c.quota.touch(key)
+		}
+	}
+	unlock()
+
+	errs := make([]error, len(keys))
+	for i, data := range values {
+		if data == nil {
+			errs[i] = ErrNotFound
+			continue
+		}
+		errs[i] = c.decode(keys[i], data, targets[i])
+	}
+	return errs, nil
+}
+
+// MSet stores several values in one call. Either every value is stored or,
+// if one cannot be marshaled, none is.
+func (c *Client) MSet(ctx context.Context, values map[string]interface{}) error {
+	var batch WriteBatch
+	for key, value := range values {
+		batch.Set(key, value)
+	}
+	return c.Write(ctx, &batch)
+}
+
+// put stores data under key in s, the caller must hold the write lock of
+// s and have reserved the write in the quota
+func (c *Client) put(s *shard, key string, data []byte) {
+	var old uint64
+	previous, ok := s.store[key]
+	if ok {
+		old = s.versions[key]
+	}
+	s.store[key] = data
+	s.versions[key] = c.publish(OpPut, key, old, previous, data)
+}
+
+// remove deletes key from s, the caller must hold the write lock of s and
+// have reserved the delete in the quota
+func (c *Client) remove(s *shard, key string) {
+	previous, ok := s.store[key]
+	if !ok {
+		return
+	}
+	delete(s.store, key)
+	c.publish(OpDelete, key, s.versions[key], previous, nil)
+	delete(s.versions, key)
 }
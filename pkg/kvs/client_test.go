@@ -0,0 +1,109 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestClient_Delete(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	// Action
+	err := client.Delete(ctx, "a")
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if ok, _ := client.Exists(ctx, "a"); ok {
+		t.Errorf("Expected deleted key to be missing")
+	}
+	if err := client.Delete(ctx, "a"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v deleting a missing key, got %v", ErrNotFound, err)
+	}
+}
+
+func TestClient_Exists(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		key      string
+		expected bool
+	}{
+		{name: "ExistingKey", key: "a", expected: true},
+		{name: "MissingKey", key: "b", expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := client.Exists(ctx, tt.key)
+
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if ok != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, ok)
+			}
+		})
+	}
+}
+
+func TestClient_MSetMGet(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+
+	// Action
+	if err := client.MSet(ctx, map[string]interface{}{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var a, missing int
+	var b string
+	errs, err := client.MGet(ctx, []string{"a", "missing", "b"}, []interface{}{&a, &missing, &b})
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if errs[0] != nil || a != 1 {
+		t.Errorf("Expected 1, got %d (%v)", a, errs[0])
+	}
+	if !errors.Is(errs[1], ErrNotFound) {
+		t.Errorf("Expected %v, got %v", ErrNotFound, errs[1])
+	}
+	if errs[2] == nil {
+		t.Errorf("Expected an unmarshal error for a mismatched target, got nil")
+	}
+	if _, err := client.MGet(ctx, []string{"a"}, nil); err == nil {
+		t.Errorf("Expected an error for mismatched keys and targets, got nil")
+	}
+}
+
+func TestClient_MSetAllOrNothing(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+
+	// Action
+	err := client.MSet(ctx, map[string]interface{}{"a": 1, "b": make(chan int)})
+
+	// Assertions
+	if err == nil {
+		t.Fatalf("Expected an error for a value that cannot be marshaled, got nil")
+	}
+	if ok, _ := client.Exists(ctx, "a"); ok {
+		t.Errorf("Expected nothing written")
+	}
+}
@@ -0,0 +1,171 @@
+package kvs
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes values to bytes and back. Every stored record starts with
+// the tag of the codec that wrote it, so a store can hold records of
+// different codecs, e.g. while migrating from one to another.
+type Codec interface {
+	// Name identifies the codec in configuration, e.g. "json"
+	Name() string
+	// Tag is the first byte of the records written with the codec
+	Tag() byte
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Codec tags. Values below 0x09 never start a JSON text, so records
+// written before tags existed are still read as JSON.
+const (
+	TagJSON    byte = 0x01
+	TagGob     byte = 0x02
+	TagMsgpack byte = 0x03
+	TagCBOR    byte = 0x04
+)
+
+var (
+	// JSON is the default codec
+	JSON Codec = jsonCodec{}
+	// Gob encodes values with encoding/gob
+	Gob Codec = gobCodec{}
+	// Msgpack encodes values as MessagePack
+	Msgpack Codec = msgpackCodec{}
+	// CBOR encodes values as CBOR (RFC 8949)
+	CBOR Codec = cborCodec{}
+)
+
+// Codecs returns the built-in codecs
+func Codecs() []Codec {
+	return []Codec{JSON, Gob, Msgpack, CBOR}
+}
+
+// CodecByName returns the built-in codec with the given name
+func CodecByName(name string) (Codec, error) {
+	for _, c := range Codecs() {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown codec %q", name)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return "json" }
+func (jsonCodec) Tag() byte    { return TagJSON }
+
+func (jsonCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string { return "gob" }
+func (gobCodec) Tag() byte    { return TagGob }
+
+func (gobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string { return "msgpack" }
+func (msgpackCodec) Tag() byte    { return TagMsgpack }
+
+func (msgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	// Use the json tags, so that records match the JSON field names
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+type cborCodec struct{}
+
+// cborEncMode keeps the nanoseconds of times, which the default mode
+// truncates to seconds
+var cborEncMode = func() cbor.EncMode {
+	mode, err := cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return mode
+}()
+
+func (cborCodec) Name() string { return "cbor" }
+func (cborCodec) Tag() byte    { return TagCBOR }
+
+func (cborCodec) Marshal(v interface{}) ([]byte, error) {
+	return cborEncMode.Marshal(v)
+}
+
+func (cborCodec) Unmarshal(data []byte, v interface{}) error {
+	return cbor.Unmarshal(data, v)
+}
+
+// encode writes v as the record of key, tagged with the codec of the
+// client, then compressed and encrypted when enabled
+func (c *Client) encode(key string, v interface{}) ([]byte, error) {
+	data, err := c.codec.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling value: %w", err)
+	}
+	record := make([]byte, 0, len(data)+1)
+	record = append(record, c.codec.Tag())
+	record, err = c.compress(append(record, data...))
+	if err != nil {
+		return nil, err
+	}
+	return c.encrypt(key, record)
+}
+
+// decode reads the record of key with the codec named by its tag,
+// decrypting and decompressing it first if needed. Untagged records were
+// written as JSON before tags existed.
+func (c *Client) decode(key string, record []byte, v interface{}) error {
+	record, err := c.decrypt(key, record)
+	if err != nil {
+		return err
+	}
+	record, err = c.decompress(record)
+	if err != nil {
+		return err
+	}
+	if len(record) == 0 || record[0] >= 0x09 {
+		return json.Unmarshal(record, v)
+	}
+	codec, ok := c.codecs[record[0]]
+	if !ok {
+		return fmt.Errorf("unknown codec tag 0x%02x", record[0])
+	}
+	return codec.Unmarshal(record[1:], v)
+}
@@ -0,0 +1,219 @@
+package kvs
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressor compresses records. Compressed records start with the tag of
+// the compressor and the uncompressed length, so records written without
+// compression, or with another compressor, remain readable.
+type Compressor interface {
+	// Name identifies the compressor in configuration, e.g. "gzip"
+	Name() string
+	// Tag is the first byte of the records compressed with it
+	Tag() byte
+	Compress(data []byte) ([]byte, error)
+	Decompress(data []byte, size int) ([]byte, error)
+}
+
+// Compression tags, distinct from the codec tags and from the first byte
+// of a JSON text
+const (
+	TagGzip byte = 0x05
+	TagZstd byte = 0x06
+)
+
+// DefaultCompressionThreshold is the record size from which values are
+// compressed unless configured otherwise
+const DefaultCompressionThreshold = 1024
+
+// maxRecordSize bounds the uncompressed size read from the header of a
+// compressed record, so that a corrupt record cannot exhaust the memory
+const maxRecordSize = 1 << 28
+
+var (
+	// Gzip compresses records with compress/gzip
+	Gzip Compressor = gzipCompressor{}
+	// Zstd compresses records with Zstandard
+	Zstd Compressor = zstdCompressor{}
+)
+
+// Compressors returns the built-in compressors
+func Compressors() []Compressor {
+	return []Compressor{Gzip, Zstd}
+}
+
+// CompressorByName returns the built-in compressor with the given name, or
+// nil for "none"
+func CompressorByName(name string) (Compressor, error) {
+	if name == "none" {
+		return nil, nil
+	}
+	for _, c := range Compressors() {
+		if c.Name() == name {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown compressor %q", name)
+}
+
+// WithCompression compresses the records of at least threshold bytes, when
+// that makes them smaller
+func WithCompression(compressor Compressor, threshold int) Option {
+	return func(c *Client) {
+		c.compressor = compressor
+		c.compressionThreshold = threshold
+		c.compressors[compressor.Tag()] = compressor
+	}
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Name() string { return "gzip" }
+func (gzipCompressor) Tag() byte    { return TagGzip }
+
+func (gzipCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(data []byte, size int) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	out := bytes.NewBuffer(make([]byte, 0, capacity(size, data)))
+	// One more byte than announced to detect longer contents
+	if _, err := io.Copy(out, io.LimitReader(r, int64(size)+1)); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type zstdCompressor struct{}
+
+// The zstd encoder and decoder are safe for concurrent EncodeAll and
+// DecodeAll calls and costly to create, so they are shared
+var (
+	zstdEncoder = sync.OnceValue(func() *zstd.Encoder {
+		enc, err := zstd.NewWriter(nil)
+		if err != nil {
+			panic(err)
+		}
+		return enc
+	})
+	zstdDecoder = sync.OnceValue(func() *zstd.Decoder {
+		dec, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(maxRecordSize))
+		if err != nil {
+			panic(err)
+		}
+		return dec
+	})
+)
+
+func (zstdCompressor) Name() string { return "zstd" }
+func (zstdCompressor) Tag() byte    { return TagZstd }
+
+func (zstdCompressor) Compress(data []byte) ([]byte, error) {
+	return zstdEncoder().EncodeAll(data, nil), nil
+}
+
+func (zstdCompressor) Decompress(data []byte, size int) ([]byte, error) {
+	return zstdDecoder().DecodeAll(data, make([]byte, 0, capacity(size, data)))
+}
+
+// capacity returns the capacity of the buffer holding the size bytes
+// decompressed from data, not trusting a size much larger than data
+func capacity(size int, data []byte) int {
+	return min(size, 64*len(data)+512)
+}
+
+// compress returns the compressed form of record if compression is enabled,
+// the record is large enough and compressing makes it smaller
+func (c *Client) compress(record []byte) ([]byte, error) {
+	if c.compressor == nil || len(record) < c.compressionThreshold {
+		return record, nil
+	}
+
+	data, err := c.compressor.Compress(record)
+	if err != nil {
+		return nil, fmt.Errorf("error compressing value: %w", err)
+	}
+	compressed := make([]byte, 0, 1+binary.MaxVarintLen64+len(data))
+	compressed = append(compressed, c.compressor.Tag())
+	compressed = binary.AppendUvarint(compressed, uint64(len(record)))
+	compressed = append(compressed, data...)
+	if len(compressed) >= len(record) {
+		return record, nil
+	}
+	return compressed, nil
+}
+
+// decompress returns the uncompressed form of a record
+func (c *Client) decompress(record []byte) ([]byte, error) {
+	if len(record) == 0 {
+		return record, nil
+	}
+	compressor, ok := c.compressors[record[0]]
+	if !ok {
+		return record, nil
+	}
+
+	size, n, err := header(compressor, record)
+	if err != nil {
+		return nil, err
+	}
+	data, err := compressor.Decompress(record[n:], size)
+	if err != nil {
+		return nil, fmt.Errorf("error decompressing value: %w", err)
+	}
+	if len(data) != size {
+		return nil, fmt.Errorf("invalid %s record: size does not match the header", compressor.Name())
+	}
+	return data, nil
+}
+
+// header returns the uncompressed size announced by a record compressed
+// with compressor, and the length of its header
+func header(compressor Compressor, record []byte) (int, int, error) {
+	size, n := binary.Uvarint(record[1:])
+	if n <= 0 {
+		return 0, 0, fmt.Errorf("invalid %s record header", compressor.Name())
+	}
+	if size == 0 || size > maxRecordSize {
+		return 0, 0, fmt.Errorf("invalid %s record header: size %d out of range", compressor.Name(), size)
+	}
+	return int(size), 1 + n, nil
+}
+
+// rawSize returns the uncompressed size of a record without decompressing it
+func (c *Client) rawSize(record []byte) (int, bool) {
+	if len(record) == 0 {
+		return 0, false
+	}
+	compressor, ok := c.compressors[record[0]]
+	if !ok {
+		return len(record), false
+	}
+	size, _, err := header(compressor, record)
+	if err != nil {
+		return len(record), false
+	}
+	return size, true
+}
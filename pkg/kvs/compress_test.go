@@ -0,0 +1,264 @@
+package kvs_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"fruitsapi/pkg/kvs"
+)
+
+type document struct {
+	Body string `json:"body"`
+}
+
+func TestCompression_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		wantCompressed int
+	}{
+		{name: "Large value", body: strings.Repeat("manzana ", 500), wantCompressed: 1},
+		{name: "Value below the threshold", body: "manzana", wantCompressed: 0},
+	}
+
+	for _, compressor := range kvs.Compressors() {
+		for _, tt := range tests {
+			t.Run(compressor.Name()+"/"+tt.name, func(t *testing.T) {
+				// Setup
+				client := kvs.NewClient(kvs.WithCompression(compressor, 1024))
+				ctx := context.Background()
+
+				// Action
+				if err := client.Set(ctx, "doc", document{Body: tt.body}); err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				var got document
+				err := client.Get(ctx, "doc", &got)
+
+				// Assertions
+				if err != nil {
+					t.Fatalf("Expected no error, got %v", err)
+				}
+				if got.Body != tt.body {
+					t.Errorf("Expected body of %d bytes, got %d bytes", len(tt.body), len(got.Body))
+				}
+				if stats := client.Stats(); stats.CompressedKeys != tt.wantCompressed {
+					t.Errorf("Expected %d compressed keys, got %d", tt.wantCompressed, stats.CompressedKeys)
+				}
+			})
+		}
+	}
+}
+
+func TestCompression_IncompressibleValue(t *testing.T) {
+	// Setup: random bytes only grow when compressed
+	data := make([]byte, 4096)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("Failed to generate data: %v", err)
+	}
+	client := kvs.NewClient(kvs.WithCodec(kvs.Gob), kvs.WithCompression(kvs.Gzip, 0))
+
+	// Action
+	if err := client.Set(context.Background(), "random", data); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var got []byte
+	err := client.Get(context.Background(), "random", &got)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Expected the stored bytes back")
+	}
+	if stats := client.Stats(); stats.CompressedKeys != 0 || stats.CompressionRatio != 1 {
+		t.Errorf("Expected the value to be stored uncompressed, got %+v", stats)
+	}
+}
+
+func TestCompression_MixedRecords(t *testing.T) {
+	// Setup: records written by a client without compression, then read by
+	// clients compressing with every compressor
+	body := strings.Repeat("pera ", 1000)
+	plain := kvs.NewClient()
+	ctx := context.Background()
+	if err := plain.Set(ctx, "plain", document{Body: body}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	zstd := kvs.NewClient(kvs.WithCompression(kvs.Zstd, 0))
+	if err := zstd.Set(ctx, "zstd", document{Body: body}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	var store struct {
+		Keys map[string]json.RawMessage `json:"keys"`
+	}
+	for _, c := range []*kvs.Client{plain, zstd} {
+		var buf bytes.Buffer
+		if err := c.WriteSnapshot(&buf); err != nil {
+			t.Fatalf("Failed to write snapshot: %v", err)
+		}
+		if err := json.Unmarshal(buf.Bytes(), &store); err != nil {
+			t.Fatalf("Failed to read snapshot: %v", err)
+		}
+	}
+	snapshot, err := json.Marshal(store)
+	if err != nil {
+		t.Fatalf("Failed to marshal snapshot: %v", err)
+	}
+
+	for _, compressor := range kvs.Compressors() {
+		t.Run(compressor.Name(), func(t *testing.T) {
+			client := kvs.NewClient(kvs.WithCompression(compressor, 0))
+			if err := client.ReadSnapshot(bytes.NewReader(snapshot)); err != nil {
+				t.Fatalf("Failed to read snapshot: %v", err)
+			}
+
+			// Assertions: every client reads every record, whatever wrote it
+			for _, key := range []string{"plain", "zstd"} {
+				var got document
+				if err := client.Get(ctx, key, &got); err != nil {
+					t.Errorf("Failed to read %s record: %v", key, err)
+					continue
+				}
+				if got.Body != body {
+					t.Errorf("Expected the %s record body back", key)
+				}
+			}
+		})
+	}
+}
+
+func TestCompression_CorruptHeader(t *testing.T) {
+	for _, compressor := range kvs.Compressors() {
+		data, err := compressor.Compress([]byte(`{"body":"manzana"}`))
+		if err != nil {
+			t.Fatalf("Failed to compress: %v", err)
+		}
+		tests := []struct {
+			name string
+			size uint64
+		}{
+			{name: "Zero size", size: 0},
+			{name: "Huge size", size: 1 << 62},
+			{name: "Overflowing size", size: 1 << 63},
+			{name: "Smaller size", size: 4},
+			{name: "Larger size", size: 1 << 20},
+		}
+
+		for _, tt := range tests {
+			t.Run(compressor.Name()+"/"+tt.name, func(t *testing.T) {
+				// Setup
+				record := binary.AppendUvarint([]byte{compressor.Tag()}, tt.size)
+				snapshot, err := json.Marshal(map[string][]byte{"doc": append(record, data...)})
+				if err != nil {
+					t.Fatalf("Failed to marshal snapshot: %v", err)
+				}
+				client := kvs.NewClient(kvs.WithCompression(compressor, 0))
+				if err := client.ReadSnapshot(bytes.NewReader(snapshot)); err != nil {
+					t.Fatalf("Failed to read snapshot: %v", err)
+				}
+
+				// Action: neither panics
+				client.Stats()
+				var got document
+				err = client.Get(context.Background(), "doc", &got)
+
+				// Assertions
+				if err == nil {
+					t.Errorf("Expected an error, got nil")
+				}
+			})
+		}
+	}
+}
+
+func TestCompressorByName(t *testing.T) {
+	for _, compressor := range kvs.Compressors() {
+		got, err := kvs.CompressorByName(compressor.Name())
+		if err != nil || got != compressor {
+			t.Errorf("Expected compressor %s, got %v (%v)", compressor.Name(), got, err)
+		}
+	}
+	if got, err := kvs.CompressorByName("none"); got != nil || err != nil {
+		t.Errorf("Expected no compressor for none, got %v (%v)", got, err)
+	}
+	if _, err := kvs.CompressorByName("lz4"); err == nil {
+		t.Errorf("Expected an error for an unknown compressor, got nil")
+	}
+}
+
+func TestStats_CompressionRatio(t *testing.T) {
+	// Setup
+	client := kvs.NewClient(kvs.WithCompression(kvs.Gzip, 1024))
+	ctx := context.Background()
+	large := document{Body: strings.Repeat("naranja ", 1000)}
+	if err := client.Set(ctx, "large", large); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := client.Set(ctx, "small", document{Body: "naranja"}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// Action
+	stats := client.Stats()
+
+	// Assertions
+	if stats.Keys != 2 || stats.CompressedKeys != 1 {
+		t.Errorf("Expected 2 keys with 1 compressed, got %d with %d", stats.Keys, stats.CompressedKeys)
+	}
+	largeJSON, _ := json.Marshal(large)
+	smallJSON, _ := json.Marshal(document{Body: "naranja"})
+	// Each record carries a one byte codec tag
+	if want := int64(len(largeJSON) + len(smallJSON) + 2); stats.RawBytes != want {
+		t.Errorf("Expected %d raw bytes, got %d", want, stats.RawBytes)
+	}
+	if stats.StoredBytes >= stats.RawBytes {
+		t.Errorf("Expected fewer stored bytes than raw bytes, got %d and %d", stats.StoredBytes, stats.RawBytes)
+	}
+	if stats.CompressionRatio <= 1 {
+		t.Errorf("Expected a compression ratio above 1, got %f", stats.CompressionRatio)
+	}
+}
+
+func BenchmarkCompressors(b *testing.B) {
+	fruits := make([]interface{}, 20)
+	for i := range fruits {
+		fruits[i] = testFruit(i)
+	}
+	data, err := json.Marshal(fruits)
+	if err != nil {
+		b.Fatalf("Failed to marshal: %v", err)
+	}
+
+	for _, compressor := range kvs.Compressors() {
+		compressed, err := compressor.Compress(data)
+		if err != nil {
+			b.Fatalf("Failed to compress: %v", err)
+		}
+
+		b.Run(compressor.Name()+"/Compress", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(data))/float64(len(compressed)), "ratio")
+			for i := 0; i < b.N; i++ {
+				if _, err := compressor.Compress(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run(compressor.Name()+"/Decompress", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := compressor.Decompress(compressed, len(data)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
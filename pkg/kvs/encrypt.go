@@ -0,0 +1,212 @@
+package kvs
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TagAESGCM starts encrypted records, followed by the length and the ID of
+// the key, the nonce and the sealed record. It is distinct from the codec
+// and compression tags and from the first byte of a JSON text.
+const TagAESGCM byte = 0x07
+
+// reencryptBatchSize is the number of records re-encrypted under one lock
+const reencryptBatchSize = 100
+
+// WithEncryption encrypts new records with the primary key of keyring
+// using AES-GCM, binding each record to its key. Records encrypted with
+// any key of the keyring, or not encrypted, remain readable.
+func WithEncryption(keyring *Keyring) Option {
+	return func(c *Client) {
+		c.keyring = keyring
+	}
+}
+
+// encrypt seals the record of key with the primary key, if encryption is
+// enabled
+func (c *Client) encrypt(key string, record []byte) ([]byte, error) {
+	if c.keyring == nil {
+		return record, nil
+	}
+	aead, id, err := c.keyring.aead("")
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting value: %w", err)
+	}
+
+	sealed := make([]byte, 0, 2+len(id)+aead.NonceSize()+len(record)+aead.Overhead())
+	sealed = append(sealed, TagAESGCM, byte(len(id)))
+	sealed = append(sealed, id...)
+	nonce := sealed[len(sealed) : len(sealed)+aead.NonceSize()]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	sealed = sealed[:len(sealed)+len(nonce)]
+	return aead.Seal(sealed, nonce, record, []byte(key)), nil
+}
+
+// decrypt opens the record of key if it is encrypted
+func (c *Client) decrypt(key string, record []byte) ([]byte, error) {
+	id, ok, err := keyID(record)
+	if err != nil || !ok {
+		return record, err
+	}
+	if c.keyring == nil {
+		return nil, fmt.Errorf("%w %q: encryption is not enabled", ErrUnknownKey, id)
+	}
+	aead, _, err := c.keyring.aead(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data := record[2+len(id):]
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("invalid encrypted record")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting value with key %q: %w", id, err)
+	}
+	return plain, nil
+}
+
+// keyID returns the ID of the key a record is encrypted with, and false if
+// it is not encrypted
+func keyID(record []byte) (string, bool, error) {
+	if len(record) == 0 || record[0] != TagAESGCM {
+		return "", false, nil
+	}
+	if len(record) < 2 || len(record) < 2+int(record[1]) {
+		return "", false, errors.New("invalid encrypted record header")
+	}
+	return string(record[2 : 2+record[1]]), true, nil
+}
+
+// Reencrypt encrypts with the primary key every record encrypted with
+// another key, or not encrypted, e.g. after a key rotation. Records are
+// re-encrypted in small batches so that writers are not blocked for long;
+// a record written meanwhile is left as written. It returns the number of
+// re-encrypted records.
+func (c *Client) Reencrypt(ctx context.Context) (int, error) {
+	if c.keyring == nil {
+		return 0, errors.New("encryption is not enabled")
+	}
+	primary := c.keyring.Primary()
+
+	var stale []string
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for key, record := range s.store {
+			if id, _, _ := keyID(record); id != primary {
+				stale = append(stale, key)
+			}
+		}
+		s.mu.RUnlock()
+	}
+
+	n := 0
+	for start := 0; start < len(stale); start += reencryptBatchSize {
+		if err := ctx.Err(); err != nil {
+			return n, err
+		}
+		end := min(start+reencryptBatchSize, len(stale))
+		done, err := c.reencrypt(stale[start:end])
+		n += done
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// reencrypt re-encrypts the records of keys, skipping the ones that were
+// deleted or rewritten since they were listed
+func (c *Client) reencrypt(keys []string) (int, error) {
+	old := make(map[string][]byte, len(keys))
+	unlock := c.rlockKeys(keys)
+	for _, key := range keys {
+		if record, ok := c.shardOf(key).store[key]; ok {
+			old[key] = record
+		}
+	}
+	unlock()
+
+	records := make(map[string][]byte, len(old))
+	for key, record := range old {
+		plain, err := c.decrypt(key, record)
+		if err != nil {
+			return 0, fmt.Errorf("key %q: %w", key, err)
+		}
+		sealed, err := c.encrypt(key, plain)
+		if err != nil {
+			return 0, fmt.Errorf("key %q: %w", key, err)
+		}
+		records[key] = sealed
+	}
+
+	unlock = c.lockKeys(keys)
+	defer unlock()
+	n := 0
+	for key, record := range records {
+		s := c.shardOf(key)
+		if !bytes.Equal(s.store[key], old[key]) {
+			continue
+		}
+		// The value is unchanged, so the version is kept and
+		// transactions that read it do not conflict
+		s.store[key] = record
+		if c.quota != nil {
+			c.quota.resize(key, entrySize(key, record))
+		}
+		n++
+	}
+	return n, nil
+}
+
+// EncryptionReport describes how the records of the store are encrypted
+type EncryptionReport struct {
+	Records int `json:"records"`
+	// Plaintext is the number of records that are not encrypted
+	Plaintext int `json:"plaintext"`
+	// ByKey is the number of records encrypted with each key ID
+	ByKey map[string]int `json:"by_key"`
+	// Failed lists the keys of the records that cannot be decrypted
+	Failed []string `json:"failed"`
+}
+
+// VerifyEncryption decrypts every record of the store and reports which
+// keys they are encrypted with and which ones cannot be decrypted
+func (c *Client) VerifyEncryption(ctx context.Context) (EncryptionReport, error) {
+	store := make(map[string][]byte)
+	for _, s := range c.shards {
+		s.mu.RLock()
+		for key, record := range s.store {
+			store[key] = record
+		}
+		s.mu.RUnlock()
+	}
+
+	report := EncryptionReport{Records: len(store), ByKey: make(map[string]int)}
+	for key, record := range store {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		id, encrypted, err := keyID(record)
+		if err == nil && encrypted {
+			_, err = c.decrypt(key, record)
+		}
+		switch {
+		case err != nil:
+			report.Failed = append(report.Failed, key)
+		case encrypted:
+			report.ByKey[id]++
+		default:
+			report.Plaintext++
+		}
+	}
+	sort.Strings(report.Failed)
+	return report, nil
+}
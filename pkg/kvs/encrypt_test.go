@@ -0,0 +1,223 @@
+package kvs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type secret struct {
+	Owner string `json:"owner"`
+}
+
+// newTestKeyring returns a keyring with one key
+func newTestKeyring(t *testing.T) *Keyring {
+	t.Helper()
+	keyring := NewKeyring()
+	if _, err := keyring.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate keyring: %v", err)
+	}
+	return keyring
+}
+
+func TestEncryption_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{name: "JSON"},
+		{name: "CBOR", opts: []Option{WithCodec(CBOR)}},
+		{name: "Compressed", opts: []Option{WithCompression(Zstd, 0)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			keyring := newTestKeyring(t)
+			client := NewClient(append(tt.opts, WithEncryption(keyring))...)
+			ctx := context.Background()
+			want := secret{Owner: strings.Repeat("owner-42 ", 200)}
+
+			// Action
+			if err := client.Set(ctx, "fruit", want); err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			var got secret
+			err := client.Get(ctx, "fruit", &got)
+
+			// Assertions
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if got != want {
+				t.Errorf("Expected the stored value back")
+			}
+			record := client.shardOf("fruit").store["fruit"]
+			if id, ok, _ := keyID(record); !ok || id != keyring.Primary() {
+				t.Errorf("Expected a record encrypted with key %s, got %q", keyring.Primary(), id)
+			}
+			if bytes.Contains(record, []byte("owner-42")) {
+				t.Errorf("Expected the record not to contain the plaintext")
+			}
+		})
+	}
+}
+
+func TestEncryption_RecordBoundToKey(t *testing.T) {
+	// Setup: a record copied under another key
+	client := NewClient(WithEncryption(newTestKeyring(t)))
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", secret{Owner: "a"}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	client.shardOf("b").store["b"] = client.shardOf("a").store["a"]
+
+	// Action
+	var got secret
+	err := client.Get(ctx, "b", &got)
+
+	// Assertions
+	if err == nil {
+		t.Errorf("Expected an error, got nil")
+	}
+}
+
+func TestEncryption_Rotation(t *testing.T) {
+	// Setup: a plaintext record and one encrypted with the first key
+	keyring := newTestKeyring(t)
+	oldKey := keyring.Primary()
+	plain := NewClient()
+	ctx := context.Background()
+	if err := plain.Set(ctx, "plain", secret{Owner: "plain"}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	client := NewClient(WithEncryption(keyring))
+	client.shardOf("plain").store["plain"] = plain.shardOf("plain").store["plain"]
+	if err := client.Set(ctx, "old", secret{Owner: "old"}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	version := client.shardOf("old").versions["old"]
+
+	// Action
+	newKey, err := keyring.Rotate()
+	if err != nil {
+		t.Fatalf("Failed to rotate keyring: %v", err)
+	}
+	// Old keys stay readable before re-encryption
+	var got secret
+	if err := client.Get(ctx, "old", &got); err != nil || got.Owner != "old" {
+		t.Errorf("Expected the old record to be readable, got %+v (%v)", got, err)
+	}
+	n, err := client.Reencrypt(ctx)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n != 2 {
+		t.Errorf("Expected 2 re-encrypted records, got %d", n)
+	}
+	report, err := client.VerifyEncryption(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if report.ByKey[newKey] != 2 || report.ByKey[oldKey] != 0 || report.Plaintext != 0 || len(report.Failed) != 0 {
+		t.Errorf("Expected every record encrypted with %s, got %+v", newKey, report)
+	}
+	for _, key := range []string{"plain", "old"} {
+		var got secret
+		if err := client.Get(ctx, key, &got); err != nil || got.Owner != key {
+			t.Errorf("Expected %s record to be readable, got %+v (%v)", key, got, err)
+		}
+	}
+	if client.shardOf("old").versions["old"] != version {
+		t.Errorf("Expected re-encryption to keep version %d, got %d", version, client.shardOf("old").versions["old"])
+	}
+}
+
+func TestEncryption_UnknownKey(t *testing.T) {
+	// Setup: a record encrypted with a key missing from the reader's keyring
+	writer := NewClient(WithEncryption(newTestKeyring(t)))
+	ctx := context.Background()
+	if err := writer.Set(ctx, "fruit", secret{Owner: "owner"}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		client *Client
+	}{
+		{name: "Other keyring", client: NewClient(WithEncryption(newTestKeyring(t)))},
+		{name: "No keyring", client: NewClient()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.client.shardOf("fruit").store["fruit"] = writer.shardOf("fruit").store["fruit"]
+
+			// Action
+			var got secret
+			err := tt.client.Get(ctx, "fruit", &got)
+
+			// Assertions
+			if !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("Expected ErrUnknownKey, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyring_SaveAndReload(t *testing.T) {
+	// Setup
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	keyring := newTestKeyring(t)
+	if err := keyring.SaveFile(path); err != nil {
+		t.Fatalf("Failed to save keyring: %v", err)
+	}
+	loaded, err := LoadKeyringFile(path)
+	if err != nil {
+		t.Fatalf("Failed to load keyring: %v", err)
+	}
+
+	// Action: another process rotates the keyring file
+	if _, err := loaded.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate keyring: %v", err)
+	}
+	if err := loaded.SaveFile(path); err != nil {
+		t.Fatalf("Failed to save keyring: %v", err)
+	}
+	err = keyring.ReloadFile(path)
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if keyring.Primary() != loaded.Primary() {
+		t.Errorf("Expected primary key %s, got %s", loaded.Primary(), keyring.Primary())
+	}
+	if ids := keyring.IDs(); len(ids) != 2 {
+		t.Errorf("Expected 2 keys, got %v", ids)
+	}
+}
+
+func TestReadKeyring_Error(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{name: "Invalid JSON", data: `{`},
+		{name: "Missing primary key", data: `{"primary":"b","keys":{"a":"AAAAAAAAAAAAAAAAAAAAAA=="}}`},
+		{name: "Invalid key size", data: `{"primary":"a","keys":{"a":"AAAA"}}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadKeyring(strings.NewReader(tt.data)); err == nil {
+				t.Error("Expected error, got nil")
+			}
+		})
+	}
+}
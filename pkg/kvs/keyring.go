@@ -0,0 +1,214 @@
+package kvs
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownKey is returned when a record is encrypted with a key that is
+// not in the keyring
+var ErrUnknownKey = errors.New("unknown encryption key")
+
+// Keyring holds the AES keys used to encrypt records, by key ID. New
+// records are encrypted with the primary key; older keys stay in the
+// keyring so that records encrypted with them remain readable until they
+// are re-encrypted.
+type Keyring struct {
+	mu      sync.RWMutex
+	primary string
+	keys    map[string][]byte
+	aeads   map[string]cipher.AEAD
+}
+
+// keyringFile is the on-disk form of a keyring, keys being base64 encoded
+type keyringFile struct {
+	Primary string            `json:"primary"`
+	Keys    map[string][]byte `json:"keys"`
+}
+
+// NewKeyring creates an empty keyring
+func NewKeyring() *Keyring {
+	return &Keyring{
+		keys:  make(map[string][]byte),
+		aeads: make(map[string]cipher.AEAD),
+	}
+}
+
+// ReadKeyring reads a keyring written by Keyring.Write
+func ReadKeyring(r io.Reader) (*Keyring, error) {
+	var f keyringFile
+	if err := json.NewDecoder(r).Decode(&f); err != nil {
+		return nil, fmt.Errorf("error reading keyring: %w", err)
+	}
+	if _, ok := f.Keys[f.Primary]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", f.Primary)
+	}
+
+	k := NewKeyring()
+	for id, key := range f.Keys {
+		if err := k.add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	k.primary = f.Primary
+	return k, nil
+}
+
+// LoadKeyringFile reads the keyring at path
+func LoadKeyringFile(path string) (*Keyring, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening keyring file: %w", err)
+	}
+	defer f.Close()
+
+	return ReadKeyring(f)
+}
+
+// ReloadFile replaces the keys of the keyring with the ones of the keyring
+// at path, e.g. after a rotation by another process
+func (k *Keyring) ReloadFile(path string) error {
+	loaded, err := LoadKeyringFile(path)
+	if err != nil {
+		return err
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.primary, k.keys, k.aeads = loaded.primary, loaded.keys, loaded.aeads
+	return nil
+}
+
+// Write writes the keyring to w
+func (k *Keyring) Write(w io.Writer) error {
+	k.mu.RLock()
+	f := keyringFile{Primary: k.primary, Keys: k.keys}
+	data, err := json.MarshalIndent(f, "", "  ")
+	k.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("error marshaling keyring: %w", err)
+	}
+
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("error writing keyring: %w", err)
+	}
+	return nil
+}
+
+// SaveFile atomically writes the keyring to path, readable by its owner only
+func (k *Keyring) SaveFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating keyring file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := k.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing keyring file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing keyring file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error renaming keyring file: %w", err)
+	}
+	return nil
+}
+
+// Rotate adds a random AES-256 key to the keyring and makes it the primary
+// key, returning its ID
+func (k *Keyring) Rotate() (string, error) {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("error generating key: %w", err)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for {
+		b := make([]byte, 4)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("error generating key ID: %w", err)
+		}
+		id := hex.EncodeToString(b)
+		if _, ok := k.keys[id]; ok {
+			continue
+		}
+		if err := k.add(id, key); err != nil {
+			return "", err
+		}
+		k.primary = id
+		return id, nil
+	}
+}
+
+// Primary returns the ID of the key encrypting new records, empty when the
+// keyring has no key
+func (k *Keyring) Primary() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.primary
+}
+
+// IDs returns the sorted IDs of the keys of the keyring
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	ids := make([]string, 0, len(k.keys))
+	for id := range k.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// aead returns the cipher of the key with the given ID, or of the primary
+// key when id is empty, along with the ID
+func (k *Keyring) aead(id string) (cipher.AEAD, string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if id == "" {
+		id = k.primary
+	}
+	aead, ok := k.aeads[id]
+	if !ok {
+		return nil, "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	return aead, id, nil
+}
+
+// add adds a key to the keyring; the caller holds the write lock or owns
+// the keyring
+func (k *Keyring) add(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return fmt.Errorf("key ID %q must be 1 to 255 bytes long", id)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", id, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("key %q: %w", id, err)
+	}
+	k.keys[id] = key
+	k.aeads[id] = aead
+	return nil
+}
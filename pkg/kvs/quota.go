@@ -0,0 +1,333 @@
+package kvs
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EvictionPolicy decides what happens to a write that does not fit the
+// quota of the store
+type EvictionPolicy int
+
+const (
+	// NoEviction rejects the write with a QuotaError, so nothing is ever
+	// dropped. It is the policy to use when the store is the primary copy
+	// of the data.
+	NoEviction EvictionPolicy = iota
+	// LRU evicts the least recently used keys
+	LRU
+	// LFU evicts the least frequently used keys, the least recently used
+	// first among equally used ones
+	LFU
+)
+
+// String returns the name of the policy
+func (p EvictionPolicy) String() string {
+	switch p {
+	case NoEviction:
+		return "noeviction"
+	case LRU:
+		return "lru"
+	case LFU:
+		return "lfu"
+	default:
+		return fmt.Sprintf("EvictionPolicy(%d)", int(p))
+	}
+}
+
+// ErrQuotaExceeded matches every QuotaError
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// QuotaError is returned when a write does not fit the quota of the store
+// and the policy cannot, or must not, evict enough keys. Nothing is
+// written.
+type QuotaError struct {
+	Policy EvictionPolicy
+	// Keys and Bytes are what the store would hold after the write
+	Keys     int
+	Bytes    int64
+	MaxKeys  int
+	MaxBytes int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("quota exceeded (%s): the write needs %d keys and %d bytes, the limits are %d keys and %d bytes",
+		e.Policy, e.Keys, e.Bytes, e.MaxKeys, e.MaxBytes)
+}
+
+// Is reports whether target is ErrQuotaExceeded
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Quota limits the size of the store. A zero limit is unlimited.
+type Quota struct {
+	// MaxBytes limits the sum of the sizes of the keys and stored records
+	MaxBytes int64
+	MaxKeys  int
+	Policy   EvictionPolicy
+	// OnEvict, if set, is called with each evicted key and the size it
+	// freed, after the write that evicted it is applied and the store
+	// unlocked
+	OnEvict func(key string, size int64)
+}
+
+// WithQuota limits the size of the store
+func WithQuota(quota Quota) Option {
+	return func(c *Client) {
+		c.quota = &quotaState{
+			Quota:   quota,
+			entries: make(map[string]*quotaEntry),
+		}
+		c.quota.heap.policy = quota.Policy
+	}
+}
+
+// quotaState tracks the size and the use of every key. It mirrors the
+// shards, which it is updated with under their write locks, and has its own
+// mutex since reads touch keys under read locks only and writes to
+// different shards update it concurrently.
+type quotaState struct {
+	Quota
+
+	mu      sync.Mutex
+	entries map[string]*quotaEntry
+	heap    quotaHeap
+	clock   uint64
+
+	// bytes is the current size of the store, evictions and rejections
+	// count evicted keys and rejected writes
+	bytes      int64
+	evictions  uint64
+	rejections uint64
+}
+
+// quotaEntry is a key in the eviction heap
+type quotaEntry struct {
+	key      string
+	size     int64
+	hits     uint64
+	lastUsed uint64
+	index    int
+}
+
+// evicted is a key evicted by a write
+type evicted struct {
+	key  string
+	size int64
+}
+
+// entrySize returns the size of a key and its record in the quota
+func entrySize(key string, record []byte) int64 {
+	return int64(len(key) + len(record))
+}
+
+// exceeds reports whether a store of keys and bytes is over the quota
+func (q *quotaState) exceeds(keys int, bytes int64) bool {
+	return (q.MaxKeys > 0 && keys > q.MaxKeys) || (q.MaxBytes > 0 && bytes > q.MaxBytes)
+}
+
+// touch records a use of key
+func (q *quotaState) touch(key string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[key]; ok {
+		q.use(e)
+	}
+}
+
+// use records a use of e, the caller holds q.mu
+func (q *quotaState) use(e *quotaEntry) {
+	q.clock++
+	e.hits++
+	e.lastUsed = q.clock
+	if e.index >= 0 {
+		heap.Fix(&q.heap, e.index)
+	}
+}
+
+// set records that key is written with a record of size bytes, the caller
+// holds q.mu
+func (q *quotaState) set(key string, size int64) {
+	e, ok := q.entries[key]
+	if !ok {
+		e = &quotaEntry{key: key}
+		q.entries[key] = e
+		heap.Push(&q.heap, e)
+	}
+	q.bytes += size - e.size
+	e.size = size
+	q.use(e)
+}
+
+// delete records that key is removed, the caller holds q.mu
+func (q *quotaState) delete(key string) {
+	e, ok := q.entries[key]
+	if !ok {
+		return
+	}
+	if e.index >= 0 {
+		heap.Remove(&q.heap, e.index)
+	}
+	delete(q.entries, key)
+	q.bytes -= e.size
+}
+
+// resize records that the record of key changed size without being
+// written, e.g. when re-encrypted
+func (q *quotaState) resize(key string, size int64) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if e, ok := q.entries[key]; ok {
+		q.bytes += size - e.size
+		e.size = size
+	}
+}
+
+// reserve accounts for writes, nil records being deletes, making room by
+// choosing victims among the keys that are not written if the policy
+// allows it. Writes that do not grow the store always fit. The caller
+// holds the write locks of the written keys and applies the writes only
+// if reserve succeeds, then evicts the victims once the locks are
+// released.
+func (c *Client) reserve(writes map[string][]byte) ([]evicted, error) {
+	q := c.quota
+	if q == nil {
+		return nil, nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	keys, bytes := len(q.entries), q.bytes
+	for key, record := range writes {
+		if e, ok := q.entries[key]; ok {
+			keys--
+			bytes -= e.size
+		}
+		if record != nil {
+			keys++
+			bytes += entrySize(key, record)
+		}
+	}
+	grows := keys > len(q.entries) || bytes > q.bytes
+	if grows && q.exceeds(keys, bytes) {
+		reject := func() error {
+			q.rejections++
+			return &QuotaError{Policy: q.Policy, Keys: keys, Bytes: bytes, MaxKeys: q.MaxKeys, MaxBytes: q.MaxBytes}
+		}
+		if q.Policy == NoEviction {
+			return nil, reject()
+		}
+
+		// Pop victims until the writes fit, putting everything back if
+		// they cannot fit without evicting keys they write
+		var victims, skipped []*quotaEntry
+		for q.exceeds(keys, bytes) && q.heap.Len() > 0 {
+			e := heap.Pop(&q.heap).(*quotaEntry)
+			if _, ok := writes[e.key]; ok {
+				skipped = append(skipped, e)
+				continue
+			}
+			victims = append(victims, e)
+			keys--
+			bytes -= e.size
+		}
+		for _, e := range skipped {
+			heap.Push(&q.heap, e)
+		}
+		if q.exceeds(keys, bytes) {
+			for _, e := range victims {
+				heap.Push(&q.heap, e)
+			}
+			return nil, reject()
+		}
+
+		evictions := make([]evicted, len(victims))
+		for i, e := range victims {
+			evictions[i] = evicted{key: e.key, size: e.size}
+			q.delete(e.key)
+		}
+		q.evictions += uint64(len(victims))
+		q.apply(writes)
+		return evictions, nil
+	}
+
+	q.apply(writes)
+	return nil, nil
+}
+
+// apply records writes, the caller holds q.mu
+func (q *quotaState) apply(writes map[string][]byte) {
+	for key, record := range writes {
+		if record != nil {
+			q.set(key, entrySize(key, record))
+		} else {
+			q.delete(key)
+		}
+	}
+}
+
+// evict removes the victims of a write from their shards and reports them
+// to OnEvict; the caller must not hold any shard lock. A victim written
+// again since it was chosen is kept, the new write being accounted for.
+func (c *Client) evict(victims []evicted) {
+	if len(victims) == 0 {
+		return
+	}
+	for _, v := range victims {
+		s := c.shardOf(v.key)
+		s.mu.Lock()
+		c.quota.mu.Lock()
+		if _, back := c.quota.entries[v.key]; !back {
+			c.remove(s, v.key)
+		}
+		c.quota.mu.Unlock()
+		s.mu.Unlock()
+	}
+	if c.quota.OnEvict != nil {
+		for _, v := range victims {
+			c.quota.OnEvict(v.key, v.size)
+		}
+	}
+}
+
+// quotaHeap orders entries by eviction priority, the first to evict on top
+type quotaHeap struct {
+	policy  EvictionPolicy
+	entries []*quotaEntry
+}
+
+func (h *quotaHeap) Len() int { return len(h.entries) }
+
+func (h *quotaHeap) Less(i, j int) bool {
+	a, b := h.entries[i], h.entries[j]
+	if h.policy == LFU && a.hits != b.hits {
+		return a.hits < b.hits
+	}
+	return a.lastUsed < b.lastUsed
+}
+
+func (h *quotaHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.entries[i].index = i
+	h.entries[j].index = j
+}
+
+func (h *quotaHeap) Push(x any) {
+	e := x.(*quotaEntry)
+	e.index = len(h.entries)
+	h.entries = append(h.entries, e)
+}
+
+func (h *quotaHeap) Pop() any {
+	n := len(h.entries)
+	e := h.entries[n-1]
+	h.entries[n-1] = nil
+	h.entries = h.entries[:n-1]
+	e.index = -1
+	return e
+}
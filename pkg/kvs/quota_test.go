@@ -0,0 +1,225 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestQuota_NoEviction(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(c *Client) error
+	}{
+		{
+			name: "Set",
+			write: func(c *Client) error {
+				return c.Set(context.Background(), "c", "c")
+			},
+		},
+		{
+			name: "Batch",
+			write: func(c *Client) error {
+				var b WriteBatch
+				b.Set("a", "updated")
+				b.Set("c", "c")
+				return c.Write(context.Background(), &b)
+			},
+		},
+		{
+			name: "Transaction",
+			write: func(c *Client) error {
+				return c.Update(context.Background(), func(tx *Tx) error {
+					if err := tx.Set("a", "updated"); err != nil {
+						return err
+					}
+					return tx.Set("c", "c")
+				})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup: a full store
+			client := NewClient(WithQuota(Quota{MaxKeys: 2}))
+			ctx := context.Background()
+			if err := client.MSet(ctx, map[string]interface{}{"a": "a", "b": "b"}); err != nil {
+				t.Fatalf("Failed to set values: %v", err)
+			}
+
+			// Action
+			err := tt.write(client)
+
+			// Assertions
+			var quotaErr *QuotaError
+			if !errors.As(err, &quotaErr) || !errors.Is(err, ErrQuotaExceeded) {
+				t.Fatalf("Expected a QuotaError, got %v", err)
+			}
+			if quotaErr.Keys != 3 || quotaErr.MaxKeys != 2 {
+				t.Errorf("Expected 3 keys over a limit of 2, got %d over %d", quotaErr.Keys, quotaErr.MaxKeys)
+			}
+			var a string
+			if err := client.Get(ctx, "a", &a); err != nil || a != "a" {
+				t.Errorf("Expected a to be unchanged, got %q (%v)", a, err)
+			}
+			if ok, _ := client.Exists(ctx, "c"); ok {
+				t.Errorf("Expected c not to be written")
+			}
+			if stats := client.Stats(); stats.RejectedWrites != 1 || stats.Evictions != 0 {
+				t.Errorf("Expected 1 rejected write and no eviction, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestQuota_NoEvictionAllowsShrinkingWrites(t *testing.T) {
+	// Setup: a full store
+	client := NewClient(WithQuota(Quota{MaxKeys: 1}))
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", "a"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// Action: overwrite a key, and replace one key by another
+	errSet := client.Set(ctx, "a", "updated")
+	errTx := client.Update(ctx, func(tx *Tx) error {
+		tx.Delete("a")
+		return tx.Set("b", "b")
+	})
+
+	// Assertions
+	if errSet != nil || errTx != nil {
+		t.Errorf("Expected no error, got %v and %v", errSet, errTx)
+	}
+}
+
+func TestQuota_Eviction(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      EvictionPolicy
+		wantEvicted []string
+	}{
+		// a was read last, b is the least recently used
+		{name: "LRU", policy: LRU, wantEvicted: []string{"b"}},
+		// b and c were read twice, a is the least frequently used
+		{name: "LFU", policy: LFU, wantEvicted: []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			var evicted []string
+			client := NewClient(WithQuota(Quota{
+				MaxKeys: 3,
+				Policy:  tt.policy,
+				OnEvict: func(key string, size int64) {
+					evicted = append(evicted, key)
+				},
+			}))
+			ctx := context.Background()
+			for _, key := range []string{"a", "b", "c"} {
+				if err := client.Set(ctx, key, key); err != nil {
+					t.Fatalf("Failed to set value: %v", err)
+				}
+			}
+			var v string
+			for _, key := range []string{"b", "b", "c", "c", "a"} {
+				if err := client.Get(ctx, key, &v); err != nil {
+					t.Fatalf("Failed to get value: %v", err)
+				}
+			}
+
+			// Action
+			err := client.Set(ctx, "d", "d")
+
+			// Assertions
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if len(evicted) != len(tt.wantEvicted) || evicted[0] != tt.wantEvicted[0] {
+				t.Errorf("Expected %v to be evicted, got %v", tt.wantEvicted, evicted)
+			}
+			if ok, _ := client.Exists(ctx, tt.wantEvicted[0]); ok {
+				t.Errorf("Expected %s to be removed", tt.wantEvicted[0])
+			}
+			if stats := client.Stats(); stats.Keys != 3 || stats.Evictions != 1 {
+				t.Errorf("Expected 3 keys and 1 eviction, got %+v", stats)
+			}
+		})
+	}
+}
+
+func TestQuota_EvictionByBytes(t *testing.T) {
+	// Setup: each key takes 5 bytes, 1 for the key, 3 for "x" in JSON and 1
+	// for the codec tag, for a budget of 2 keys
+	var evicted []string
+	client := NewClient(WithQuota(Quota{
+		MaxBytes: 12,
+		Policy:   LRU,
+		OnEvict:  func(key string, size int64) { evicted = append(evicted, key) },
+	}))
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c", "d"} {
+		if err := client.Set(ctx, key, "x"); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+
+	// Action: a value that only fits if both remaining keys are evicted
+	err := client.Set(ctx, "e", "xxxxxx")
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	sort.Strings(evicted)
+	if want := []string{"a", "b", "c", "d"}; strings.Join(evicted, ",") != strings.Join(want, ",") {
+		t.Errorf("Expected %v to be evicted, got %v", want, evicted)
+	}
+	if stats := client.Stats(); stats.Keys != 1 {
+		t.Errorf("Expected 1 key, got %d", stats.Keys)
+	}
+}
+
+func TestQuota_WriteLargerThanQuota(t *testing.T) {
+	// Setup
+	client := NewClient(WithQuota(Quota{MaxBytes: 10, Policy: LRU}))
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// Action
+	err := client.Set(ctx, "b", "a value that does not fit even in an empty store")
+
+	// Assertions
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+	}
+	if ok, _ := client.Exists(ctx, "a"); !ok {
+		t.Errorf("Expected a not to be evicted by a rejected write")
+	}
+}
+
+func TestQuota_DeleteFreesRoom(t *testing.T) {
+	// Setup: a full store
+	client := NewClient(WithQuota(Quota{MaxKeys: 1}))
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", "a"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// Action
+	if err := client.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Failed to delete value: %v", err)
+	}
+	err := client.Set(ctx, "b", "b")
+
+	// Assertions
+	if err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
@@ -0,0 +1,192 @@
+package kvs
+
+import (
+	"container/heap"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultShards is the number of shards of a client unless configured
+// otherwise
+const DefaultShards = 32
+
+// shard is a part of the store with its own lock, so that writes to keys
+// of different shards do not wait for each other
+type shard struct {
+	mu    sync.RWMutex
+	store map[string][]byte
+	// versions holds the revision of the last write of each stored key, so
+	// that optimistic transactions can detect conflicts. Deleted keys are
+	// dropped: revisions are never reused, so a key written again gets a
+	// version no transaction can have read.
+	versions map[string]uint64
+}
+
+// WithShards splits the store into n independently locked shards, keys
+// being spread by hash. One shard is a single lock for the whole store.
+func WithShards(n int) Option {
+	return func(c *Client) {
+		c.shardCount = max(n, 1)
+	}
+}
+
+// shardIndex returns the index of the shard holding key, hashing it with
+// 32-bit FNV-1a
+func (c *Client) shardIndex(key string) int {
+	h := uint32(2166136261)
+	for i := 0; i < len(key); i++ {
+		h ^= uint32(key[i])
+		h *= 16777619
+	}
+	return int(h % uint32(len(c.shards)))
+}
+
+// shardOf returns the shard holding key
+func (c *Client) shardOf(key string) *shard {
+	return c.shards[c.shardIndex(key)]
+}
+
+// shardsOf returns the shards holding keys, in index order
+func (c *Client) shardsOf(keys []string) []*shard {
+	if len(keys) == 1 {
+		return []*shard{c.shardOf(keys[0])}
+	}
+	held := make([]bool, len(c.shards))
+	for _, key := range keys {
+		held[c.shardIndex(key)] = true
+	}
+	var shards []*shard
+	for i, s := range c.shards {
+		if held[i] {
+			shards = append(shards, s)
+		}
+	}
+	return shards
+}
+
+// lockKeys write-locks the shards holding keys and returns the function
+// unlocking them. Shards are always locked in index order, so that
+// concurrent multi-key writes cannot deadlock.
+func (c *Client) lockKeys(keys []string) func() {
+	shards := c.shardsOf(keys)
+	for _, s := range shards {
+		s.mu.Lock()
+	}
+	return func() {
+		for _, s := range shards {
+			s.mu.Unlock()
+		}
+	}
+}
+
+// rlockKeys read-locks the shards holding keys, for a consistent view of
+// them, and returns the function unlocking them
+func (c *Client) rlockKeys(keys []string) func() {
+	shards := c.shardsOf(keys)
+	for _, s := range shards {
+		s.mu.RLock()
+	}
+	return func() {
+		for _, s := range shards {
+			s.mu.RUnlock()
+		}
+	}
+}
+
+// lockAll write-locks every shard in index order and returns the function
+// unlocking them
+func (c *Client) lockAll() func() {
+	for _, s := range c.shards {
+		s.mu.Lock()
+	}
+	return func() {
+		for _, s := range c.shards {
+			s.mu.Unlock()
+		}
+	}
+}
+
+// rlockAll read-locks every shard in index order, for a consistent view
+// of the whole store, and returns the function unlocking them
+func (c *Client) rlockAll() func() {
+	for _, s := range c.shards {
+		s.mu.RLock()
+	}
+	return func() {
+		for _, s := range c.shards {
+			s.mu.RUnlock()
+		}
+	}
+}
+
+// ScanEntry is a key found by Scan
+type ScanEntry struct {
+	Key    string
+	client *Client
+	record []byte
+}
+
+// Decode unmarshals the value of the entry into target
+func (e ScanEntry) Decode(target interface{}) error {
+	return e.client.decode(e.Key, e.record, target)
+}
+
+// Scan calls fn for every key starting with prefix, in ascending key
+// order, stopping at the first error of fn and returning it. The keys of
+// each shard are sorted under its read lock, then the shards are merged,
+// so that writers are blocked one shard at a time; the keys of a shard are
+// seen as of when it was read.
+func (c *Client) Scan(ctx context.Context, prefix string, fn func(e ScanEntry) error) error {
+	h := make(scanHeap, 0, len(c.shards))
+	for _, s := range c.shards {
+		var entries []ScanEntry
+		s.mu.RLock()
+		for key, record := range s.store {
+			if strings.HasPrefix(key, prefix) {
+				entries = append(entries, ScanEntry{Key: key, client: c, record: record})
+			}
+		}
+		s.mu.RUnlock()
+
+		if len(entries) > 0 {
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+			h = append(h, entries)
+		}
+	}
+
+	heap.Init(&h)
+	for h.Len() > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		entries := h[0]
+		if err := fn(entries[0]); err != nil {
+			return err
+		}
+		if len(entries) == 1 {
+			heap.Pop(&h)
+		} else {
+			h[0] = entries[1:]
+			heap.Fix(&h, 0)
+		}
+	}
+	return nil
+}
+
+// scanHeap merges the sorted entries of each shard, the shard with the
+// smallest next key on top
+type scanHeap [][]ScanEntry
+
+func (h scanHeap) Len() int           { return len(h) }
+func (h scanHeap) Less(i, j int) bool { return h[i][0].Key < h[j][0].Key }
+func (h scanHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *scanHeap) Push(x any)        { *h = append(*h, x.([]ScanEntry)) }
+
+func (h *scanHeap) Pop() any {
+	old := *h
+	entries := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entries
+}
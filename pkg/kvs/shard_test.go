@@ -0,0 +1,342 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClient_Scan(t *testing.T) {
+	// Setup: keys spread over every shard
+	client := NewClient(WithShards(8))
+	ctx := context.Background()
+	values := make(map[string]interface{})
+	for i := 0; i < 50; i++ {
+		values[fmt.Sprintf("fruit/%02d", i)] = i
+		values[fmt.Sprintf("owner/%02d", i)] = i
+	}
+	if err := client.MSet(ctx, values); err != nil {
+		t.Fatalf("Failed to set values: %v", err)
+	}
+
+	// Action
+	var keys []string
+	sum := 0
+	err := client.Scan(ctx, "fruit/", func(e ScanEntry) error {
+		var v int
+		if err := e.Decode(&v); err != nil {
+			return err
+		}
+		keys = append(keys, e.Key)
+		sum += v
+		return nil
+	})
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(keys) != 50 {
+		t.Fatalf("Expected 50 keys, got %d", len(keys))
+	}
+	for i, key := range keys {
+		if want := fmt.Sprintf("fruit/%02d", i); key != want {
+			t.Errorf("Expected key %s at position %d, got %s", want, i, key)
+		}
+	}
+	if sum != 49*50/2 {
+		t.Errorf("Expected a sum of %d, got %d", 49*50/2, sum)
+	}
+}
+
+func TestClient_ScanStops(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+	if err := client.MSet(ctx, map[string]interface{}{"a": 1, "b": 2, "c": 3}); err != nil {
+		t.Fatalf("Failed to set values: %v", err)
+	}
+	errStop := errors.New("stop")
+
+	// Action
+	var keys []string
+	err := client.Scan(ctx, "", func(e ScanEntry) error {
+		keys = append(keys, e.Key)
+		if e.Key == "b" {
+			return errStop
+		}
+		return nil
+	})
+
+	// Assertions
+	if !errors.Is(err, errStop) {
+		t.Errorf("Expected the error of fn, got %v", err)
+	}
+	if got := strings.Join(keys, ","); got != "a,b" {
+		t.Errorf("Expected a,b, got %s", got)
+	}
+}
+
+func TestClient_ShardedTransactions(t *testing.T) {
+	for _, optimistic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("optimistic=%v", optimistic), func(t *testing.T) {
+			// Setup: accounts spread over the shards, money moving between
+			// random pairs must neither deadlock nor be lost
+			client := NewClient(WithShards(4))
+			ctx := context.Background()
+			const accounts, workers, transfers = 16, 8, 100
+			values := make(map[string]interface{})
+			for i := 0; i < accounts; i++ {
+				values[fmt.Sprintf("account/%d", i)] = 100
+			}
+			if err := client.MSet(ctx, values); err != nil {
+				t.Fatalf("Failed to set values: %v", err)
+			}
+			var opts []TxOption
+			if optimistic {
+				opts = append(opts, Optimistic())
+			}
+
+			// Action
+			var wg sync.WaitGroup
+			for w := 0; w < workers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < transfers; i++ {
+						from := fmt.Sprintf("account/%d", (w+i)%accounts)
+						to := fmt.Sprintf("account/%d", (w*7+i*3+1)%accounts)
+						for {
+							err := client.Update(ctx, func(tx *Tx) error {
+								var a, b int
+								if err := tx.Get(from, &a); err != nil {
+									return err
+								}
+								if err := tx.Get(to, &b); err != nil {
+									return err
+								}
+								if from == to {
+									return nil
+								}
+								if err := tx.Set(from, a-1); err != nil {
+									return err
+								}
+								return tx.Set(to, b+1)
+							}, opts...)
+							if !errors.Is(err, ErrConflict) {
+								if err != nil {
+									t.Errorf("Expected no error, got %v", err)
+								}
+								break
+							}
+						}
+					}
+				}(w)
+			}
+			wg.Wait()
+
+			// Assertions
+			total := 0
+			err := client.Scan(ctx, "account/", func(e ScanEntry) error {
+				var v int
+				err := e.Decode(&v)
+				total += v
+				return err
+			})
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if total != accounts*100 {
+				t.Errorf("Expected a total of %d, got %d", accounts*100, total)
+			}
+		})
+	}
+}
+
+func TestClient_UpdateLocksTouchedShards(t *testing.T) {
+	// Setup: two keys of different shards
+	client := NewClient(WithShards(8))
+	ctx := context.Background()
+	a, b := "fruit/0", "fruit/1"
+	for i := 2; client.shardIndex(a) == client.shardIndex(b); i++ {
+		b = fmt.Sprintf("fruit/%d", i)
+	}
+	started, written := make(chan struct{}), make(chan error)
+
+	// Action: a write to the other shard while the transaction runs
+	err := client.Update(ctx, func(tx *Tx) error {
+		if err := tx.Set(a, 1); err != nil {
+			return err
+		}
+		go func() {
+			<-started
+			written <- client.Set(ctx, b, 2)
+		}()
+		close(started)
+		select {
+		case err := <-written:
+			return err
+		case <-time.After(time.Second):
+			return errors.New("write to another shard blocked")
+		}
+	})
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var v int
+	if err := client.Get(ctx, a, &v); err != nil || v != 1 {
+		t.Errorf("Expected 1, got %d (%v)", v, err)
+	}
+}
+
+func TestClient_DeletePrunesVersions(t *testing.T) {
+	// Setup
+	client := NewClient(WithShards(1))
+	ctx := context.Background()
+	if err := client.MSet(ctx, map[string]interface{}{"a": 1, "b": 2}); err != nil {
+		t.Fatalf("Failed to set values: %v", err)
+	}
+
+	// Action
+	if err := client.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Failed to delete key: %v", err)
+	}
+	err := client.Update(ctx, func(tx *Tx) error {
+		tx.Delete("b")
+		return nil
+	})
+
+	// Assertions: deleted keys leave no version behind
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n := len(client.shards[0].versions); n != 0 {
+		t.Errorf("Expected no versions, got %d", n)
+	}
+}
+
+func TestQuota_ConcurrentEviction(t *testing.T) {
+	// Setup
+	client := NewClient(WithQuota(Quota{MaxKeys: 20, Policy: LRU}))
+	ctx := context.Background()
+
+	// Action: writers of different shards evicting each other's keys
+	var wg sync.WaitGroup
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("%d/%d", w, i%40)
+				if err := client.Set(ctx, key, i); err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				var v int
+				_ = client.Get(ctx, key, &v)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Assertions: the store and the quota agree, within the limit
+	stats := client.Stats()
+	if stats.Keys > 20 {
+		t.Errorf("Expected at most 20 keys, got %d", stats.Keys)
+	}
+	if len(client.quota.entries) != stats.Keys {
+		t.Errorf("Expected the quota to track %d keys, got %d", stats.Keys, len(client.quota.entries))
+	}
+}
+
+// runGoroutines runs b.N calls of fn spread over n goroutines
+func runGoroutines(b *testing.B, n int, fn func(g, i int)) {
+	var wg sync.WaitGroup
+	per := b.N / n
+	b.ResetTimer()
+	for g := 0; g < n; g++ {
+		count := per
+		if g == 0 {
+			count += b.N % n
+		}
+		wg.Add(1)
+		go func(g, count int) {
+			defer wg.Done()
+			for i := 0; i < count; i++ {
+				fn(g, i)
+			}
+		}(g, count)
+	}
+	wg.Wait()
+}
+
+// BenchmarkClient_Parallel compares a single lock, the client before
+// sharding, with the default shards under 1, 8 and 64 goroutines. Gains
+// need as many CPUs as goroutines.
+func BenchmarkClient_Parallel(b *testing.B) {
+	const keys = 1024
+	names := make([]string, keys)
+	for i := range names {
+		names[i] = fmt.Sprintf("fruit/%d", i)
+	}
+
+	for _, shards := range []int{1, DefaultShards} {
+		for _, goroutines := range []int{1, 8, 64} {
+			name := fmt.Sprintf("shards=%d/goroutines=%d", shards, goroutines)
+			ctx := context.Background()
+
+			b.Run(name+"/Set", func(b *testing.B) {
+				client := NewClient(WithShards(shards))
+				runGoroutines(b, goroutines, func(g, i int) {
+					if err := client.Set(ctx, names[(g*31+i)%keys], i); err != nil {
+						b.Error(err)
+					}
+				})
+			})
+
+			b.Run(name+"/Update", func(b *testing.B) {
+				client := NewClient(WithShards(shards))
+				runGoroutines(b, goroutines, func(g, i int) {
+					key := names[(g*31+i)%keys]
+					err := client.Update(ctx, func(tx *Tx) error {
+						var v int
+						if err := tx.Get(key, &v); err != nil && !errors.Is(err, ErrNotFound) {
+							return err
+						}
+						return tx.Set(key, v+1)
+					})
+					if err != nil {
+						b.Error(err)
+					}
+				})
+			})
+
+			b.Run(name+"/Get90Set10", func(b *testing.B) {
+				client := NewClient(WithShards(shards))
+				for i, key := range names {
+					if err := client.Set(ctx, key, i); err != nil {
+						b.Fatal(err)
+					}
+				}
+				runGoroutines(b, goroutines, func(g, i int) {
+					key := names[(g*31+i)%keys]
+					if i%10 == 0 {
+						if err := client.Set(ctx, key, i); err != nil {
+							b.Error(err)
+						}
+						return
+					}
+					var v int
+					if err := client.Get(ctx, key, &v); err != nil {
+						b.Error(err)
+					}
+				})
+			})
+		}
+	}
+}
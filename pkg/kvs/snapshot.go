@@ -0,0 +1,140 @@
+package kvs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// snapshot is the content of a snapshot. Snapshots written before the
+// revision was kept hold the keys alone.
+type snapshot struct {
+	// Revision is the revision of the store when the snapshot was written
+	Revision uint64            `json:"revision"`
+	Keys     map[string][]byte `json:"keys"`
+}
+
+// WriteSnapshot writes every stored key and its raw value to w, along with
+// the current revision
+func (c *Client) WriteSnapshot(w io.Writer) error {
+	unlock := c.rlockAll()
+	defer unlock()
+
+	snap := snapshot{Revision: c.Revision(), Keys: make(map[string][]byte)}
+	for _, s := range c.shards {
+		for key, record := range s.store {
+			snap.Keys[key] = record
+		}
+	}
+	if err := json.NewEncoder(w).Encode(snap); err != nil {
+		return fmt.Errorf("error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// ReadSnapshot replaces the content of the store with the snapshot read
+// from r. The revisions resume after the one of the snapshot, so that
+// watchers resuming from a revision of before the snapshot was read get
+// ErrCompacted instead of unrelated events.
+func (c *Client) ReadSnapshot(r io.Reader) error {
+	snap, err := decodeSnapshot(r)
+	if err != nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+	store := snap.Keys
+
+	unlock := c.lockAll()
+	defer unlock()
+	// The snapshot replaces every key, so none can be evicted to make
+	// room for it
+	writes := make(map[string][]byte, len(store))
+	for _, s := range c.shards {
+		for key := range s.store {
+			writes[key] = nil
+		}
+	}
+	for key, data := range store {
+		writes[key] = data
+	}
+	if _, err := c.reserve(writes); err != nil {
+		return fmt.Errorf("error reading snapshot: %w", err)
+	}
+
+	c.feed.skip(snap.Revision)
+	// Every key changes version, so transactions that read the old
+	// content conflict
+	for _, s := range c.shards {
+		for key := range s.store {
+			c.remove(s, key)
+		}
+	}
+	for key, data := range store {
+		c.put(c.shardOf(key), key, data)
+	}
+	return nil
+}
+
+// decodeSnapshot reads a snapshot, or the keys alone of an older one
+func decodeSnapshot(r io.Reader) (snapshot, error) {
+	var data json.RawMessage
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return snapshot{}, err
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return snapshot{}, err
+	}
+
+	// The values of the older snapshots are strings, never objects
+	var snap snapshot
+	if keys, ok := raw["keys"]; ok && bytes.HasPrefix(keys, []byte("{")) {
+		err := json.Unmarshal(data, &snap)
+		return snap, err
+	}
+	err := json.Unmarshal(data, &snap.Keys)
+	return snap, err
+}
+
+// SaveSnapshotFile atomically writes a snapshot of the store to path
+func (c *Client) SaveSnapshotFile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := c.WriteSnapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error syncing snapshot file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error closing snapshot file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("error renaming snapshot file: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshotFile restores the store from the snapshot at path.
+// A missing file is not an error, the store is simply left empty.
+func (c *Client) LoadSnapshotFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error opening snapshot file: %w", err)
+	}
+	defer f.Close()
+
+	return c.ReadSnapshot(f)
+}
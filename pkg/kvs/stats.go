@@ -0,0 +1,59 @@
+package kvs
+
+// Stats describes the content of the store
+type Stats struct {
+	Keys int `json:"keys"`
+	// StoredBytes is the size of the stored records
+	StoredBytes int64 `json:"stored_bytes"`
+	// RawBytes is the size the records would have without compression
+	RawBytes int64 `json:"raw_bytes"`
+	// CompressedKeys is the number of records stored compressed
+	CompressedKeys int `json:"compressed_keys"`
+	// CompressionRatio is RawBytes / StoredBytes, 1 when nothing is compressed
+	CompressionRatio float64 `json:"compression_ratio"`
+	// EncryptedKeys is the number of records stored encrypted
+	EncryptedKeys int `json:"encrypted_keys"`
+	// Evictions is the number of keys evicted to respect the quota
+	Evictions uint64 `json:"evictions"`
+	// RejectedWrites is the number of writes rejected by the quota
+	RejectedWrites uint64 `json:"rejected_writes"`
+}
+
+// Stats returns the current statistics of the store. Shards are read one
+// at a time, so the statistics may mix states of the store under
+// concurrent writes.
+func (c *Client) Stats() Stats {
+	st := Stats{CompressionRatio: 1}
+	for _, s := range c.shards {
+		s.mu.RLock()
+		st.Keys += len(s.store)
+		for key, record := range s.store {
+			st.StoredBytes += int64(len(record))
+			// The compression header of encrypted records is sealed with
+			// them, the encryption overhead counts in both sizes
+			overhead := 0
+			if _, encrypted, _ := keyID(record); encrypted {
+				st.EncryptedKeys++
+				if plain, err := c.decrypt(key, record); err == nil {
+					overhead = len(record) - len(plain)
+					record = plain
+				}
+			}
+			size, compressed := c.rawSize(record)
+			st.RawBytes += int64(size + overhead)
+			if compressed {
+				st.CompressedKeys++
+			}
+		}
+		s.mu.RUnlock()
+	}
+	if c.quota != nil {
+		c.quota.mu.Lock()
+		st.Evictions, st.RejectedWrites = c.quota.evictions, c.quota.rejections
+		c.quota.mu.Unlock()
+	}
+	if st.StoredBytes > 0 {
+		st.CompressionRatio = float64(st.RawBytes) / float64(st.StoredBytes)
+	}
+	return st
+}
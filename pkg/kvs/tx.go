@@ -0,0 +1,248 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConflict is returned by optimistic transactions when a key they read
+// was written by someone else before they committed. The transaction had
+// no effect and can be retried.
+var ErrConflict = errors.New("transaction conflict")
+
+// TxOption configures a transaction
+type TxOption func(*txOptions)
+
+type txOptions struct {
+	optimistic bool
+}
+
+// Optimistic runs the transaction without holding shard locks. Reads
+// are validated at commit time, and the commit fails with ErrConflict if
+// any key read was changed in the meantime.
+func Optimistic() TxOption {
+	return func(o *txOptions) {
+		o.optimistic = true
+	}
+}
+
+// Tx reads and writes keys inside a transaction, see Client.Update. Writes
+// are buffered until commit and visible to later reads of the same Tx.
+// A Tx must not be used after its function returns.
+type Tx struct {
+	c          *Client
+	optimistic bool
+	// reads holds each key read from the store, later reads of the same
+	// key see the same value
+	reads map[string]read
+	// writes holds the buffered writes, a nil value is a delete, and
+	// written the order in which keys were first written
+	writes  map[string][]byte
+	written []string
+
+	// locked marks the shards held by a locking transaction, top being
+	// the highest index held or -1. retry is set when a shard could not
+	// be taken in index order, missed being its index.
+	locked []bool
+	top    int
+	retry  bool
+	missed int
+}
+
+// Update runs fn in a transaction and commits its writes if it returns nil.
+// If fn returns an error, or the context is done, nothing is written.
+//
+// Transactions are serializable. By default the shard of each key is
+// locked when fn first reads or writes it, until the commit, so fn must
+// not call other Client methods. Shards are locked in index order: when a
+// shard below one already held is busy, fn runs again from the start on a
+// new Tx, with the shards it used locked upfront, so fn must not have
+// effects other than on its Tx. With Optimistic reads run concurrently
+// and, at commit, the shards of the keys read and written are locked in
+// index order and conflicts are reported as ErrConflict.
+func (c *Client) Update(ctx context.Context, fn func(tx *Tx) error, opts ...TxOption) error {
+	var o txOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	var held []bool
+	for {
+		tx := &Tx{
+			c:          c,
+			optimistic: o.optimistic,
+			reads:      make(map[string]read),
+			writes:     make(map[string][]byte),
+			top:        -1,
+		}
+		if !tx.optimistic {
+			tx.locked = make([]bool, len(c.shards))
+			for i, h := range held {
+				if h {
+					c.shards[i].mu.Lock()
+					tx.locked[i] = true
+					tx.top = i
+				}
+			}
+		}
+
+		victims, err := c.commit(ctx, tx, fn)
+		// Victims are evicted once the locks are released
+		c.evict(victims)
+		if !tx.retry {
+			return err
+		}
+		held = tx.locked
+		held[tx.missed] = true
+	}
+}
+
+// commit runs fn on tx and applies its writes, returning the keys to evict
+func (c *Client) commit(ctx context.Context, tx *Tx, fn func(tx *Tx) error) ([]evicted, error) {
+	defer tx.unlock()
+
+	err := fn(tx)
+	if tx.retry {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if tx.optimistic {
+		keys := make([]string, 0, len(tx.reads)+len(tx.written))
+		for key := range tx.reads {
+			keys = append(keys, key)
+		}
+		keys = append(keys, tx.written...)
+		unlock := c.lockKeys(keys)
+		defer unlock()
+		for key, r := range tx.reads {
+			if c.shardOf(key).versions[key] != r.version {
+				return nil, fmt.Errorf("%w: key %q was changed", ErrConflict, key)
+			}
+		}
+	}
+
+	victims, err := c.reserve(tx.writes)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range tx.written {
+		if data := tx.writes[key]; data != nil {
+			c.put(c.shardOf(key), key, data)
+		} else {
+			c.remove(c.shardOf(key), key)
+		}
+	}
+	return victims, nil
+}
+
+// Get retrieves a value by its key, seeing the writes of the transaction
+func (tx *Tx) Get(key string, target interface{}) error {
+	data, ok := tx.lookup(key)
+	if !ok {
+		return ErrNotFound
+	}
+	return tx.c.decode(key, data, target)
+}
+
+// Exists reports whether a key exists, seeing the writes of the transaction
+func (tx *Tx) Exists(key string) bool {
+	_, ok := tx.lookup(key)
+	return ok
+}
+
+// Set buffers storing value under key
+func (tx *Tx) Set(key string, value interface{}) error {
+	data, err := tx.c.encode(key, value)
+	if err != nil {
+		return err
+	}
+	tx.write(key, data)
+	return nil
+}
+
+// Delete buffers removing key. Deleting a missing key is not an error.
+func (tx *Tx) Delete(key string) {
+	tx.write(key, nil)
+}
+
+// read is a value read from the store and its version
+type read struct {
+	data    []byte
+	version uint64
+}
+
+// lookup returns the raw value of key, buffered or stored
+func (tx *Tx) lookup(key string) ([]byte, bool) {
+	if data, ok := tx.writes[key]; ok {
+		return data, data != nil
+	}
+	if r, ok := tx.reads[key]; ok {
+		return r.data, r.data != nil
+	}
+	if !tx.lock(key) {
+		return nil, false
+	}
+
+	s := tx.c.shardOf(key)
+	if tx.optimistic {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+	}
+	r := read{data: s.store[key], version: s.versions[key]}
+	tx.reads[key] = r
+	if r.data != nil && tx.c.quota != nil {
+		tx.c.quota.touch(key)
+	}
+	return r.data, r.data != nil
+}
+
+// write buffers a write, keeping the order of first writes
+func (tx *Tx) write(key string, data []byte) {
+	if !tx.lock(key) {
+		return
+	}
+	if _, ok := tx.writes[key]; !ok {
+		tx.written = append(tx.written, key)
+	}
+	tx.writes[key] = data
+}
+
+// lock write-locks the shard of key for a locking transaction, reporting
+// false once the transaction must start over. A shard above every shard
+// held is waited for, one below is only taken if it is free, so that
+// transactions never wait for each other in a cycle.
+func (tx *Tx) lock(key string) bool {
+	if tx.optimistic || tx.retry {
+		return !tx.retry
+	}
+	i := tx.c.shardIndex(key)
+	switch {
+	case tx.locked[i]:
+		return true
+	case i > tx.top:
+		tx.c.shards[i].mu.Lock()
+		tx.top = i
+	case !tx.c.shards[i].mu.TryLock():
+		tx.retry = true
+		tx.missed = i
+		return false
+	}
+	tx.locked[i] = true
+	return true
+}
+
+// unlock releases the shards held by the transaction
+func (tx *Tx) unlock() {
+	for i, held := range tx.locked {
+		if held {
+			tx.c.shards[i].mu.Unlock()
+		}
+	}
+}
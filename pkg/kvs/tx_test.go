@@ -0,0 +1,223 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestClient_Update(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+
+	// Action
+	err := client.Update(ctx, func(tx *Tx) error {
+		var a int
+		if err := tx.Get("a", &a); err != nil {
+			return err
+		}
+		if err := tx.Set("b", a+1); err != nil {
+			return err
+		}
+		tx.Delete("a")
+
+		// Reads see the writes of the transaction
+		var b int
+		if err := tx.Get("b", &b); err != nil || b != 2 {
+			t.Errorf("Expected to read 2 from the transaction, got %d (%v)", b, err)
+		}
+		if tx.Exists("a") {
+			t.Errorf("Expected deleted key to be missing in the transaction")
+		}
+		return nil
+	})
+
+	// Assertions
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	var value int
+	if err := client.Get(ctx, "a", &value); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v for deleted key, got %v", ErrNotFound, err)
+	}
+	if err := client.Get(ctx, "b", &value); err != nil || value != 2 {
+		t.Errorf("Expected 2, got %d (%v)", value, err)
+	}
+}
+
+func TestClient_UpdateRollback(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx := context.Background()
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set key: %v", err)
+	}
+	boom := errors.New("boom")
+
+	// Action
+	err := client.Update(ctx, func(tx *Tx) error {
+		if err := tx.Set("a", 2); err != nil {
+			return err
+		}
+		tx.Delete("a")
+		if err := tx.Set("b", 3); err != nil {
+			return err
+		}
+		return boom
+	})
+
+	// Assertions
+	if !errors.Is(err, boom) {
+		t.Fatalf("Expected %v, got %v", boom, err)
+	}
+	var value int
+	if err := client.Get(ctx, "a", &value); err != nil || value != 1 {
+		t.Errorf("Expected 1, got %d (%v)", value, err)
+	}
+	if err := client.Get(ctx, "b", &value); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected %v, got %v", ErrNotFound, err)
+	}
+}
+
+func TestClient_UpdateSerializable(t *testing.T) {
+	for _, optimistic := range []bool{false, true} {
+		name := "Pessimistic"
+		var opts []TxOption
+		if optimistic {
+			name = "Optimistic"
+			opts = append(opts, Optimistic())
+		}
+
+		t.Run(name, func(t *testing.T) {
+			// Setup
+			client := NewClient()
+			ctx := context.Background()
+			if err := client.Set(ctx, "counter", 0); err != nil {
+				t.Fatalf("Failed to set key: %v", err)
+			}
+
+			// Action: concurrent increments, retried on conflict
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						err := client.Update(ctx, func(tx *Tx) error {
+							var n int
+							if err := tx.Get("counter", &n); err != nil {
+								return err
+							}
+							return tx.Set("counter", n+1)
+						}, opts...)
+						if !errors.Is(err, ErrConflict) {
+							if err != nil {
+								t.Errorf("Expected no error, got %v", err)
+							}
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			// Assertions: no increment was lost
+			var n int
+			if err := client.Get(ctx, "counter", &n); err != nil || n != 50 {
+				t.Errorf("Expected 50, got %d (%v)", n, err)
+			}
+		})
+	}
+}
+
+func TestClient_UpdateConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(c *Client) error
+	}{
+		{
+			name:   "Set",
+			change: func(c *Client) error { return c.Set(context.Background(), "a", 2) },
+		},
+		{
+			name: "Delete",
+			change: func(c *Client) error {
+				var batch WriteBatch
+				batch.Delete("a")
+				return c.Write(context.Background(), &batch)
+			},
+		},
+		{
+			name: "DeleteAndCreate",
+			change: func(c *Client) error {
+				if err := c.Delete(context.Background(), "a"); err != nil {
+					return err
+				}
+				return c.Set(context.Background(), "a", 1)
+			},
+		},
+		{
+			name:   "CreateReadMissingKey",
+			change: func(c *Client) error { return c.Set(context.Background(), "missing", 1) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup
+			client := NewClient()
+			ctx := context.Background()
+			if err := client.Set(ctx, "a", 1); err != nil {
+				t.Fatalf("Failed to set key: %v", err)
+			}
+
+			// Action: another writer changes a key read by the transaction
+			err := client.Update(ctx, func(tx *Tx) error {
+				var a int
+				if err := tx.Get("a", &a); err != nil {
+					return err
+				}
+				tx.Exists("missing")
+				if err := tt.change(client); err != nil {
+					t.Fatalf("Failed to change key: %v", err)
+				}
+				return tx.Set("b", a)
+			}, Optimistic())
+
+			// Assertions
+			if !errors.Is(err, ErrConflict) {
+				t.Fatalf("Expected %v, got %v", ErrConflict, err)
+			}
+			var value int
+			if err := client.Get(ctx, "b", &value); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Expected nothing written, got %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_UpdateCanceled(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	// Action
+	err := client.Update(ctx, func(tx *Tx) error {
+		cancel()
+		return tx.Set("a", 1)
+	})
+
+	// Assertions
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected %v, got %v", context.Canceled, err)
+	}
+	var value int
+	if err := client.Get(context.Background(), "a", &value); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected nothing written, got %v", err)
+	}
+}
@@ -0,0 +1,345 @@
+package kvs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultHistory is the number of past events Watch can resume from unless
+// configured otherwise
+const DefaultHistory = 1024
+
+// DefaultWatchBuffer is the number of events a watcher can fall behind
+// before it is told to resync, unless configured otherwise
+const DefaultWatchBuffer = 256
+
+// ErrCompacted is returned by Watch when resuming from a revision older
+// than the history kept by the client, or newer than its current revision
+// as after a restart. The watcher must resync with Scan.
+var ErrCompacted = errors.New("revision is not in the watch history")
+
+// EventOp is the kind of a change event
+type EventOp string
+
+const (
+	// OpPut is a key written with a new value
+	OpPut EventOp = "put"
+	// OpDelete is a key deleted, evictions included
+	OpDelete EventOp = "delete"
+	// OpResync is the last event of a watcher that fell too far behind.
+	// Its Revision is the last revision delivered before: watch again from
+	// it, or resync with Scan if it is no longer in the history.
+	OpResync EventOp = "resync"
+)
+
+// Event is a change of a key, see Client.Watch
+type Event struct {
+	Op  EventOp
+	Key string
+	// Revision orders the events of every key, it is the NewVersion of
+	// the key
+	Revision uint64
+	// OldVersion is the version of the key before the change, 0 if it
+	// did not exist, and NewVersion its version after the change
+	OldVersion uint64
+	NewVersion uint64
+
+	client   *Client
+	record   []byte
+	previous []byte
+}
+
+// Decode unmarshals the value written by a put event into target
+func (e Event) Decode(target interface{}) error {
+	if e.Op != OpPut {
+		return fmt.Errorf("%s event has no value: %w", e.Op, ErrNotFound)
+	}
+	return e.client.decode(e.Key, e.record, target)
+}
+
+// DecodePrevious unmarshals the value of the key before the change into
+// target, such as the value removed by a delete event
+func (e Event) DecodePrevious(target interface{}) error {
+	if e.previous == nil {
+		return fmt.Errorf("%s event has no previous value: %w", e.Op, ErrNotFound)
+	}
+	return e.client.decode(e.Key, e.previous, target)
+}
+
+// WithHistory keeps the last n events, so that watchers can resume from
+// any of their revisions
+func WithHistory(n int) Option {
+	return func(c *Client) {
+		c.feed.history = make([]Event, max(n, 0))
+	}
+}
+
+// WatchOption configures a watcher
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	from   uint64
+	buffer int
+}
+
+// FromRevision resumes watching after revision, replaying the events that
+// followed it from the history
+func FromRevision(revision uint64) WatchOption {
+	return func(o *watchOptions) {
+		o.from = revision
+	}
+}
+
+// WithWatchBuffer sets the number of events the watcher can fall behind
+// before it gets an OpResync event and its channel is closed
+func WithWatchBuffer(n int) WatchOption {
+	return func(o *watchOptions) {
+		o.buffer = max(n, 1)
+	}
+}
+
+// feed numbers the writes of every shard and delivers them to watchers,
+// in revision order
+type feed struct {
+	// revision numbers the writes without a lock, published is the last
+	// revision of which every event is in the history and delivered
+	revision  atomic.Uint64
+	published atomic.Uint64
+
+	mu sync.Mutex
+	// pending holds the events numbered while an earlier revision was
+	// still on its way, until it is published
+	pending map[uint64]Event
+	// history is a ring of the last events, next being the index of the
+	// next one, and holds no revision up to base
+	history  []Event
+	base     uint64
+	next     int
+	watchers map[*watcher]struct{}
+}
+
+// publish numbers a change of key from previous to record and delivers it
+// to the watchers, returning its revision. The caller holds the write lock
+// of the shard of key, so changes of a key are numbered in order; f.mu is
+// only taken to append the change to the history in revision order.
+func (c *Client) publish(op EventOp, key string, oldVersion uint64, previous, record []byte) uint64 {
+	f := &c.feed
+	revision := f.revision.Add(1)
+	e := Event{
+		Op:         op,
+		Key:        key,
+		Revision:   revision,
+		OldVersion: oldVersion,
+		NewVersion: revision,
+		client:     c,
+		record:     record,
+		previous:   previous,
+	}
+	f.publish(e)
+	return revision
+}
+
+// publish appends e to the history once every earlier revision is, along
+// with the events numbered after it that were waiting for it
+func (f *feed) publish(e Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e.Revision != f.published.Load()+1 {
+		// The write numbered before is still being published, it
+		// appends this one after its own
+		if f.pending == nil {
+			f.pending = make(map[uint64]Event)
+		}
+		f.pending[e.Revision] = e
+		return
+	}
+	for {
+		f.append(e)
+		next, ok := f.pending[e.Revision+1]
+		if !ok {
+			return
+		}
+		delete(f.pending, next.Revision)
+		e = next
+	}
+}
+
+// append adds e to the history and delivers it to the watchers of its key,
+// the caller holds f.mu and appends the events in revision order
+func (f *feed) append(e Event) {
+	if len(f.history) > 0 {
+		f.history[f.next] = e
+		f.next = (f.next + 1) % len(f.history)
+	}
+	for w := range f.watchers {
+		if strings.HasPrefix(e.Key, w.prefix) {
+			w.push(e)
+		}
+	}
+	f.published.Store(e.Revision)
+}
+
+// skip makes the revisions resume after revision when it is ahead of the
+// current one, the history starting there. The caller holds the write lock
+// of every shard, so no write is being published.
+func (f *feed) skip(revision uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if revision <= f.revision.Load() {
+		return
+	}
+	f.revision.Store(revision)
+	f.published.Store(revision)
+	f.base = revision
+}
+
+// Revision returns the revision of the last write delivered to watchers
+func (c *Client) Revision() uint64 {
+	return c.feed.published.Load()
+}
+
+// Watch returns a channel of the changes of the keys starting with prefix,
+// in revision order, from now on or, with FromRevision, from the history.
+// The channel is closed when ctx is done, or after an OpResync event if
+// the receiver falls behind by more than the watch buffer.
+//
+// To resync, get the Revision, Scan the keys, then watch from that
+// revision; events already seen by the scan can be told by their version.
+func (c *Client) Watch(ctx context.Context, prefix string, opts ...WatchOption) (<-chan Event, error) {
+	o := watchOptions{buffer: DefaultWatchBuffer}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	w := &watcher{
+		prefix: prefix,
+		limit:  o.buffer,
+		wake:   make(chan struct{}, 1),
+		out:    make(chan Event),
+	}
+
+	f := &c.feed
+	f.mu.Lock()
+	if o.from > 0 {
+		replay, err := f.since(o.from, prefix)
+		if err != nil {
+			f.mu.Unlock()
+			return nil, err
+		}
+		// The replay is bounded by the history, not by the buffer
+		w.queue = replay
+	}
+	if f.watchers == nil {
+		f.watchers = make(map[*watcher]struct{})
+	}
+	f.watchers[w] = struct{}{}
+	f.mu.Unlock()
+
+	go func() {
+		defer func() {
+			f.mu.Lock()
+			delete(f.watchers, w)
+			f.mu.Unlock()
+			close(w.out)
+		}()
+		w.run(ctx, o.from)
+	}()
+	return w.out, nil
+}
+
+// since returns the events of the history after revision for keys starting
+// with prefix, the caller holds f.mu
+func (f *feed) since(revision uint64, prefix string) ([]Event, error) {
+	current := f.published.Load()
+	if revision > current {
+		return nil, fmt.Errorf("%w: revision %d is after the current revision %d", ErrCompacted, revision, current)
+	}
+	if revision == current {
+		return nil, nil
+	}
+
+	// The history holds the revisions after current-len(history), and
+	// after base
+	start := max(current-min(uint64(len(f.history)), current), f.base)
+	if revision < start {
+		return nil, fmt.Errorf("%w: revision %d, history starts after %d", ErrCompacted, revision, start)
+	}
+	var events []Event
+	for i := uint64(0); i < current-revision; i++ {
+		idx := (f.next - int(current-revision) + int(i) + len(f.history)) % len(f.history)
+		if e := f.history[idx]; strings.HasPrefix(e.Key, prefix) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// watcher queues the events of a Watch until they are received
+type watcher struct {
+	prefix string
+	limit  int
+	wake   chan struct{}
+	out    chan Event
+
+	mu     sync.Mutex
+	queue  []Event
+	lagged bool
+}
+
+// push queues e, or marks the watcher as lagging if its buffer is full.
+// It never blocks, so a slow receiver does not slow down writers.
+func (w *watcher) push(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.lagged {
+		return
+	}
+	if len(w.queue) >= w.limit {
+		w.lagged = true
+		w.queue = nil
+	} else {
+		w.queue = append(w.queue, e)
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run delivers the queued events until ctx is done or the watcher lags,
+// last being the revision delivered so far
+func (w *watcher) run(ctx context.Context, last uint64) {
+	for {
+		w.mu.Lock()
+		queue, lagged := w.queue, w.lagged
+		w.queue = nil
+		w.mu.Unlock()
+
+		for _, e := range queue {
+			select {
+			case w.out <- e:
+				last = e.Revision
+			case <-ctx.Done():
+				return
+			}
+		}
+		if lagged {
+			select {
+			case w.out <- Event{Op: OpResync, Revision: last}:
+			case <-ctx.Done():
+			}
+			return
+		}
+
+		select {
+		case <-w.wake:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
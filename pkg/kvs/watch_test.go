@@ -0,0 +1,381 @@
+package kvs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive returns the next event of events, failing after a second
+func receive(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatalf("Expected an event, got a closed channel")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("Expected an event, got none")
+	}
+	return Event{}
+}
+
+func TestClient_Watch(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := client.Set(ctx, "fruit/1", "before"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	events, err := client.Watch(ctx, "fruit/")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Action
+	if err := client.Set(ctx, "owner/1", "ignored"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := client.Set(ctx, "fruit/1", "after"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := client.Delete(ctx, "fruit/1"); err != nil {
+		t.Fatalf("Failed to delete value: %v", err)
+	}
+	if err := client.Set(ctx, "fruit/2", "new"); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+
+	// Assertions
+	want := []Event{
+		{Op: OpPut, Key: "fruit/1", Revision: 3, OldVersion: 1, NewVersion: 3},
+		{Op: OpDelete, Key: "fruit/1", Revision: 4, OldVersion: 3, NewVersion: 4},
+		{Op: OpPut, Key: "fruit/2", Revision: 5, OldVersion: 0, NewVersion: 5},
+	}
+	for i, w := range want {
+		e := receive(t, events)
+		if e.Op != w.Op || e.Key != w.Key || e.Revision != w.Revision || e.OldVersion != w.OldVersion || e.NewVersion != w.NewVersion {
+			t.Errorf("Expected event %d to be %+v, got %+v", i, w, e)
+		}
+	}
+}
+
+func TestEvent_Decode(t *testing.T) {
+	// Setup
+	client := NewClient(WithEncryption(newTestKeyring(t)), WithCompression(Gzip, 0))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := client.Set(ctx, "fruit", secret{Owner: "owner"}); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	if err := client.Delete(ctx, "fruit"); err != nil {
+		t.Fatalf("Failed to delete value: %v", err)
+	}
+
+	// Action
+	var got, previous, deleted secret
+	put, del := receive(t, events), receive(t, events)
+	errPut := put.Decode(&got)
+	errPutPrevious := put.DecodePrevious(&previous)
+	errDelete := del.Decode(&got)
+	errDeletePrevious := del.DecodePrevious(&deleted)
+
+	// Assertions
+	if errPut != nil || got.Owner != "owner" {
+		t.Errorf("Expected the written value, got %+v (%v)", got, errPut)
+	}
+	if !errors.Is(errPutPrevious, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for the previous value of a new key, got %v", errPutPrevious)
+	}
+	if !errors.Is(errDelete, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a delete, got %v", errDelete)
+	}
+	if errDeletePrevious != nil || deleted.Owner != "owner" {
+		t.Errorf("Expected the deleted value, got %+v (%v)", deleted, errDeletePrevious)
+	}
+}
+
+func TestClient_WatchFromRevision(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     uint64
+		wantKeys []string
+		wantErr  error
+	}{
+		{name: "Replays the history", from: 2, wantKeys: []string{"c", "d", "e"}},
+		{name: "Nothing to replay", from: 4, wantKeys: []string{"e"}},
+		{name: "Compacted", from: 1, wantErr: ErrCompacted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup: 4 writes, the first two out of the history
+			client := NewClient(WithHistory(2))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			for _, key := range []string{"a", "b", "c", "d"} {
+				if err := client.Set(ctx, key, key); err != nil {
+					t.Fatalf("Failed to set value: %v", err)
+				}
+			}
+
+			// Action
+			events, err := client.Watch(ctx, "", FromRevision(tt.from))
+
+			// Assertions
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			// Live writes follow the replay
+			if err := client.Set(ctx, "e", "e"); err != nil {
+				t.Fatalf("Failed to set value: %v", err)
+			}
+			for _, key := range tt.wantKeys {
+				if e := receive(t, events); e.Key != key {
+					t.Errorf("Expected key %s, got %s", key, e.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_WatchAfterSnapshot(t *testing.T) {
+	tests := []struct {
+		name     string
+		from     uint64
+		wantKeys []string
+		wantErr  error
+	}{
+		{name: "Revision of the snapshot", from: 3, wantKeys: []string{"a", "b", "c"}},
+		{name: "Revision before the snapshot", from: 2, wantErr: ErrCompacted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Setup: 3 writes saved in a snapshot, read after a restart
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			before := NewClient()
+			for _, key := range []string{"a", "b", "c"} {
+				if err := before.Set(ctx, key, key); err != nil {
+					t.Fatalf("Failed to set value: %v", err)
+				}
+			}
+			var buf bytes.Buffer
+			if err := before.WriteSnapshot(&buf); err != nil {
+				t.Fatalf("Failed to write snapshot: %v", err)
+			}
+			client := NewClient(WithHistory(10))
+			if err := client.ReadSnapshot(&buf); err != nil {
+				t.Fatalf("Failed to read snapshot: %v", err)
+			}
+
+			// Action
+			events, err := client.Watch(ctx, "", FromRevision(tt.from))
+
+			// Assertions: the revisions resume after the snapshot
+			if got := client.Revision(); got != 6 {
+				t.Errorf("Expected revision 6, got %d", got)
+			}
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			keys := make(map[string]bool)
+			for range tt.wantKeys {
+				e := receive(t, events)
+				if e.Revision <= 3 {
+					t.Errorf("Expected a revision after 3, got %d", e.Revision)
+				}
+				keys[e.Key] = true
+			}
+			for _, key := range tt.wantKeys {
+				if !keys[key] {
+					t.Errorf("Expected an event of %s", key)
+				}
+			}
+		})
+	}
+}
+
+func TestClient_WatchFutureRevision(t *testing.T) {
+	// Setup
+	client := NewClient()
+
+	// Action
+	_, err := client.Watch(context.Background(), "", FromRevision(1))
+
+	// Assertions
+	if !errors.Is(err, ErrCompacted) {
+		t.Errorf("Expected ErrCompacted, got %v", err)
+	}
+}
+
+func TestClient_WatchResync(t *testing.T) {
+	// Setup: a watcher that does not receive
+	client := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.Watch(ctx, "", WithWatchBuffer(2))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if err := client.Set(ctx, "a", 1); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	first := receive(t, events)
+
+	// Action: writes never block on the slow watcher
+	for i := 0; i < 10; i++ {
+		if err := client.Set(ctx, fmt.Sprintf("k%d", i), i); err != nil {
+			t.Fatalf("Failed to set value: %v", err)
+		}
+	}
+
+	// Assertions: events queued before the overflow may be delivered, then
+	// the resync event points at the last one and the channel is closed
+	var last uint64 = first.Revision
+	for e := range events {
+		if e.Op == OpResync {
+			if e.Revision != last {
+				t.Errorf("Expected resync from revision %d, got %d", last, e.Revision)
+			}
+			if _, ok := <-events; ok {
+				t.Errorf("Expected the channel to be closed after resync")
+			}
+			// The watcher can resume from where it stopped
+			if _, err := client.Watch(ctx, "", FromRevision(e.Revision)); err != nil {
+				t.Errorf("Expected to resume from revision %d, got %v", e.Revision, err)
+			}
+			return
+		}
+		last = e.Revision
+	}
+	t.Errorf("Expected a resync event")
+}
+
+func TestClient_WatchCancel(t *testing.T) {
+	// Setup
+	client := NewClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := client.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Action
+	cancel()
+
+	// Assertions: the channel is closed and the watcher unregistered
+	select {
+	case _, ok := <-events:
+		if ok {
+			t.Errorf("Expected no event")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Expected the channel to be closed")
+	}
+	client.feed.mu.Lock()
+	watchers := len(client.feed.watchers)
+	client.feed.mu.Unlock()
+	if watchers != 0 {
+		t.Errorf("Expected no watcher, got %d", watchers)
+	}
+}
+
+func TestClient_WatchConcurrentWrites(t *testing.T) {
+	// Setup
+	client := NewClient(WithShards(8))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	const writers, writes = 8, 100
+	events, err := client.Watch(ctx, "", WithWatchBuffer(writers*writes))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	// Action: writers of different shards
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				if err := client.Set(ctx, fmt.Sprintf("%d/%d", w, i%10), i); err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	// Assertions: every write is seen once, in revision order, and each
+	// event follows the previous version of its key
+	versions := make(map[string]uint64)
+	for i := uint64(1); i <= writers*writes; i++ {
+		e := receive(t, events)
+		if e.Revision != i {
+			t.Fatalf("Expected revision %d, got %d", i, e.Revision)
+		}
+		if e.OldVersion != versions[e.Key] {
+			t.Errorf("Expected old version %d of %s, got %d", versions[e.Key], e.Key, e.OldVersion)
+		}
+		versions[e.Key] = e.NewVersion
+	}
+}
+
+func TestClient_WatchOutOfOrderPublish(t *testing.T) {
+	// Setup: a revision numbered by a writer that has not published it yet
+	client := NewClient(WithShards(8))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := client.Watch(ctx, "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	late := client.feed.revision.Add(1)
+
+	// Action
+	if err := client.Set(ctx, "fruit/2", 2); err != nil {
+		t.Fatalf("Failed to set value: %v", err)
+	}
+	held := client.Revision()
+	client.feed.publish(Event{Op: OpPut, Key: "fruit/1", Revision: late, NewVersion: late, client: client})
+
+	// Assertions: the later write waits for the earlier one
+	if held != 0 {
+		t.Errorf("Expected revision 0 while revision 1 is pending, got %d", held)
+	}
+	for _, want := range []string{"fruit/1", "fruit/2"} {
+		if e := receive(t, events); e.Key != want {
+			t.Errorf("Expected an event of %s, got %+v", want, e)
+		}
+	}
+	if got := client.Revision(); got != 2 {
+		t.Errorf("Expected revision 2, got %d", got)
+	}
+	if len(client.feed.pending) != 0 {
+		t.Errorf("Expected no pending event, got %d", len(client.feed.pending))
+	}
+}